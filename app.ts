@@ -18,7 +18,7 @@ import { init as initConnection } from "./ts/connection";
 import { init as initDB } from "./ts/db";
 import { initProfiles } from "./ts/idols";
 import { _, init as initLang } from "./ts/lang";
-import { renderBoard, renderThread } from "./ts/page";
+import { initBanned, renderBoard, renderThread } from "./ts/page";
 import { init as initPosts } from "./ts/posts";
 import { loadPostStores, page } from "./ts/state";
 import { init as initUI } from "./ts/ui";
@@ -38,6 +38,8 @@ async function init() {
     /* skip */
   } else if (page.admin) {
     initAdmin();
+  } else if (page.banned) {
+    initBanned();
   } else if (page.thread) {
     renderThread();
     initConnection();
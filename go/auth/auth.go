@@ -23,8 +23,8 @@ var (
 	// for filtering in XFF IP determination.
 	ReverseProxyIP string
 
-	// board: IP: IsBanned
-	bans   = map[string]map[string]bool{}
+	// Banned networks by board
+	bans   = map[string]*ipTrie{}
 	bansMu sync.RWMutex
 
 	NullPositions = Positions{CurBoard: NotLoggedIn, AnyBoard: NotLoggedIn}
@@ -102,14 +102,18 @@ func BcryptCompare(password string, hash []byte) error {
 
 // IsBanned returns if the IP is banned on the target board
 func IsBanned(board, ip string) (banned bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
 	bansMu.RLock()
 	defer bansMu.RUnlock()
 	global := bans["all"]
-	ips := bans[board]
-	if global != nil && global[ip] {
+	nets := bans[board]
+	if global != nil && global.contains(addr) {
 		return true
 	}
-	if ips != nil && ips[ip] {
+	if nets != nil && nets.contains(addr) {
 		return true
 	}
 	return false
@@ -117,14 +121,18 @@ func IsBanned(board, ip string) (banned bool) {
 
 // SetBans replaces the ban cache with the new set
 func SetBans(b ...Ban) {
-	newBans := map[string]map[string]bool{}
+	newBans := map[string]*ipTrie{}
 	for _, b := range b {
+		n, err := ParseBanRange(b.IP)
+		if err != nil {
+			continue
+		}
 		board, ok := newBans[b.Board]
 		if !ok {
-			board = map[string]bool{}
+			board = &ipTrie{}
 			newBans[b.Board] = board
 		}
-		board[b.IP] = true
+		board.insert(n)
 	}
 	bansMu.Lock()
 	bans = newBans
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"net"
 	"net/http/httptest"
 	"testing"
 
@@ -11,17 +12,21 @@ import (
 )
 
 func init() {
-	config.Set(config.Configs{})
+	config.Set(config.ServerConfig{})
 }
 
+// Board existence is checked against the board configs.
 func TestIsBoard(t *testing.T) {
-	config.Clear()
-	_, err := config.SetBoardConfigs(config.BoardConfigs{
-		ID: "a",
-	})
-	if err != nil {
-		t.Fatal(err)
+	for _, id := range [...]string{"a", "all"} {
+		err := config.SetBoardConfig(config.BoardConfig{
+			BoardPublic: config.BoardPublic{ID: id},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
+	defer config.RemoveBoard("a")
+	defer config.RemoveBoard("all")
 
 	cases := [...]struct {
 		name, in string
@@ -35,7 +40,7 @@ func TestIsBoard(t *testing.T) {
 	for i := range cases {
 		c := cases[i]
 		t.Run(c.name, func(t *testing.T) {
-			if IsBoard(c.in) != c.isBoard {
+			if config.IsBoard(c.in) != c.isBoard {
 				t.Fatal("unexpected result")
 			}
 		})
@@ -85,6 +90,152 @@ func TestGetIP(t *testing.T) {
 	}
 }
 
+func TestIsBanned(t *testing.T) {
+	SetBans(
+		Ban{IP: "10.0.0.1", Board: "a"},
+		Ban{IP: "100.64.0.0/16", Board: "a"},
+		Ban{IP: "2001:db8:1:2::/64", Board: "all"},
+	)
+	defer SetBans()
+
+	cases := [...]struct {
+		name, board, ip string
+		banned          bool
+	}{
+		{"single IP", "a", "10.0.0.1", true},
+		{"other IP", "a", "10.0.0.2", false},
+		{"other board", "b", "10.0.0.1", false},
+		{"IPv4 range", "a", "100.64.13.37", true},
+		{"outside IPv4 range", "a", "100.65.0.1", false},
+		{"IPv6 range", "b", "2001:db8:1:2:dead:beef::1", true},
+		{"outside IPv6 range", "b", "2001:db8:1:3::1", false},
+		{"invalid IP", "a", "nope", false},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			if banned := IsBanned(c.board, c.ip); banned != c.banned {
+				LogUnexpected(t, c.banned, banned)
+			}
+		})
+	}
+}
+
+func TestIPTrie(t *testing.T) {
+	t.Parallel()
+
+	cases := [...]struct {
+		name     string
+		networks []string
+		ip       string
+		banned   bool
+	}{
+		{
+			"narrow after wide",
+			[]string{"10.0.0.0/8", "10.1.0.0/16"},
+			"10.2.0.1",
+			true,
+		},
+		{
+			"wide after narrow",
+			[]string{"10.1.0.0/16", "10.0.0.0/8"},
+			"10.2.0.1",
+			true,
+		},
+		{
+			"inside narrow after wide",
+			[]string{"10.0.0.0/8", "10.1.0.0/16"},
+			"10.1.0.1",
+			true,
+		},
+		{
+			"outside overlapping",
+			[]string{"10.1.0.0/16", "10.0.0.0/8"},
+			"11.0.0.1",
+			false,
+		},
+		{
+			"IPv6 narrow after wide",
+			[]string{"2001:db8::/32", "2001:db8:1::/48"},
+			"2001:db8:2::1",
+			true,
+		},
+		{
+			"IPv6 wide after narrow",
+			[]string{"2001:db8:1::/48", "2001:db8::/32"},
+			"2001:db8:2::1",
+			true,
+		},
+		{
+			"IPv4-mapped IPv6 address",
+			[]string{"10.0.0.0/8"},
+			"::ffff:10.1.2.3",
+			true,
+		},
+		{
+			"IPv4-mapped IPv6 outside range",
+			[]string{"10.0.0.0/8"},
+			"::ffff:11.1.2.3",
+			false,
+		},
+		{
+			"IPv4 address in IPv6 range",
+			[]string{"2001:db8::/32"},
+			"10.1.2.3",
+			false,
+		},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			var trie ipTrie
+			for _, s := range c.networks {
+				n, err := ParseBanRange(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				trie.insert(n)
+			}
+			if banned := trie.contains(net.ParseIP(c.ip)); banned != c.banned {
+				LogUnexpected(t, c.banned, banned)
+			}
+		})
+	}
+}
+
+func TestBanPrefixes(t *testing.T) {
+	t.Parallel()
+
+	cases := [...]struct {
+		name, ip, out string
+		prefixes      BanPrefixes
+	}{
+		{"IPv4 default", "10.1.2.3", "10.1.2.3/32", BanPrefixes{}},
+		{"IPv6 default", "2001:db8::1", "2001:db8::/64", BanPrefixes{}},
+		{"IPv4 range", "10.1.2.3", "10.1.0.0/16", BanPrefixes{IPv4: 16}},
+		{"IPv6 range", "2001:db8:1::1", "2001:db8::/32", BanPrefixes{IPv6: 32}},
+		{"too wide", "10.1.2.3", "", BanPrefixes{IPv4: 8}},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			n, err := c.prefixes.Network(c.ip)
+			switch {
+			case c.out == "" && err != ErrInvalidBanRange:
+				LogUnexpected(t, ErrInvalidBanRange, err)
+			case n != c.out:
+				LogUnexpected(t, c.out, n)
+			}
+		})
+	}
+}
+
 func TestBcryptHash(t *testing.T) {
 	t.Parallel()
 
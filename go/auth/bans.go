@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// Default and widest allowed sizes of banned networks. IPv6 users
+// usually get a whole /64, so banning a single address is pointless.
+const (
+	DefaultBanPrefixIPv4 = 32
+	DefaultBanPrefixIPv6 = 64
+	MinBanPrefixIPv4     = 16
+	MinBanPrefixIPv6     = 32
+)
+
+var (
+	ErrInvalidBanRange = errors.New("invalid ban range")
+)
+
+// BanPrefixes sets the size of banned networks for each address family.
+// Zero values fall back to defaults.
+type BanPrefixes struct {
+	IPv4 int
+	IPv6 int
+}
+
+// Valid returns, if prefixes are within the allowed bounds.
+func (p BanPrefixes) Valid() bool {
+	return (p.IPv4 == 0 || p.IPv4 >= MinBanPrefixIPv4 && p.IPv4 <= 32) &&
+		(p.IPv6 == 0 || p.IPv6 >= MinBanPrefixIPv6 && p.IPv6 <= 128)
+}
+
+// Network returns the banned network the IP belongs to in CIDR notation.
+func (p BanPrefixes) Network(ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || !p.Valid() {
+		return "", ErrInvalidBanRange
+	}
+	var mask net.IPMask
+	if ip4 := addr.To4(); ip4 != nil {
+		addr = ip4
+		mask = net.CIDRMask(orDefault(p.IPv4, DefaultBanPrefixIPv4), 32)
+	} else {
+		mask = net.CIDRMask(orDefault(p.IPv6, DefaultBanPrefixIPv6), 128)
+	}
+	n := net.IPNet{IP: addr.Mask(mask), Mask: mask}
+	return n.String(), nil
+}
+
+func orDefault(prefix, def int) int {
+	if prefix == 0 {
+		return def
+	}
+	return prefix
+}
+
+// ParseBanRange parses banned network as stored in the database. Single
+// addresses are returned without the prefix length.
+func ParseBanRange(s string) (*net.IPNet, error) {
+	if strings.IndexByte(s, '/') != -1 {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, ErrInvalidBanRange
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Binary prefix trie of banned networks. IPv4 addresses are stored in
+// their IPv4-mapped IPv6 form, so both families share the same tree.
+type ipTrie struct {
+	children [2]*ipTrie
+	// Network ending at this node is banned.
+	banned bool
+}
+
+func ipBit(ip net.IP, i int) byte {
+	return ip[i/8] >> uint(7-i%8) & 1
+}
+
+func (t *ipTrie) insert(n *net.IPNet) {
+	ip := n.IP.To16()
+	ones, bits := n.Mask.Size()
+	if ip == nil || bits == 0 {
+		return
+	}
+	ones += 128 - bits
+	node := t
+	for i := 0; i < ones; i++ {
+		if node.banned {
+			// Already covered by a wider network
+			return
+		}
+		b := ipBit(ip, i)
+		if node.children[b] == nil {
+			node.children[b] = &ipTrie{}
+		}
+		node = node.children[b]
+	}
+	node.banned = true
+}
+
+func (t *ipTrie) contains(ip net.IP) bool {
+	ip = ip.To16()
+	if ip == nil {
+		return false
+	}
+	node := t
+	for i := 0; i < 128; i++ {
+		if node.banned {
+			return true
+		}
+		node = node.children[ipBit(ip, i)]
+		if node == nil {
+			return false
+		}
+	}
+	return node.banned
+}
@@ -76,6 +76,7 @@ func (staff *Staff) TryMarshal() []byte {
 
 // Ban holdsan entry of an IP being banned from a board
 type Ban struct {
+	// Single IP or network in CIDR notation
 	IP    string `json:"ip"`
 	Board string `json:"board"`
 }
@@ -114,6 +115,17 @@ const (
 	SpoilerImage
 	DeleteThread
 	UpdateBoard
+	StickyThread
+	UnstickyThread
+	LockThread
+	UnlockThread
+	MoveThread
+	DismissReport
+	AcceptReport
+	UndeletePost
+	AcceptAppeal
+	RejectAppeal
+	BlockImage
 )
 
 // Single entry in the moderation log
@@ -136,6 +148,49 @@ func (log *ModLogRecords) TryMarshal() []byte {
 	return data
 }
 
+// User report of a rule-breaking post
+type Report struct {
+	ID      uint64 `json:"id"`
+	Board   string `json:"board"`
+	Post    uint64 `json:"post"`
+	Reason  string `json:"reason"`
+	Created int64  `json:"created"`
+}
+
+//easyjson:json
+type Reports []Report
+
+func (reports *Reports) TryMarshal() []byte {
+	data, err := reports.MarshalJSON()
+	if err != nil {
+		return []byte("null")
+	}
+	return data
+}
+
+// Appeal of a ban by the banned user
+type Appeal struct {
+	ID    uint64 `json:"id"`
+	Board string `json:"board"`
+	Post  uint64 `json:"post"`
+	// Reason of the appealed ban
+	Reason  string `json:"reason"`
+	Body    string `json:"body"`
+	Expires int64  `json:"expires"`
+	Created int64  `json:"created"`
+}
+
+//easyjson:json
+type Appeals []Appeal
+
+func (appeals *Appeals) TryMarshal() []byte {
+	data, err := appeals.MarshalJSON()
+	if err != nil {
+		return []byte("null")
+	}
+	return data
+}
+
 type IgnoreMode int
 
 const (
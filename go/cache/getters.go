@@ -3,6 +3,8 @@ package cache
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/cutechan/cutechan/go/metrics"
 )
 
 // FrontEnd provides functions for fetching, validating and generating the
@@ -51,6 +53,7 @@ func getData(s *store, f FrontEnd) (
 	if s.data != nil {
 		if s.isFresh() {
 			// No freshness check needed yet
+			metrics.CacheHits.Inc()
 			return s.data, s.json, s.updateCounter, false, nil
 		}
 		ctr, err = f.GetCounter(s.key)
@@ -60,11 +63,13 @@ func getData(s *store, f FrontEnd) (
 		if ctr == s.updateCounter {
 			// Still fresh
 			s.lastChecked = time.Now()
+			metrics.CacheHits.Inc()
 			return s.data, s.json, s.updateCounter, false, nil
 		}
 	}
 
 	fresh = true
+	metrics.CacheMisses.Inc()
 	if ctr == 0 {
 		ctr, err = f.GetCounter(s.key)
 		if err != nil {
@@ -6,6 +6,8 @@ import (
 	"container/list"
 	"sync"
 	"time"
+
+	"github.com/cutechan/cutechan/go/metrics"
 )
 
 // Time for the cache to expire and need counter comparison
@@ -29,6 +31,8 @@ type Key struct {
 	LastN   int
 	Page    int
 	Catalog bool
+	// Include deleted posts, which are only shown to moderators.
+	Deleted bool
 }
 
 // Single cache entry
@@ -78,6 +82,9 @@ func updateUsedSize(delta int) {
 	defer mu.Unlock()
 
 	totalUsed += delta
+	defer func() {
+		metrics.CacheUsed.Set(float64(totalUsed))
+	}()
 
 	for totalUsed > Size<<20 {
 		last := ll.Back()
@@ -86,6 +93,7 @@ func updateUsedSize(delta int) {
 		}
 		s := ll.Remove(last).(*store)
 		delete(cache, s.key)
+		metrics.CacheEvictions.Inc()
 
 		s.sizeMu.Lock()
 		totalUsed -= s.size
@@ -8,6 +8,7 @@ import (
 	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/cache"
 	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/feeds"
 	"github.com/cutechan/cutechan/go/file"
 	"github.com/cutechan/cutechan/go/geoip"
 	"github.com/cutechan/cutechan/go/lang"
@@ -26,6 +27,8 @@ const VERSION = "0.0.0"
 const USAGE = `
 Usage:
   cutechan [options]
+  cutechan migrate-files --from <backend> --to <backend> [--dry-run] [options]
+  cutechan regen-thumbs [--check] [options]
   cutechan [-h | --help]
   cutechan [-V | --version]
 
@@ -45,6 +48,43 @@ Options:
   -z <size>     Cache size in megabytes (default: 128).
   -s <sitedir>  Site directory location (default: ./dist).
   --cfg <path>  Path to TOML config.
+
+Migration options:
+  --from <backend>   Uploads backend to copy files from.
+  --to <backend>     Uploads backend to copy files to.
+  --dry-run          Only read and verify files, don't write anything.
+  --progress <path>  File to keep migration progress in, allows to resume
+                     interrupted migration (default: migrate-files.progress).
+
+Migration connection options:
+  Unset ones default to the file_* settings of the config, so files can be
+  copied between two remote servers.
+  --from-dir <path>           Override file_dir of the source backend.
+  --from-address <addr>       Override file_address of the source backend.
+  --from-host-key <key>       Override file_host_key of the source backend.
+  --from-username <name>      Override file_username of the source backend.
+  --from-password <pass>      Override file_password of the source backend.
+  --from-auth-url <url>       Override file_auth_url of the source backend.
+  --from-container <name>     Override file_container of the source backend.
+  --from-endpoint <url>       Override file_endpoint of the source backend.
+  --from-bucket <name>        Override file_bucket of the source backend.
+  --from-region <name>        Override file_region of the source backend.
+  --from-path-style           Enable file_path_style for the source backend.
+  --to-dir <path>             Override file_dir of the target backend.
+  --to-address <addr>         Override file_address of the target backend.
+  --to-host-key <key>         Override file_host_key of the target backend.
+  --to-username <name>        Override file_username of the target backend.
+  --to-password <pass>        Override file_password of the target backend.
+  --to-auth-url <url>         Override file_auth_url of the target backend.
+  --to-container <name>       Override file_container of the target backend.
+  --to-endpoint <url>         Override file_endpoint of the target backend.
+  --to-bucket <name>          Override file_bucket of the target backend.
+  --to-region <name>          Override file_region of the target backend.
+  --to-path-style             Enable file_path_style for the target backend.
+
+Thumbnail options:
+  --check  Only check that files and thumbnails are present and intact,
+           don't regenerate anything.
 `
 
 // Duplicates USAGE so make sure to update consistently!
@@ -65,6 +105,16 @@ var confDefault = config{
 	FilePassword:  "password",
 	FileAuthURL:   "https://localhost/v1.0",
 	FileContainer: "uploads",
+	FileEndpoint:  "https://s3.amazonaws.com",
+	FileBucket:    "uploads",
+	FileRegion:    "us-east-1",
+	Progress:      "migrate-files.progress",
+	ThumbWorkers:  1,
+	ThumbQueue:    32,
+	ThumbTimeout:  60,
+	ThumbCPU:      60,
+	ThumbMemory:   1024,
+	ThumbFileSize: 64,
 }
 
 type config struct {
@@ -87,6 +137,45 @@ type config struct {
 	FilePassword  string `toml:"file_password"`
 	FileAuthURL   string `toml:"file_auth_url"`
 	FileContainer string `toml:"file_container"`
+	FileEndpoint  string `toml:"file_endpoint"`
+	FileBucket    string `toml:"file_bucket"`
+	FileRegion    string `toml:"file_region"`
+	FilePathStyle bool   `toml:"file_path_style"`
+	ThumbWorkers  int    `toml:"thumb_workers"`
+	ThumbQueue    int    `toml:"thumb_queue"`
+	ThumbTimeout  int    `toml:"thumb_timeout"`
+	ThumbCPU      int    `toml:"thumb_cpu"`
+	ThumbMemory   uint64 `toml:"thumb_memory"`
+	ThumbFileSize uint64 `toml:"thumb_file_size"`
+	MigrateFiles  bool   `docopt:"migrate-files" toml:"-"`
+	From          string `docopt:"--from" toml:"-"`
+	To            string `docopt:"--to" toml:"-"`
+	FromDir       string `docopt:"--from-dir" toml:"-"`
+	FromAddress   string `docopt:"--from-address" toml:"-"`
+	FromHostKey   string `docopt:"--from-host-key" toml:"-"`
+	FromUsername  string `docopt:"--from-username" toml:"-"`
+	FromPassword  string `docopt:"--from-password" toml:"-"`
+	FromAuthURL   string `docopt:"--from-auth-url" toml:"-"`
+	FromContainer string `docopt:"--from-container" toml:"-"`
+	FromEndpoint  string `docopt:"--from-endpoint" toml:"-"`
+	FromBucket    string `docopt:"--from-bucket" toml:"-"`
+	FromRegion    string `docopt:"--from-region" toml:"-"`
+	FromPathStyle bool   `docopt:"--from-path-style" toml:"-"`
+	ToDir         string `docopt:"--to-dir" toml:"-"`
+	ToAddress     string `docopt:"--to-address" toml:"-"`
+	ToHostKey     string `docopt:"--to-host-key" toml:"-"`
+	ToUsername    string `docopt:"--to-username" toml:"-"`
+	ToPassword    string `docopt:"--to-password" toml:"-"`
+	ToAuthURL     string `docopt:"--to-auth-url" toml:"-"`
+	ToContainer   string `docopt:"--to-container" toml:"-"`
+	ToEndpoint    string `docopt:"--to-endpoint" toml:"-"`
+	ToBucket      string `docopt:"--to-bucket" toml:"-"`
+	ToRegion      string `docopt:"--to-region" toml:"-"`
+	ToPathStyle   bool   `docopt:"--to-path-style" toml:"-"`
+	DryRun        bool   `docopt:"--dry-run" toml:"-"`
+	Progress      string `docopt:"--progress" toml:"-"`
+	RegenThumbs   bool   `docopt:"regen-thumbs" toml:"-"`
+	Check         bool   `docopt:"--check" toml:"-"`
 }
 
 // Merge non-zero values from additional config.
@@ -113,6 +202,78 @@ func merge(conf, confAdd, confDef *config) {
 	}
 }
 
+func isValidBackend(backend string) bool {
+	switch backend {
+	case "fs", "sftp", "swift", "s3":
+		return true
+	}
+	return false
+}
+
+// Get file backend parameters from config.
+func getFileConfig(conf config, backend string) file.Config {
+	return file.Config{
+		Backend:   backend,
+		Dir:       conf.FileDir,
+		Address:   conf.FileAddress,
+		HostKey:   conf.FileHostKey,
+		Username:  conf.FileUsername,
+		Password:  conf.FilePassword,
+		AuthURL:   conf.FileAuthURL,
+		Container: conf.FileContainer,
+		Endpoint:  conf.FileEndpoint,
+		Bucket:    conf.FileBucket,
+		Region:    conf.FileRegion,
+		PathStyle: conf.FilePathStyle,
+	}
+}
+
+// Get parameters of the source and target backends of file migration.
+// Explicitly set connection options of each side override the shared ones.
+func getMigrateFileConfigs(conf config) (from, to file.Config) {
+	from = getFileConfig(conf, conf.From)
+	overrideFileConfig(&from, file.Config{
+		Dir:       conf.FromDir,
+		Address:   conf.FromAddress,
+		HostKey:   conf.FromHostKey,
+		Username:  conf.FromUsername,
+		Password:  conf.FromPassword,
+		AuthURL:   conf.FromAuthURL,
+		Container: conf.FromContainer,
+		Endpoint:  conf.FromEndpoint,
+		Bucket:    conf.FromBucket,
+		Region:    conf.FromRegion,
+		PathStyle: conf.FromPathStyle,
+	})
+	to = getFileConfig(conf, conf.To)
+	overrideFileConfig(&to, file.Config{
+		Dir:       conf.ToDir,
+		Address:   conf.ToAddress,
+		HostKey:   conf.ToHostKey,
+		Username:  conf.ToUsername,
+		Password:  conf.ToPassword,
+		AuthURL:   conf.ToAuthURL,
+		Container: conf.ToContainer,
+		Endpoint:  conf.ToEndpoint,
+		Bucket:    conf.ToBucket,
+		Region:    conf.ToRegion,
+		PathStyle: conf.ToPathStyle,
+	})
+	return
+}
+
+// Set non-zero values of override in file backend parameters.
+func overrideFileConfig(conf *file.Config, override file.Config) {
+	v := reflect.ValueOf(conf).Elem()
+	vOver := reflect.ValueOf(override)
+	for i := 0; i < v.NumField(); i++ {
+		f := vOver.Field(i)
+		if !reflect.DeepEqual(f.Interface(), reflect.Zero(f.Type()).Interface()) {
+			v.Field(i).Set(f)
+		}
+	}
+}
+
 func serve(conf config) {
 	// TODO(Kagami): Use config structs instead of globals.
 	db.ConnArgs = conf.Conn
@@ -121,20 +282,14 @@ func serve(conf config) {
 	geoip.CountryHeader = conf.GeoHeader
 
 	startFileBackend := func() error {
-		return file.StartBackend(file.Config{
-			Backend:   conf.FileBackend,
-			Dir:       conf.FileDir,
-			Address:   conf.FileAddress,
-			HostKey:   conf.FileHostKey,
-			Username:  conf.FileUsername,
-			Password:  conf.FilePassword,
-			AuthURL:   conf.FileAuthURL,
-			Container: conf.FileContainer,
-		})
+		return file.StartBackend(getFileConfig(conf, conf.FileBackend))
 	}
 
 	// Prepare subsystems.
-	err := util.RunTasks([][]util.Task{{db.StartDB, lang.Load, templates.CompileMustache, startFileBackend}})
+	err := util.RunTasks([][]util.Task{
+		{db.StartDB, lang.Load, templates.CompileMustache, startFileBackend},
+		{feeds.Listen},
+	})
 	if err != nil {
 		log.Fatalf("Error preparing server: %v", err)
 	}
@@ -146,8 +301,10 @@ func serve(conf config) {
 		DebugRoutes:  conf.Debug,
 		Address:      address,
 		SecureCookie: conf.Secure,
-		ThumbUser:    conf.User,
 		SiteDir:      conf.SiteDir,
+		ThumbWorkers: conf.ThumbWorkers,
+		ThumbQueue:   conf.ThumbQueue,
+		Thumb:        getThumbOptions(conf),
 	}))
 }
 
@@ -169,9 +326,24 @@ func main() {
 	}
 	merge(&conf, &confFromFile, &confDefault)
 
-	if conf.FileBackend != "fs" && conf.FileBackend != "sftp" && conf.FileBackend != "swift" {
+	if conf.MigrateFiles {
+		for _, backend := range [...]string{conf.From, conf.To} {
+			if !isValidBackend(backend) {
+				log.Fatalf("Bad uploads backend: %s", backend)
+			}
+		}
+		migrateFiles(conf)
+		return
+	}
+
+	if !isValidBackend(conf.FileBackend) {
 		log.Fatalf("Bad uploads backend: %s", conf.FileBackend)
 	}
 
+	if conf.RegenThumbs {
+		regenThumbs(conf)
+		return
+	}
+
 	serve(conf)
 }
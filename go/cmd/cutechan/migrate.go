@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
+)
+
+// Number of image records to read from DB at once.
+const migrateBatchSize = 100
+
+var (
+	errMissingSource = errors.New("source file is missing")
+	errBadChecksum   = errors.New("source file checksum mismatch")
+)
+
+type fileBackend interface {
+	Write(sha1 string, fileType, thumbType uint8, src io.ReadSeeker, thumb []byte) error
+	Read(sha1 string, fileType, thumbType uint8) (src, thumb []byte, err error)
+	ReplaceThumb(sha1 string, oldType, newType uint8, thumb []byte) error
+	WriteVariant(sha1, name string, data []byte) error
+	ReadVariant(sha1, name string) ([]byte, error)
+	DeleteVariant(sha1, name string) error
+}
+
+// Copy all uploads referenced in the images table between file backends.
+// Progress is saved after every file, so interrupted migration continues
+// where it stopped. Files, that are missing or damaged in the source
+// backend, are logged and skipped.
+func migrateFiles(conf config) {
+	db.ConnArgs = conf.Conn
+	db.NoCleanup = true
+	if err := db.StartDB(); err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+
+	fromConf, toConf := getMigrateFileConfigs(conf)
+	from, err := file.MakeBackend(fromConf)
+	if err != nil {
+		log.Fatalf("Error preparing %s backend: %v", conf.From, err)
+	}
+	var to fileBackend
+	if !conf.DryRun {
+		to, err = file.MakeBackend(toConf)
+		if err != nil {
+			log.Fatalf("Error preparing %s backend: %v", conf.To, err)
+		}
+	}
+
+	last, err := readMigrateProgress(conf.Progress)
+	if err != nil {
+		log.Fatalf("Error reading progress: %v", err)
+	}
+	if last != "" {
+		log.Printf("Resuming migration after %s", last)
+	}
+
+	var copied, skipped int
+	for {
+		images, err := db.GetImagesAfter(last, migrateBatchSize)
+		if err != nil {
+			log.Fatalf("Error reading images: %v", err)
+		}
+		if len(images) == 0 {
+			break
+		}
+		for _, img := range images {
+			err = migrateFile(from, to, img)
+			switch err {
+			case nil:
+				copied++
+			case errMissingSource, errBadChecksum:
+				log.Printf("Skipping %s: %v", img.SHA1, err)
+				skipped++
+			default:
+				log.Fatalf("Error migrating %s: %v", img.SHA1, err)
+			}
+			last = img.SHA1
+			if !conf.DryRun {
+				err = ioutil.WriteFile(conf.Progress, []byte(last), 0644)
+				if err != nil {
+					log.Fatalf("Error saving progress: %v", err)
+				}
+			}
+		}
+	}
+
+	if conf.DryRun {
+		log.Printf("Dry run: %d files OK, %d would be skipped", copied, skipped)
+	} else {
+		log.Printf("Migrated %d files, skipped %d", copied, skipped)
+	}
+	if skipped != 0 {
+		os.Exit(1)
+	}
+}
+
+// Read SHA1 of the last migrated file. Returns empty string, if there is no
+// saved progress.
+func readMigrateProgress(path string) (string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(buf)), err
+}
+
+// Copy single file with its thumbnail and verify written data. Source is
+// only read and verified, if destination is nil.
+func migrateFile(from, to fileBackend, img common.ImageCommon) (err error) {
+	src, thumb, err := from.Read(img.SHA1, img.FileType, img.ThumbType)
+	switch {
+	case err != nil:
+		return
+	case src == nil:
+		return errMissingSource
+	case getSHA1(src) != img.SHA1:
+		return errBadChecksum
+	}
+	variants := make([][]byte, len(img.Variants))
+	for i, v := range img.Variants {
+		if variants[i], err = from.ReadVariant(img.SHA1, v.Name); err != nil {
+			return
+		}
+	}
+	if to == nil {
+		return
+	}
+
+	err = to.Write(img.SHA1, img.FileType, img.ThumbType, bytes.NewReader(src), thumb)
+	if err != nil {
+		return
+	}
+	written, writtenThumb, err := to.Read(img.SHA1, img.FileType, img.ThumbType)
+	switch {
+	case err != nil:
+		return
+	case getSHA1(written) != img.SHA1, !bytes.Equal(writtenThumb, thumb):
+		return errors.New("written files don't match source")
+	}
+
+	// Missing variants are not fatal, they can be regenerated later.
+	for i, v := range img.Variants {
+		if variants[i] == nil {
+			log.Printf("%s: %s variant is missing", img.SHA1, v.Name)
+			continue
+		}
+		if err = to.WriteVariant(img.SHA1, v.Name, variants[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func getSHA1(data []byte) string {
+	hash := sha1.Sum(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
+	"github.com/cutechan/cutechan/go/ipc"
+)
+
+var errBadWebP = errors.New("malformed WebP")
+
+// Get thumbnailer parameters from config.
+func getThumbOptions(conf config) ipc.ThumbOptions {
+	return ipc.ThumbOptions{
+		User:        conf.User,
+		Timeout:     time.Duration(conf.ThumbTimeout) * time.Second,
+		MaxCPU:      time.Duration(conf.ThumbCPU) * time.Second,
+		MaxMemory:   conf.ThumbMemory << 20,
+		MaxFileSize: conf.ThumbFileSize << 20,
+	}
+}
+
+// Walk all images, report missing or corrupted files and regenerate
+// thumbnails with current thumbnailer settings. Only reports problems in
+// check mode.
+func regenThumbs(conf config) {
+	db.ConnArgs = conf.Conn
+	db.NoCleanup = true
+	if err := db.StartDB(); err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	backend, err := file.MakeBackend(getFileConfig(conf, conf.FileBackend))
+	if err != nil {
+		log.Fatalf("Error preparing %s backend: %v", conf.FileBackend, err)
+	}
+	opts := getThumbOptions(conf)
+
+	var ok, damaged int
+	last := ""
+	for {
+		images, err := db.GetImagesAfter(last, migrateBatchSize)
+		if err != nil {
+			log.Fatalf("Error reading images: %v", err)
+		}
+		if len(images) == 0 {
+			break
+		}
+		for _, img := range images {
+			last = img.SHA1
+			src, thumb, err := backend.Read(img.SHA1, img.FileType, img.ThumbType)
+			switch {
+			case err != nil:
+				log.Fatalf("Error reading %s: %v", img.SHA1, err)
+			case src == nil:
+				log.Printf("%s: %v", img.SHA1, errMissingSource)
+				damaged++
+				continue
+			case getSHA1(src) != img.SHA1:
+				log.Printf("%s: %v", img.SHA1, errBadChecksum)
+				damaged++
+				continue
+			}
+
+			if conf.Check {
+				if checkThumbs(backend, img, thumb) {
+					ok++
+				} else {
+					damaged++
+				}
+				continue
+			}
+
+			if err := regenThumb(backend, opts, img, src, thumb); err != nil {
+				log.Printf("%s: %v", img.SHA1, err)
+				damaged++
+				continue
+			}
+			ok++
+		}
+	}
+
+	if conf.Check {
+		log.Printf("Checked %d files OK, %d damaged", ok, damaged)
+	} else {
+		log.Printf("Regenerated %d thumbnails, %d files damaged", ok, damaged)
+	}
+	if damaged != 0 {
+		os.Exit(1)
+	}
+}
+
+// Report missing or corrupted thumbnail and variants of a single file.
+func checkThumbs(backend fileBackend, img common.ImageCommon, thumb []byte) bool {
+	ok := true
+	// Thumbnail is skipped only for audio records and archives.
+	isRecord := img.Audio && !img.Video
+	switch {
+	case thumb == nil && !isRecord && !common.IsArchive(img.FileType):
+		log.Printf("%s: thumbnail is missing", img.SHA1)
+		ok = false
+	case thumb != nil:
+		if err := checkThumb(thumb, img.Dims[2], img.Dims[3]); err != nil {
+			log.Printf("%s: thumbnail is corrupted: %v", img.SHA1, err)
+			ok = false
+		}
+	}
+	for _, v := range img.Variants {
+		data, err := backend.ReadVariant(img.SHA1, v.Name)
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", img.SHA1, err)
+		}
+		if data == nil {
+			log.Printf("%s: %s variant is missing", img.SHA1, v.Name)
+			ok = false
+			continue
+		}
+		if err := checkVariant(data, v.Width, v.Height); err != nil {
+			log.Printf("%s: %s variant is corrupted: %v", img.SHA1, v.Name, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Decode whole thumbnail, because truncated files still have valid
+// headers.
+func checkThumb(data []byte, width, height uint16) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	b := img.Bounds()
+	return checkDims(b.Dx(), b.Dy(), width, height)
+}
+
+// Variants are WebP, which the standard library can't decode, so check
+// the container size and dimensions only.
+func checkVariant(data []byte, width, height uint16) error {
+	if len(data) < 30 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return errBadWebP
+	}
+	if int64(binary.LittleEndian.Uint32(data[4:]))+8 != int64(len(data)) {
+		return errors.New("truncated WebP")
+	}
+
+	var w, h int
+	switch string(data[12:16]) {
+	case "VP8 ":
+		w = int(binary.LittleEndian.Uint16(data[26:]) & 0x3FFF)
+		h = int(binary.LittleEndian.Uint16(data[28:]) & 0x3FFF)
+	case "VP8L":
+		bits := binary.LittleEndian.Uint32(data[21:])
+		w = int(bits&0x3FFF) + 1
+		h = int(bits>>14&0x3FFF) + 1
+	case "VP8X":
+		w = int(uint32(data[24])|uint32(data[25])<<8|uint32(data[26])<<16) + 1
+		h = int(uint32(data[27])|uint32(data[28])<<8|uint32(data[29])<<16) + 1
+	default:
+		return errBadWebP
+	}
+	return checkDims(w, h, width, height)
+}
+
+func checkDims(w, h int, width, height uint16) error {
+	if w != int(width) || h != int(height) {
+		return fmt.Errorf("dimensions %dx%d, expected %dx%d", w, h, width, height)
+	}
+	return nil
+}
+
+// Regenerate thumbnail of a single file and update its record.
+func regenThumb(
+	backend fileBackend,
+	opts ipc.ThumbOptions,
+	img common.ImageCommon,
+	src, oldThumb []byte,
+) (err error) {
+	tmp, err := ioutil.TempFile("", "cutechan-regen-")
+	if err != nil {
+		return
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(src); err != nil {
+		return
+	}
+
+	thumb, err := ipc.GetThumbnail(opts, tmp)
+	if err != nil {
+		return
+	}
+	if len(thumb.Data) == 0 {
+		// Nothing to regenerate for files without thumbnails.
+		return
+	}
+
+	thumbType := uint8(common.JPEG)
+	if thumb.HasAlpha {
+		thumbType = common.PNG
+	}
+	dims := [4]uint16{thumb.SrcWidth, thumb.SrcHeight, thumb.Width, thumb.Height}
+	if thumbType == img.ThumbType && dims == img.Dims && thumb.PHash == img.PHash &&
+		sameVariants(img.Variants, thumb.Variants) && bytes.Equal(oldThumb, thumb.Data) {
+		// Already up to date.
+		return
+	}
+
+	err = backend.ReplaceThumb(img.SHA1, img.ThumbType, thumbType, thumb.Data)
+	if err != nil {
+		return
+	}
+
+	var variants []common.ThumbVariant
+	written := make(map[string]bool, len(thumb.Variants))
+	for _, v := range thumb.Variants {
+		if err = backend.WriteVariant(img.SHA1, v.Name, v.Data); err != nil {
+			return
+		}
+		variants = append(variants, common.ThumbVariant{
+			Name:   v.Name,
+			Width:  v.Width,
+			Height: v.Height,
+		})
+		written[v.Name] = true
+	}
+	err = db.UpdateImageThumb(img.SHA1, thumbType, dims, variants, thumb.PHash)
+	if err != nil {
+		return
+	}
+
+	// Remove variants no longer produced by the thumbnailer.
+	for _, v := range img.Variants {
+		if !written[v.Name] {
+			if err = backend.DeleteVariant(img.SHA1, v.Name); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Report, if thumbnailer produced the same variants as stored.
+func sameVariants(stored []common.ThumbVariant, produced []ipc.ThumbVariant) bool {
+	if len(stored) != len(produced) {
+		return false
+	}
+	for i, v := range produced {
+		s := stored[i]
+		if s.Name != v.Name || s.Width != v.Width || s.Height != v.Height {
+			return false
+		}
+	}
+	return true
+}
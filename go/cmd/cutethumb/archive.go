@@ -0,0 +1,320 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/cutechan/cutechan/go/ipc"
+
+	"github.com/cutechan/thumbnailer"
+	"github.com/saracen/go7z/headers"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+const (
+	// Listing is stored along with the file record, so keep it small.
+	maxArchiveEntries = 1000
+	maxLenEntryName   = 300
+	// Compressed tarballs have to be unpacked to reach further entries,
+	// so listing stops after this many bytes.
+	maxArchiveUnpacked = 64 << 20
+	// Directory attribute of 7z entries, same as in Windows.
+	sevenZipDirAttr = 0x10
+	// Limit of unpacked 7z headers.
+	maxSevenZipHeader = 16 << 20
+	// Codecs 7z headers are compressed with.
+	sevenZipCopy  = 0x00
+	sevenZipLZMA  = 0x030101
+	sevenZipLZMA2 = 0x21
+)
+
+var (
+	errUnpackLimit   = errors.New("unpack limit reached")
+	errMalformed7z   = errors.New("malformed 7z headers")
+	errUnsupported7z = errors.New("unsupported 7z headers")
+
+	archiveMimeTypes = map[string]bool{
+		"application/zip":             true,
+		"application/x-7z-compressed": true,
+		"application/gzip":            true,
+		"application/x-xz":            true,
+	}
+	archiveSignatures = []struct {
+		mime, ext string
+		sig       []byte
+	}{
+		{"application/zip", "zip", []byte("PK\x03\x04")},
+		// Empty archive.
+		{"application/zip", "zip", []byte("PK\x05\x06")},
+		{"application/x-7z-compressed", "7z", []byte("7z\xBC\xAF\x27\x1C")},
+		// Compressed tarballs, contents are checked on listing.
+		{"application/gzip", "tar.gz", []byte("\x1F\x8B")},
+		{"application/x-xz", "tar.xz", []byte("\xFD7zXZ\x00")},
+	}
+)
+
+func init() {
+	thumbnailer.RegisterMatcher(thumbnailer.MatcherFunc(matchArchive))
+	for mime := range archiveMimeTypes {
+		thumbnailer.RegisterProcessor(mime, processArchive)
+	}
+}
+
+func matchArchive(data []byte) (string, string) {
+	for _, s := range archiveSignatures {
+		if bytes.HasPrefix(data, s.sig) {
+			return s.mime, s.ext
+		}
+	}
+	return "", ""
+}
+
+// Archives don't have thumbnails, client shows generic icon instead.
+func processArchive(src thumbnailer.Source, opts thumbnailer.Options) (
+	thumbnailer.Source, thumbnailer.Thumbnail, error,
+) {
+	return src, thumbnailer.Thumbnail{}, nil
+}
+
+// Collects archive entries up to the limit.
+type archiveListing []ipc.ArchiveEntry
+
+func (l *archiveListing) add(name string, size int64) bool {
+	*l = append(*l, ipc.ArchiveEntry{
+		Name: truncString(name, maxLenEntryName),
+		Size: size,
+	})
+	return len(*l) < maxArchiveEntries
+}
+
+// List files stored in the archive. Directories are skipped.
+func listArchive(mime string, data []byte) (entries []ipc.ArchiveEntry, err error) {
+	var l archiveListing
+	switch mime {
+	case "application/zip":
+		err = listZip(&l, data)
+	case "application/x-7z-compressed":
+		err = list7z(&l, data)
+	case "application/gzip":
+		var r io.Reader
+		r, err = gzip.NewReader(bytes.NewReader(data))
+		if err == nil {
+			err = listTar(&l, &unpackLimiter{r, maxArchiveUnpacked})
+		}
+	case "application/x-xz":
+		var r io.Reader
+		r, err = xz.NewReader(bytes.NewReader(data))
+		if err == nil {
+			err = listTar(&l, &unpackLimiter{r, maxArchiveUnpacked})
+		}
+	}
+	entries = l
+	return
+}
+
+// Like io.LimitedReader, but reports reaching the limit, so it can be told
+// apart from a truncated archive.
+type unpackLimiter struct {
+	r io.Reader
+	n int64
+}
+
+func (l *unpackLimiter) Read(p []byte) (n int, err error) {
+	if l.n <= 0 {
+		return 0, errUnpackLimit
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err = l.r.Read(p)
+	l.n -= int64(n)
+	return
+}
+
+func listZip(l *archiveListing, data []byte) error {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !l.add(f.Name, int64(f.UncompressedSize64)) {
+			break
+		}
+	}
+	return nil
+}
+
+func listTar(l *archiveListing, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err == errUnpackLimit && len(*l) != 0:
+			// Keep what was listed so far.
+			return nil
+		case err != nil:
+			// Also rejects compressed files which are not tarballs.
+			return err
+		}
+		if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if !l.add(h.Name, h.Size) {
+			return nil
+		}
+	}
+}
+
+// Entry sizes are read from 7z headers, so the entries themselves are not
+// unpacked.
+func list7z(l *archiveListing, data []byte) error {
+	header, err := read7zHeader(data)
+	if err != nil {
+		return err
+	}
+
+	// Entries with data consume sizes of unpacked streams in order.
+	var sizes []uint64
+	if si := header.MainStreamsInfo; si != nil {
+		switch {
+		case si.SubStreamsInfo != nil:
+			sizes = si.SubStreamsInfo.UnpackSizes
+		case si.UnpackInfo != nil:
+			for _, f := range si.UnpackInfo.Folders {
+				sizes = append(sizes, f.UnpackSize())
+			}
+		}
+	}
+	for _, h := range header.FilesInfo {
+		var size uint64
+		if !h.IsEmptyStream {
+			if len(sizes) == 0 {
+				return errMalformed7z
+			}
+			size, sizes = sizes[0], sizes[1:]
+		}
+		if h.Attrib&sevenZipDirAttr != 0 || (h.IsEmptyStream && !h.IsEmptyFile) {
+			continue
+		}
+		if !l.add(h.Name, int64(size)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Read 7z headers, unpacking them if needed.
+func read7zHeader(data []byte) (*headers.Header, error) {
+	sig, err := headers.ReadSignatureHeader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	buf, err := slice7z(
+		data,
+		uint64(sig.StartHeader.NextHeaderOffset),
+		uint64(sig.StartHeader.NextHeaderSize),
+	)
+	if err != nil {
+		return nil, err
+	}
+	header, encoded, err := readPacked7zHeader(buf)
+	if err != nil || encoded == nil {
+		return header, err
+	}
+
+	buf, err = unpack7zHeader(data, encoded)
+	if err != nil {
+		return nil, err
+	}
+	header, encoded, err = readPacked7zHeader(buf)
+	switch {
+	case err != nil:
+		return nil, err
+	case encoded != nil:
+		return nil, errUnsupported7z
+	}
+	return header, nil
+}
+
+func readPacked7zHeader(buf []byte) (*headers.Header, *headers.StreamsInfo, error) {
+	r := &io.LimitedReader{R: bytes.NewReader(buf), N: int64(len(buf))}
+	header, encoded, err := headers.ReadPackedStreamsForHeaders(r)
+	if err == nil && header == nil && encoded == nil {
+		err = errMalformed7z
+	}
+	return header, encoded, err
+}
+
+// Return part of the archive at offset from the end of signature header.
+func slice7z(data []byte, off, size uint64) ([]byte, error) {
+	start := uint64(headers.SignatureHeaderSize) + off
+	if start < off || start > uint64(len(data)) || size > uint64(len(data))-start {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data[start : start+size], nil
+}
+
+// Headers are compressed by a single coder, when compressed at all.
+func unpack7zHeader(data []byte, si *headers.StreamsInfo) ([]byte, error) {
+	if si.PackInfo == nil || len(si.PackInfo.PackSizes) == 0 ||
+		si.UnpackInfo == nil || len(si.UnpackInfo.Folders) != 1 {
+		return nil, errUnsupported7z
+	}
+	folder := si.UnpackInfo.Folders[0]
+	if len(folder.CoderInfo) != 1 || len(folder.UnpackSizes) != 1 {
+		return nil, errUnsupported7z
+	}
+	size := folder.UnpackSizes[0]
+	if size > maxSevenZipHeader {
+		return nil, errUnsupported7z
+	}
+	packed, err := slice7z(data, si.PackInfo.PackPos, si.PackInfo.PackSizes[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	coder := folder.CoderInfo[0]
+	switch coder.CodecID {
+	case sevenZipCopy:
+		r = bytes.NewReader(packed)
+	case sevenZipLZMA:
+		// Decoder expects properties followed by unpacked size.
+		head := make([]byte, len(coder.Properties)+8)
+		copy(head, coder.Properties)
+		binary.LittleEndian.PutUint64(head[len(coder.Properties):], size)
+		r, err = lzma.NewReader(io.MultiReader(
+			bytes.NewReader(head),
+			bytes.NewReader(packed),
+		))
+	case sevenZipLZMA2:
+		var conf lzma.Reader2Config
+		if len(coder.Properties) != 0 {
+			p := coder.Properties[0]
+			conf.DictCap = int(2|(p&1)) << (p/2 + 11)
+		}
+		r, err = conf.NewReader2(bytes.NewReader(packed))
+	default:
+		return nil, errUnsupported7z
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/ipc"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+type testEntry struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func makeZip(t *testing.T, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		name := e.name
+		if e.dir {
+			name += "/"
+		}
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(make([]byte, e.size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeTarGz(t *testing.T, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gw)
+	for _, e := range entries {
+		h := &tar.Header{
+			Name:     e.name,
+			Size:     e.size,
+			Mode:     0644,
+			Typeflag: tar.TypeReg,
+		}
+		if e.dir {
+			h.Size = 0
+			h.Mode = 0755
+			h.Typeflag = tar.TypeDir
+		}
+		if err := w.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.CopyN(w, zeroReader{}, h.Size); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeGzip(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestListArchive(t *testing.T) {
+	files := []testEntry{
+		{name: "dir", dir: true},
+		{name: "dir/a.txt", size: 10},
+		{name: "empty"},
+		{name: "b.txt", size: 3},
+	}
+	listed := []ipc.ArchiveEntry{
+		{Name: "dir/a.txt", Size: 10},
+		{Name: "empty", Size: 0},
+		{Name: "b.txt", Size: 3},
+	}
+	huge := []testEntry{
+		{name: "a.txt", size: 1},
+		{name: "huge.bin", size: maxArchiveUnpacked},
+		{name: "b.txt", size: 1},
+	}
+
+	cases := [...]struct {
+		name, mime string
+		data       []byte
+		entries    []ipc.ArchiveEntry
+		err        bool
+	}{
+		{
+			name:    "zip with directories",
+			mime:    "application/zip",
+			data:    makeZip(t, files),
+			entries: listed,
+		},
+		{
+			name: "7z with compressed headers and empty files",
+			mime: "application/x-7z-compressed",
+			data: ReadSample(t, "sample.7z"),
+			entries: []ipc.ArchiveEntry{
+				{Name: "a.txt", Size: 6},
+				{Name: "dir/b.txt", Size: 7},
+				{Name: "empty", Size: 0},
+			},
+		},
+		{
+			name:    "tar.gz",
+			mime:    "application/gzip",
+			data:    makeTarGz(t, files),
+			entries: listed,
+		},
+		{
+			name: "tar.gz over unpack limit",
+			mime: "application/gzip",
+			data: makeTarGz(t, huge),
+			entries: []ipc.ArchiveEntry{
+				{Name: "a.txt", Size: 1},
+				{Name: "huge.bin", Size: maxArchiveUnpacked},
+			},
+		},
+		{
+			name: "gzip, which is not a tar",
+			mime: "application/gzip",
+			data: makeGzip(t, []byte(strings.Repeat("not a tarball\n", 100))),
+			err:  true,
+		},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			entries, err := listArchive(c.mime, c.data)
+			switch {
+			case c.err && err == nil:
+				t.Fatal("expected error")
+			case !c.err && err != nil:
+				t.Fatal(err)
+			}
+			if !c.err {
+				AssertDeepEquals(t, entries, c.entries)
+			}
+		})
+	}
+}
+
+func TestListArchiveEntryLimit(t *testing.T) {
+	entries := make([]testEntry, maxArchiveEntries+1)
+	for i := range entries {
+		entries[i] = testEntry{name: strings.Repeat("a", i%maxLenEntryName+1)}
+	}
+	listed, err := listArchive("application/zip", makeZip(t, entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(listed), maxArchiveEntries)
+}
+
+func TestUnpackLimiter(t *testing.T) {
+	cases := [...]struct {
+		name     string
+		data     string
+		limit    int64
+		read     string
+		limitHit bool
+	}{
+		{"under limit", "abc", 5, "abc", false},
+		{"at limit", "abc", 3, "abc", true},
+		{"over limit", "abcdef", 3, "abc", true},
+		{"zero limit", "abc", 0, "", true},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			r := &unpackLimiter{strings.NewReader(c.data), c.limit}
+			buf, err := ioutil.ReadAll(r)
+			switch {
+			case c.limitHit && err != errUnpackLimit:
+				LogUnexpected(t, errUnpackLimit, err)
+			case !c.limitHit && err != nil:
+				t.Fatal(err)
+			}
+			AssertDeepEquals(t, string(buf), c.read)
+		})
+	}
+}
@@ -3,13 +3,20 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io/ioutil"
 	"log"
 	"os"
+	"syscall"
+	"time"
 
 	"github.com/cutechan/cutechan/go/ipc"
 
+	"github.com/chai2010/webp"
 	"github.com/cutechan/thumbnailer"
 )
 
@@ -18,7 +25,10 @@ const (
 	maxHeight       = 10000
 	thumbSize       = 200
 	jpegQuality     = 90
+	webpQuality     = 80
 	maxLenFileTitle = 300
+	// Same as images.Artist column.
+	maxLenFileArtist = 100
 )
 
 var (
@@ -29,6 +39,22 @@ var (
 		"video/webm": true,
 		"video/mp4":  true,
 		"audio/mpeg": true,
+		// Allowed per board, see config.IsAllowedFileType.
+		"application/pdf":             true,
+		"application/ogg":             true,
+		"application/zip":             true,
+		"application/x-7z-compressed": true,
+		"application/gzip":            true,
+		"application/x-xz":            true,
+	}
+	// Additional WebP thumbnails. Small one is for catalog and regular
+	// posts, large one for mobile and high-density screens.
+	thumbVariants = [...]struct {
+		name string
+		size uint
+	}{
+		{"small", thumbSize},
+		{"large", thumbSize * 2},
 	}
 )
 
@@ -40,21 +66,56 @@ func truncString(s string, max int) string {
 	}
 }
 
-func getThumbnail(srcData []byte) (ithumb *ipc.Thumb, err error) {
-	opts := thumbnailer.Options{
+func getOptions(size uint, quality uint8) thumbnailer.Options {
+	return thumbnailer.Options{
 		MaxSourceDims: thumbnailer.Dims{
 			Width:  maxWidth,
 			Height: maxHeight,
 		},
 		ThumbDims: thumbnailer.Dims{
-			Width:  thumbSize,
-			Height: thumbSize,
+			Width:  size,
+			Height: size,
 		},
-		JPEGQuality:       jpegQuality,
+		JPEGQuality:       quality,
 		AcceptedMimeTypes: allowedMimeTypes,
 	}
+}
 
-	src, thumb, err := thumbnailer.ProcessBuffer(srcData, opts)
+// Make thumbnail of the given size and re-encode it to WebP.
+func getVariant(srcData []byte, name string, size uint) (v ipc.ThumbVariant, err error) {
+	// Intermediate JPEG is only decoded, so use max quality to avoid
+	// generation loss.
+	_, thumb, err := thumbnailer.ProcessBuffer(srcData, getOptions(size, 100))
+	if err != nil {
+		return
+	}
+	img, err := decodeThumb(thumb)
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	err = webp.Encode(&buf, img, &webp.Options{Quality: webpQuality})
+	if err != nil {
+		return
+	}
+	v = ipc.ThumbVariant{
+		Name:   name,
+		Width:  uint16(thumb.Width),
+		Height: uint16(thumb.Height),
+		Data:   buf.Bytes(),
+	}
+	return
+}
+
+func decodeThumb(thumb thumbnailer.Thumbnail) (image.Image, error) {
+	if thumb.IsPNG {
+		return png.Decode(bytes.NewReader(thumb.Data))
+	}
+	return jpeg.Decode(bytes.NewReader(thumb.Data))
+}
+
+func getThumbnail(srcData []byte) (ithumb *ipc.Thumb, err error) {
+	src, thumb, err := thumbnailer.ProcessBuffer(srcData, getOptions(thumbSize, jpegQuality))
 	switch err {
 	case nil:
 		// Do nothing.
@@ -76,10 +137,12 @@ func getThumbnail(srcData []byte) (ithumb *ipc.Thumb, err error) {
 		return
 	}
 
-	isMp3 := src.Mime == "audio/mpeg"
+	isArchive := archiveMimeTypes[src.Mime]
+	isRecord := src.Mime == "audio/mpeg" ||
+		(src.Mime == "application/ogg" && src.HasAudio && !src.HasVideo)
 
-	// Thumbnail is skipped only for MP3.
-	if isMp3 {
+	// Thumbnail is skipped only for audio records and archives.
+	if isRecord || isArchive {
 		thumb.Data = nil
 	} else if thumb.Data == nil {
 		log.Printf("thumbnailer error: no data")
@@ -87,14 +150,24 @@ func getThumbnail(srcData []byte) (ithumb *ipc.Thumb, err error) {
 		return
 	}
 
-	// Allow only MP3 audios currently.
+	// Allow only MP3 and OGG audios currently.
 	if src.HasAudio {
-		if !src.HasVideo && !isMp3 {
+		if !src.HasVideo && !isRecord {
 			err = ipc.ErrThumbTracks
 			return
 		}
 	}
 
+	var contents []ipc.ArchiveEntry
+	if isArchive {
+		contents, err = listArchive(src.Mime, srcData)
+		if err != nil {
+			log.Printf("thumbnailer error: bad archive: %v", err)
+			err = ipc.ErrThumbProcess
+			return
+		}
+	}
+
 	ithumb = &ipc.Thumb{
 		HasVideo:  src.HasVideo,
 		HasAudio:  src.HasAudio,
@@ -106,12 +179,72 @@ func getThumbnail(srcData []byte) (ithumb *ipc.Thumb, err error) {
 		Height:    uint16(thumb.Height),
 		Duration:  uint32(src.Length.Seconds() + 0.5),
 		Title:     truncString(src.Title, maxLenFileTitle),
+		Artist:    truncString(src.Artist, maxLenFileArtist),
 		Data:      thumb.Data,
+		Contents:  contents,
+	}
+	if thumb.Data == nil {
+		return
+	}
+	img, err := decodeThumb(thumb)
+	if err != nil {
+		log.Printf("thumbnailer error: bad thumbnail: %v", err)
+		err = ipc.ErrThumbProcess
+		return
+	}
+	ithumb.PHash = dHash(img)
+	for _, tv := range thumbVariants {
+		var v ipc.ThumbVariant
+		v, err = getVariant(srcData, tv.name, tv.size)
+		if err != nil {
+			log.Printf("thumbnailer error: %s variant: %v", tv.name, err)
+			err = ipc.ErrThumbProcess
+			return
+		}
+		ithumb.Variants = append(ithumb.Variants, v)
 	}
 	return
 }
 
+// Apply resource limits passed by the server to the current process.
+func applyLimits(opts ipc.ThumbOptions) error {
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, uint64(opts.MaxCPU.Seconds() + 0.5)},
+		{syscall.RLIMIT_AS, opts.MaxMemory},
+		{syscall.RLIMIT_FSIZE, opts.MaxFileSize},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: l.value, Max: l.value})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func main() {
+	opts, err := ipc.ParseThumbArgs(os.Args[1:])
+	if err != nil {
+		os.Exit(1)
+	}
+	if err := applyLimits(opts); err != nil {
+		log.Printf("thumbnailer error: %v", err)
+		os.Exit(1)
+	}
+	// Server might be unable to kill us, so terminate on our own.
+	if opts.Timeout > 0 {
+		time.AfterFunc(opts.Timeout, func() {
+			fmt.Print(ipc.ErrThumbTimeout.Error())
+			os.Exit(ipc.THUMB_ERROR_EXIT_CODE)
+		})
+	}
+
 	srcData, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		fmt.Print(err.Error())
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+const (
+	// Hash is built from 9x8 grayscale image, each bit tells if pixel is
+	// brighter than its right neighbour.
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// Difference hash of the image. Survives re-encoding, resizing and minor
+// edits, so near-duplicates have hashes with small Hamming distance. See
+// http://www.hackerfactor.com/blog/index.php?/archives/529-Kind-of-Like-That.html
+func dHash(img image.Image) (hash uint64) {
+	var cells [dHashHeight][dHashWidth]uint64
+	var counts [dHashHeight][dHashWidth]uint64
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return
+	}
+	// Box downscale, images are small thumbnails anyway.
+	for y := 0; y < h; y++ {
+		cy := y * dHashHeight / h
+		for x := 0; x < w; x++ {
+			cx := x * dHashWidth / w
+			c := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			cells[cy][cx] += uint64(c.Y)
+			counts[cy][cx]++
+		}
+	}
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth; x++ {
+			if counts[y][x] != 0 {
+				cells[y][x] /= counts[y][x]
+			}
+		}
+	}
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return
+}
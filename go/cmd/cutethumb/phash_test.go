@@ -0,0 +1,74 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+	"testing"
+)
+
+// Grayscale image getting darker from left to right, with optional noise.
+func gradient(w, h int, noise bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := 255 - x*255/w
+			if noise && (x*7+y*13)%5 == 0 {
+				v -= 4
+			}
+			if v < 0 {
+				v = 0
+			}
+			img.SetGray(x, y, color.Gray{uint8(v)})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	cases := [...]struct {
+		name string
+		img  image.Image
+		hash uint64
+	}{
+		{"empty", image.NewGray(image.Rect(0, 0, 0, 0)), 0},
+		{"flat", image.NewGray(image.Rect(0, 0, 32, 32)), 0},
+		{"gradient", gradient(90, 80, false), ^uint64(0)},
+		{"mirrored", mirror(gradient(90, 80, false)), 0},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			if h := dHash(c.img); h != c.hash {
+				t.Fatalf("unexpected hash: %016x", h)
+			}
+		})
+	}
+}
+
+func mirror(src image.Image) image.Image {
+	b := src.Bounds()
+	img := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.Set(b.Max.X-1-x+b.Min.X, y, src.At(x, y))
+		}
+	}
+	return img
+}
+
+// Resized and slightly altered copies should stay close.
+func TestDHashNearDuplicates(t *testing.T) {
+	orig := dHash(gradient(360, 320, false))
+	for _, img := range [...]image.Image{
+		gradient(180, 160, false),
+		gradient(720, 640, false),
+		gradient(360, 320, true),
+	} {
+		if d := bits.OnesCount64(orig ^ dHash(img)); d >= 8 {
+			t.Fatalf("distance too large: %d", d)
+		}
+	}
+}
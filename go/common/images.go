@@ -18,6 +18,7 @@ const (
 	SevenZip
 	TGZ
 	TXZ
+	WEBP
 )
 
 // Extensions maps internal file types to their canonical file
@@ -35,11 +36,36 @@ var Extensions = map[uint8]string{
 	SevenZip: "7z",
 	TGZ:      "tar.gz",
 	TXZ:      "tar.xz",
+	WEBP:     "webp",
+}
+
+// ExtraFileTypes are only accepted on boards, which explicitly allow them.
+var ExtraFileTypes = map[uint8]bool{
+	PDF:      true,
+	OGG:      true,
+	ZIP:      true,
+	SevenZip: true,
+	TGZ:      true,
+	TXZ:      true,
+}
+
+// IsArchive returns, if file type is an archive.
+func IsArchive(typ uint8) bool {
+	switch typ {
+	case ZIP, SevenZip, TGZ, TXZ:
+		return true
+	}
+	return false
 }
 
 // Image contains a post's image and thumbnail data.
 type Image struct {
 	ImageCommon
+	// Metadata stripped from this particular upload. Only shown to
+	// moderators.
+	Metadata *MetadataSummary `json:"-"`
+	// Thumbnail is hidden by moderators.
+	Spoiler bool `json:"spoiler,omitempty"`
 }
 
 // ImageCommon contains the common data shared between multiple post
@@ -55,6 +81,46 @@ type ImageCommon struct {
 	Length    uint32    `json:"length,omitempty"`
 	Title     string    `json:"title,omitempty"`
 	Dims      [4]uint16 `json:"dims"`
-	MD5       string    `json:"-"`
-	Artist    string    `json:"-"`
+	MD5       string    `json:"md5"`
+	Artist    string    `json:"artist,omitempty"`
+	// Additional thumbnail sizes, if any.
+	Variants []ThumbVariant `json:"variants,omitempty"`
+	// Listing of archive files.
+	Contents []ArchiveEntry `json:"contents,omitempty"`
+	// Perceptual hash of the thumbnail, zero if there is none.
+	PHash uint64 `json:"-"`
+}
+
+// ArchiveEntry is a single file stored in the archive.
+type ArchiveEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// ThumbVariant describes named thumbnail variant. Variants are always
+// stored as WebP.
+type ThumbVariant struct {
+	Name   string `json:"name"`
+	Width  uint16 `json:"width"`
+	Height uint16 `json:"height"`
+}
+
+// BlockedImage is an entry of perceptual hash blocklist.
+type BlockedImage struct {
+	// Hex-encoded hash.
+	PHash   string `json:"phash"`
+	SHA1    string `json:"sha1"`
+	By      string `json:"by"`
+	Created int64  `json:"created"`
+}
+
+// MetadataSummary describes metadata stripped from uploaded file.
+type MetadataSummary struct {
+	// Kinds of removed metadata, e.g. "EXIF" or "XMP".
+	Removed  []string `json:"removed"`
+	Make     string   `json:"make,omitempty"`
+	Model    string   `json:"model,omitempty"`
+	Software string   `json:"software,omitempty"`
+	DateTime string   `json:"dateTime,omitempty"`
+	GPS      bool     `json:"gps,omitempty"`
 }
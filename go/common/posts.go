@@ -28,6 +28,8 @@ func (b Board) Less(i, j int) bool {
 type Thread struct {
 	Abbrev    bool   `json:"abbrev,omitempty"`
 	Sticky    bool   `json:"sticky,omitempty"`
+	Archived  bool   `json:"archived,omitempty"`
+	Locked    bool   `json:"locked,omitempty"`
 	PostCtr   uint32 `json:"postCtr"`
 	ImageCtr  uint32 `json:"imageCtr"`
 	ReplyTime int64  `json:"replyTime"`
@@ -40,6 +42,8 @@ type Thread struct {
 
 // Post is a generic post exposed publically through the JSON API.
 type Post struct {
+	Editing  bool     `json:"editing,omitempty"`
+	Deleted  bool     `json:"deleted,omitempty"`
 	ID       uint64   `json:"id"`
 	Time     int64    `json:"time"`
 	Auth     string   `json:"auth,omitempty"`
@@ -60,6 +64,21 @@ type StandalonePost struct {
 	Board string `json:"board"`
 }
 
+// PostReference locates a post with unknown parenthood.
+type PostReference struct {
+	ID    uint64 `json:"id"`
+	Time  int64  `json:"time"`
+	OP    uint64 `json:"op"`
+	Board string `json:"board"`
+}
+
+// SearchResult is a post matching a search query along with the subject
+// of its thread.
+type SearchResult struct {
+	StandalonePost
+	Subject string `json:"subject"`
+}
+
 // Posts.
 type Posts []*Post
 
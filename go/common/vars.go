@@ -12,9 +12,15 @@ const (
 	MaxLenBoardID      = 10
 	MaxLenBoardTitle   = 100
 	MaxBanReasonLength = 100
+	MaxLenReportReason = 100
+	MaxLenAppeal       = 500
+	MaxLenNotification = 1000
 	MaxLenIgnoreList   = 100
 	MaxLenStaffList    = 1000
 	MaxLenBansList     = 1000
+	MaxThreadsLimit    = 10000
+	MaxBumpLimit       = 10000
+	MaxCooldown        = 3600 // Seconds
 )
 
 // Various cryptographic token exact lengths
@@ -25,14 +31,23 @@ const (
 
 // Some default options.
 const (
-	SessionExpiry        = 5 * 365 // Days
-	DefaultMaxSize       = 40      // Megabytes
-	DefaultMaxFiles      = 5
-	DefaultCSS           = "light"
-	DefaultAdminPassword = "password"
-	ThreadsPerPage       = 20
-	NumPostsAtIndex      = 3
-	NumPostsOnRequest    = 100
+	SessionExpiry           = 5 * 365 // Days
+	DefaultMaxSize          = 40      // Megabytes
+	DefaultMaxFiles         = 5
+	DefaultBumpLimit        = 500
+	DefaultDeletedRetention = 7 // Days
+	DefaultPHashThreshold   = 8 // Bits
+	DefaultCSS              = "light"
+	DefaultAdminPassword    = "password"
+	ThreadsPerPage          = 20
+	NumPostsAtIndex         = 3
+	NumPostsOnRequest       = 100
+)
+
+// Default posting cooldowns in seconds.
+const (
+	DefaultThreadCooldown = 60
+	DefaultPostCooldown   = 1
 )
 
 // Available themes. Change this, when adding any new ones.
@@ -60,8 +60,9 @@ const (
 
 // Forwarded functions from "meguca/feeds" to avoid circular imports
 var (
-	// GetByIPAndBoard retrieves all Clients that match the passed IP on a board
-	GetByIPAndBoard func(ip, board string) []Client
+	// GetByNetAndBoard retrieves all Clients with IPs inside the passed
+	// network on a board
+	GetByNetAndBoard func(network, board string) []Client
 
 	// SendTo sends a message to a feed, if it exists
 	SendTo func(id uint64, msg []byte)
@@ -80,14 +81,54 @@ var (
 
 	// Propagate a message about an image being spoilered
 	SpoilerImage func(id, op uint64) error
+
+	// Redirect clients of a thread moved to another board
+	MoveThread func(op uint64, board string) error
+
+	// Notify logged in authors of the posts linked by a closed post
+	NotifyReplies func(id, op uint64, board, account string, links Links)
+)
+
+// Forwarded functions from "cutechan/parser" to avoid circular imports
+var (
+	// ParseBody extracts links and commands from a post body
+	ParseBody func(body []byte) (Links, Commands, error)
 )
 
+// SpliceMessage replaces Len characters of an open post body starting at
+// Start with Text. Offsets are in Unicode code points.
+type SpliceMessage struct {
+	ID    uint64 `json:"id"`
+	Start uint   `json:"start"`
+	Len   uint   `json:"len"`
+	Text  string `json:"text"`
+}
+
+// CloseMessage is sent to clients, when an open post is closed. Carries
+// the links and commands parsed from the final post body.
+type CloseMessage struct {
+	ID       uint64   `json:"id"`
+	Links    Links    `json:"links,omitempty"`
+	Commands Commands `json:"commands,omitempty"`
+}
+
+// ReplyNotification is sent to the logged in author of a post, when
+// another post links to it.
+type ReplyNotification struct {
+	Type   string `json:"type"`
+	ID     uint64 `json:"id"`
+	OP     uint64 `json:"op"`
+	Board  string `json:"board"`
+	Target uint64 `json:"target"`
+}
+
 // Client exposes some globally accessible websocket client functionality
 // without causing circular imports
 type Client interface {
 	Send([]byte)
 	Redirect(board string)
 	IP() string
+	Account() string
 	Close(error)
 }
 
@@ -6,6 +6,7 @@ package config
 import (
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/cutechan/cutechan/go/common"
 )
@@ -30,6 +31,8 @@ var (
 			MaxFiles:   common.DefaultMaxFiles,
 			DefaultCSS: common.DefaultCSS,
 		},
+		DeletedRetention: common.DefaultDeletedRetention,
+		PHashThreshold:   common.DefaultPHashThreshold,
 	}
 )
 
@@ -155,9 +158,78 @@ func IsReadOnlyBoard(b string) bool {
 	return ok && conf.ReadOnly
 }
 
+func IsLiveEditingBoard(b string) bool {
+	boardMu.RLock()
+	defer boardMu.RUnlock()
+	conf, ok := boardConfigs[b]
+	return ok && conf.LiveEditing
+}
+
 func IsModOnlyBoard(b string) bool {
 	boardMu.RLock()
 	defer boardMu.RUnlock()
 	conf, ok := boardConfigs[b]
 	return ok && conf.ModOnly
 }
+
+// GetBumpLimit returns the number of posts, after which threads on the board
+// are no longer bumped.
+func GetBumpLimit(b string) int {
+	if n := GetBoardConfig(b).BumpLimit; n > 0 {
+		return n
+	}
+	return common.DefaultBumpLimit
+}
+
+// GetMaxFiles returns the maximum number of files per post on the board.
+func GetMaxFiles(b string) int {
+	if n := GetBoardConfig(b).MaxFiles; n > 0 {
+		return n
+	}
+	return Get().MaxFiles
+}
+
+// GetMaxSize returns the maximum file size in megabytes on the board.
+func GetMaxSize(b string) int64 {
+	if n := GetBoardConfig(b).MaxSize; n > 0 {
+		return n
+	}
+	return Get().MaxSize
+}
+
+// GetThreadCooldown returns the minimal interval between thread creations
+// from the same IP on the board.
+func GetThreadCooldown(b string) time.Duration {
+	if n := GetBoardConfig(b).ThreadCooldown; n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return common.DefaultThreadCooldown * time.Second
+}
+
+// GetPostCooldown returns the minimal interval between posts from the same
+// IP on the board.
+func GetPostCooldown(b string) time.Duration {
+	if n := GetBoardConfig(b).PostCooldown; n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return common.DefaultPostCooldown * time.Second
+}
+
+// IsAllowedFileType returns, if files of the type can be posted on the board.
+func IsAllowedFileType(b string, typ uint8) bool {
+	conf := GetBoardConfig(b)
+	if common.ExtraFileTypes[typ] && !conf.ExtraFileTypes {
+		return false
+	}
+	types := conf.AllowedTypes
+	if len(types) == 0 {
+		return true
+	}
+	ext := common.Extensions[typ]
+	for _, t := range types {
+		if t == ext {
+			return true
+		}
+	}
+	return false
+}
@@ -1,22 +1,35 @@
 package config
 
 import (
-	"bytes"
 	"testing"
+	"time"
 
+	"github.com/cutechan/cutechan/go/common"
 	. "github.com/cutechan/cutechan/go/test"
 )
 
-func TestGetAllBoard(t *testing.T) {
-	t.Parallel()
-	AssertDeepEquals(t, GetBoardConfigs("all"), AllBoardConfigs)
+func clearBoards() {
+	boardMu.Lock()
+	defer boardMu.Unlock()
+	boardConfigs = map[string]BoardConfig{}
+}
+
+func setDefaults(t *testing.T) {
+	if err := Set(DefaultServerConfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setBoard(t *testing.T, conf BoardConfig) {
+	if err := SetBoardConfig(conf); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestSetGet(t *testing.T) {
-	Clear()
-	conf := Configs{
-		Public: Public{
-			Captcha: true,
+	conf := ServerConfig{
+		ServerPublic: ServerPublic{
+			MaxSize: 10,
 		},
 	}
 
@@ -24,159 +37,152 @@ func TestSetGet(t *testing.T) {
 		t.Fatal(err)
 	}
 	AssertDeepEquals(t, Get(), &conf)
-
-	json, hash := GetClient()
-	if json == nil {
+	if GetJSON() == nil {
 		t.Fatal("client json not set")
 	}
-	if hash == "" {
-		t.Fatal("hash not set")
-	}
-}
-
-func TestSetGetClient(t *testing.T) {
-	Clear()
-	std := []byte{1, 2, 3}
-	hash := "foo"
-	SetClient(std, hash)
-
-	json, jsonHash := GetClient()
-	if !bytes.Equal(json, std) {
-		LogUnexpected(t, std, json)
-	}
-	if jsonHash != hash {
-		LogUnexpected(t, hash, jsonHash)
-	}
 }
 
-func TestGetBoards(t *testing.T) {
-	ClearBoards()
-
-	_, err := SetBoardConfigs(BoardConfigs{
-		ID: "a",
+func TestGetBoardIDs(t *testing.T) {
+	clearBoards()
+	setBoard(t, BoardConfig{BoardPublic: BoardPublic{ID: "all"}})
+	setBoard(t, BoardConfig{BoardPublic: BoardPublic{ID: "b"}})
+	setBoard(t, BoardConfig{BoardPublic: BoardPublic{ID: "a"}})
+	setBoard(t, BoardConfig{
+		BoardPublic: BoardPublic{ID: "mod"},
+		ModOnly:     true,
 	})
-	if err != nil {
-		t.Fatal(err)
-	}
 
-	AssertDeepEquals(t, GetBoards(), []string{"a"})
+	AssertDeepEquals(t, GetBoardIDs(), []string{"a", "b"})
+	AssertDeepEquals(t, GetAllBoardIDs(), []string{"a", "all", "b", "mod"})
 }
 
-func TestSetGetAddRemoveBoardConfigs(t *testing.T) {
-	ClearBoards()
-	std := BoardConfigs{
-		ID: "a",
+func TestSetGetRemoveBoardConfig(t *testing.T) {
+	clearBoards()
+	std := BoardConfig{
 		BoardPublic: BoardPublic{
+			ID:    "a",
 			Title: "123",
 		},
 	}
+	setBoard(t, std)
 
-	changed, err := SetBoardConfigs(std)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if !changed {
-		t.Fatal("configs not changed")
-	}
-
-	conf := GetBoardConfigs("a")
-	if conf.Hash == "" {
-		t.Fatal("no hash generated")
-	}
-	if conf.JSON == nil {
+	conf := GetBoardConfig("a")
+	if conf.json == nil {
 		t.Fatal("no JSON generated")
 	}
-	AssertDeepEquals(t, conf.BoardConfigs, std)
+	conf.json = nil
+	AssertDeepEquals(t, conf, std)
 	if !IsBoard("a") {
 		t.Fatal("board does not exist")
 	}
-	AssertDeepEquals(t, len(GetAllBoardConfigs()), 1)
 
 	RemoveBoard("a")
-	AssertDeepEquals(t, GetBoardConfigs("a"), BoardConfContainer{})
+	AssertDeepEquals(t, GetBoardConfig("a"), BoardConfig{})
 	if IsBoard("a") {
 		t.Fatal("board not deleted")
 	}
 }
 
-func TestSetMatchingBoardConfigs(t *testing.T) {
-	ClearBoards()
-
-	conf := BoardConfigs{
-		ID: "a",
+func TestBoardLimits(t *testing.T) {
+	clearBoards()
+	setDefaults(t)
+	setBoard(t, BoardConfig{BoardPublic: BoardPublic{ID: "a"}})
+	setBoard(t, BoardConfig{
 		BoardPublic: BoardPublic{
-			Title: "123",
+			ID:             "b",
+			BumpLimit:      10,
+			MaxFiles:       1,
+			MaxSize:        2,
+			ThreadCooldown: 3,
+			PostCooldown:   4,
 		},
-	}
-
-	for i := 0; i < 2; i++ {
-		changed, err := SetBoardConfigs(conf)
-		if err != nil {
-			t.Fatal(err)
-		}
-		expected := i == 0
-		if changed != expected {
-			LogUnexpected(t, expected, changed)
-		}
-	}
-}
-
-func TestSetDifferentBoardConfigs(t *testing.T) {
-	ClearBoards()
+	})
 
-	conf := BoardConfigs{
-		ID: "a",
-		BoardPublic: BoardPublic{
-			Title: "123",
+	cases := [...]struct {
+		name, board string
+		bumpLimit   int
+		maxFiles    int
+		maxSize     int64
+		thread      time.Duration
+		post        time.Duration
+	}{
+		{
+			"defaults", "a",
+			common.DefaultBumpLimit, common.DefaultMaxFiles,
+			common.DefaultMaxSize,
+			common.DefaultThreadCooldown * time.Second,
+			common.DefaultPostCooldown * time.Second,
 		},
-	}
-
-	testBoardConfChange(t, conf)
-	conf.Title = "foo"
-	testBoardConfChange(t, conf)
-}
-
-func testBoardConfChange(t *testing.T, conf BoardConfigs) {
-	changed, err := SetBoardConfigs(conf)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if !changed {
-		t.Fatal("expected change")
-	}
-}
-
-func TestGetBoardTitles(t *testing.T) {
-	ClearBoards()
-
-	conf := [...]BoardConfigs{
 		{
-			ID: "g",
-			BoardPublic: BoardPublic{
-				Title: "Techloligy",
-			},
+			"unknown board", "nope",
+			common.DefaultBumpLimit, common.DefaultMaxFiles,
+			common.DefaultMaxSize,
+			common.DefaultThreadCooldown * time.Second,
+			common.DefaultPostCooldown * time.Second,
 		},
 		{
-			ID: "a",
-			BoardPublic: BoardPublic{
-				Title: "Animu & Mango",
-			},
+			"own limits", "b",
+			10, 1, 2, 3 * time.Second, 4 * time.Second,
 		},
 	}
-	for _, c := range conf {
-		if _, err := SetBoardConfigs(c); err != nil {
-			t.Fatal(err)
-		}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			AssertDeepEquals(t, GetBumpLimit(c.board), c.bumpLimit)
+			AssertDeepEquals(t, GetMaxFiles(c.board), c.maxFiles)
+			AssertDeepEquals(t, GetMaxSize(c.board), c.maxSize)
+			AssertDeepEquals(t, GetThreadCooldown(c.board), c.thread)
+			AssertDeepEquals(t, GetPostCooldown(c.board), c.post)
+		})
 	}
+}
 
-	AssertDeepEquals(t, GetBoardTitles(), BoardTitles{
-		{
-			ID:    "a",
-			Title: "Animu & Mango",
+func TestIsAllowedFileType(t *testing.T) {
+	clearBoards()
+	setBoard(t, BoardConfig{BoardPublic: BoardPublic{ID: "a"}})
+	setBoard(t, BoardConfig{
+		BoardPublic: BoardPublic{
+			ID:             "extra",
+			ExtraFileTypes: true,
 		},
-		{
-			ID:    "g",
-			Title: "Techloligy",
+	})
+	setBoard(t, BoardConfig{
+		BoardPublic: BoardPublic{
+			ID:           "png",
+			AllowedTypes: []string{"png", "zip"},
 		},
 	})
+	setBoard(t, BoardConfig{
+		BoardPublic: BoardPublic{
+			ID:             "zip",
+			AllowedTypes:   []string{"png", "zip"},
+			ExtraFileTypes: true,
+		},
+	})
+
+	cases := [...]struct {
+		name, board string
+		typ         uint8
+		allowed     bool
+	}{
+		{"default image", "a", common.JPEG, true},
+		{"default extra type", "a", common.ZIP, false},
+		{"extra types enabled", "extra", common.ZIP, true},
+		{"in allowed types", "png", common.PNG, true},
+		{"not in allowed types", "png", common.JPEG, false},
+		{"allowed extra type disabled", "png", common.ZIP, false},
+		{"allowed extra type enabled", "zip", common.ZIP, true},
+		{"extra type not in allowed types", "zip", common.PDF, false},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			allowed := IsAllowedFileType(c.board, c.typ)
+			if allowed != c.allowed {
+				LogUnexpected(t, c.allowed, allowed)
+			}
+		})
+	}
 }
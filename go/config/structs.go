@@ -2,8 +2,17 @@
 
 package config
 
+//easyjson:json
 type ServerConfig struct {
 	ServerPublic
+	// Keep summary of metadata stripped from uploads for moderators.
+	MetadataSummary bool `json:"metadataSummary,omitempty"`
+	// Days to keep deleted posts, so moderators can restore them. Zero
+	// keeps them forever.
+	DeletedRetention int `json:"deletedRetention,omitempty"`
+	// Uploads differing from a blocked image in less bits of perceptual
+	// hash are rejected. Zero falls back to the default.
+	PHashThreshold int `json:"phashThreshold,omitempty"`
 }
 
 //easyjson:json
@@ -31,6 +40,9 @@ type BoardConfig struct {
 	ModOnly     bool       `json:"modOnly,omitempty"`
 	AccessMode  AccessMode `json:"accessMode,omitempty"`
 	IncludeAnon bool       `json:"includeAnon,omitempty"`
+	// Maximum number of live threads. Threads over the limit are moved to
+	// the archive. Zero means no limit.
+	MaxThreads int `json:"maxThreads,omitempty"`
 	// Pregenerated public JSON.
 	json []byte
 }
@@ -40,6 +52,18 @@ type BoardPublic struct {
 	ID       string `json:"id"`
 	Title    string `json:"title"`
 	ReadOnly bool   `json:"readOnly,omitempty"`
+	// Allow posting in real time over websockets.
+	LiveEditing bool `json:"liveEditing,omitempty"`
+	// Board limits. Zero values fall back to server-wide defaults.
+	BumpLimit      int   `json:"bumpLimit,omitempty"`
+	MaxFiles       int   `json:"maxFiles,omitempty"`
+	MaxSize        int64 `json:"maxSize,omitempty"`
+	ThreadCooldown int   `json:"threadCooldown,omitempty"`
+	PostCooldown   int   `json:"postCooldown,omitempty"`
+	// Extensions of allowed file types. Empty means all types.
+	AllowedTypes []string `json:"allowedTypes,omitempty"`
+	// Accept PDF, OGG and archives in addition to images, video and MP3.
+	ExtraFileTypes bool `json:"extraFileTypes,omitempty"`
 }
 
 // Implements sort.Interface
@@ -19,9 +19,15 @@ func GetIP(id uint64) (string, error) {
 	return ip.String, err
 }
 
-// Ban IPs from accessing a specific board. Need to target posts. Returns all
-// banned IPs.
-func Ban(board, reason, by string, expires time.Time, ids ...uint64) (
+// Ban IPs from accessing a specific board. Need to target posts. Whole
+// networks of the posters are banned according to prefixes. Returns all
+// banned networks.
+func Ban(
+	board, reason, by string,
+	expires time.Time,
+	prefixes auth.BanPrefixes,
+	ids ...uint64,
+) (
 	ips map[string]uint64, err error,
 ) {
 	type post struct {
@@ -40,6 +46,10 @@ func Ban(board, reason, by string, expires time.Time, ids ...uint64) (
 		default:
 			return nil, err
 		}
+		ip, err = prefixes.Network(ip)
+		if err != nil {
+			return nil, err
+		}
 		ips[ip] = id
 		posts = append(posts, post{id: id})
 	}
@@ -85,7 +95,12 @@ func Ban(board, reason, by string, expires time.Time, ids ...uint64) (
 
 // Lift a ban from a specific post on a specific board
 func Unban(board string, id uint64, by string) error {
-	return execPrepared("unban", board, id, by)
+	return unban(nil, board, id, by)
+}
+
+func unban(tx *sql.Tx, board string, id uint64, by string) (err error) {
+	_, err = getStatement(tx, "unban").Exec(board, id, by)
+	return
 }
 
 func loadBans() error {
@@ -136,6 +151,8 @@ func moderatePost(
 		return
 	}
 
+	// Deleting the OP hides the whole thread. It is purged together with the
+	// OP after the retention period.
 	if id == op && query == "delete_post" {
 		query = "delete_thread"
 	}
@@ -153,6 +170,70 @@ func DeletePost(id uint64, by string) error {
 	return moderatePost(id, by, "delete_post", common.DeletePost)
 }
 
+// UndeletePost restores a deleted post, that has not been purged yet.
+// Restoring an OP also restores its thread. Clients see the restored post
+// after reloading the thread.
+func UndeletePost(id uint64, by string) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	res, err := tx.Stmt(prepared["undelete_post"]).Exec(id, by)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	err = execPreparedTx(tx, "undelete_thread", id)
+	return
+}
+
+// DeletePostImages removes all files from a post, keeping its text.
+func DeletePostImages(id uint64, by string) error {
+	return moderatePostImages(id, by, "delete_post_images", common.DeleteImage)
+}
+
+// SpoilerPostImages hides thumbnails of all files of a post behind a
+// spoiler.
+func SpoilerPostImages(id uint64, by string) error {
+	return moderatePostImages(id, by, "spoiler_post_images", common.SpoilerImage)
+}
+
+func moderatePostImages(
+	id uint64,
+	by, query string,
+	propagate func(id, op uint64) error,
+) (
+	err error,
+) {
+	op, err := GetPostOP(id)
+	if err != nil {
+		return
+	}
+
+	res, err := prepared[query].Exec(id, by)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		return ErrNoImage
+	}
+
+	err = propagate(id, op)
+	return
+}
+
 // GetSameIPPosts returns posts with the same IP and on the same board as the
 // target post
 func GetSameIPPosts(id uint64, board string) (
@@ -197,8 +278,93 @@ func GetSameIPPosts(id uint64, board string) (
 }
 
 // Set the sticky field on a thread
-func SetThreadSticky(id uint64, sticky bool) error {
-	return execPrepared("set_sticky", id, sticky)
+func SetThreadSticky(id uint64, sticky bool, by string) (err error) {
+	res, err := prepared["set_sticky"].Exec(id, sticky, by)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return
+}
+
+// Set the locked field on a thread. Locked threads don't accept new posts.
+func SetThreadLocked(id uint64, locked bool, by string) (err error) {
+	res, err := prepared["set_locked"].Exec(id, locked, by)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return
+}
+
+// MoveThread transfers a thread with all its posts to another board and
+// redirects clients, that are viewing it.
+func MoveThread(id uint64, from, to, by string) (err error) {
+	err = moveThread(id, from, to, by)
+	if err != nil {
+		return
+	}
+	if !IsTest {
+		err = common.MoveThread(id, to)
+	}
+	return
+}
+
+func moveThread(id uint64, from, to, by string) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	res, err := tx.Stmt(prepared["move_thread"]).Exec(id, from, to, by)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	err = execPreparedTx(tx, "move_thread_posts", id, to)
+	if err != nil {
+		return
+	}
+	// Remaining threads don't change, so invalidate source board caches
+	// explicitly.
+	err = execPreparedTx(tx, "touch_board", from)
+	return
+}
+
+// GetStaffBoards returns boards, where the account holder is at least a
+// janitor
+func GetStaffBoards(account string) (boards []string, err error) {
+	if account == "admin" {
+		return config.GetAllBoardIDs(), nil
+	}
+	r, err := prepared["get_staff_boards"].Query(account)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	for r.Next() {
+		var board string
+		err = r.Scan(&board)
+		if err != nil {
+			return
+		}
+		boards = append(boards, board)
+	}
+	err = r.Err()
+	return
 }
 
 // GetOwnedBoards returns boards the account holder owns
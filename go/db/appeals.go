@@ -0,0 +1,106 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+
+	"github.com/lib/pq"
+)
+
+// WriteAppeal stores an appeal of the IP's ban on the board. Returns
+// sql.ErrNoRows, if the ban was already appealed or doesn't exist.
+func WriteAppeal(ip, board, body string) (err error) {
+	res, err := prepared["write_appeal"].Exec(ip, board, body)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return
+}
+
+// GetAppealState returns, if the ban of the network on the board has been
+// appealed and if the appeal was rejected.
+func GetAppealState(ip, board string) (appealed, rejected bool, err error) {
+	err = prepared["get_appeal_state"].QueryRow(ip, board).Scan(&rejected)
+	switch err {
+	case nil:
+		appealed = true
+	case sql.ErrNoRows:
+		err = nil
+	}
+	return
+}
+
+// GetAppeal returns board and banned post of an open appeal.
+func GetAppeal(id uint64) (board string, post uint64, err error) {
+	err = prepared["get_appeal"].QueryRow(id).Scan(&board, &post)
+	return
+}
+
+// GetAppeals retrieves open appeals of the specified boards, newest first.
+func GetAppeals(boards []string) (appeals auth.Appeals, err error) {
+	appeals = make(auth.Appeals, 0)
+	rs, err := prepared["get_appeals"].Query(pq.Array(boards))
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var a auth.Appeal
+		var expires, created time.Time
+		err = rs.Scan(
+			&a.ID, &a.Board, &a.Post, &a.Reason, &a.Body, &expires, &created,
+		)
+		if err != nil {
+			return
+		}
+		a.Expires = expires.Unix()
+		a.Created = created.Unix()
+		appeals = append(appeals, a)
+	}
+	err = rs.Err()
+	return
+}
+
+// AcceptAppeal closes the appeal and lifts the appealed ban.
+func AcceptAppeal(id uint64, by string) (err error) {
+	board, post, err := GetAppeal(id)
+	if err != nil {
+		return
+	}
+
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	err = resolveAppeal(tx, "accept_appeal", id, by)
+	if err != nil {
+		return
+	}
+	return unban(tx, board, post, by)
+}
+
+// RejectAppeal closes the appeal, keeping the ban. The ban can not be
+// appealed again.
+func RejectAppeal(id uint64, by string) error {
+	return resolveAppeal(nil, "reject_appeal", id, by)
+}
+
+func resolveAppeal(tx *sql.Tx, query string, id uint64, by string) (err error) {
+	res, err := getStatement(tx, query).Exec(id, by)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+)
+
+var (
+	// Cached perceptual hashes of blocked images.
+	blocklist   []uint64
+	blocklistMu sync.RWMutex
+)
+
+func loadBlocklist() error {
+	if err := refreshBlocklist(); err != nil {
+		return err
+	}
+	return listenFunc("blocklist_updated", func(_ string) error {
+		return refreshBlocklist()
+	})
+}
+
+func refreshBlocklist() (err error) {
+	r, err := db.Query(`SELECT phash FROM phash_blocklist`)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	hashes := make([]uint64, 0, 16)
+	for r.Next() {
+		var hash int64
+		if err = r.Scan(&hash); err != nil {
+			return
+		}
+		hashes = append(hashes, uint64(hash))
+	}
+	if err = r.Err(); err != nil {
+		return
+	}
+
+	blocklistMu.Lock()
+	blocklist = hashes
+	blocklistMu.Unlock()
+	return
+}
+
+// IsBlockedImage returns, if perceptual hash differs from any blocked one
+// in less than threshold bits. Zero hash means there is no hash and is
+// never blocked.
+func IsBlockedImage(phash uint64, threshold int) bool {
+	if phash == 0 {
+		return false
+	}
+	blocklistMu.RLock()
+	defer blocklistMu.RUnlock()
+	for _, h := range blocklist {
+		if bits.OnesCount64(phash^h) < threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockImages adds perceptual hashes of files of a post to the blocklist
+// and returns number of blocked files. Files without hash are skipped.
+func BlockImages(
+	board string,
+	id uint64,
+	by string,
+	files []*common.Image,
+) (n int, err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	for _, f := range files {
+		if f.PHash == 0 {
+			continue
+		}
+		err = execPreparedTx(tx, "block_image", int64(f.PHash), f.SHA1, by)
+		if err != nil {
+			return
+		}
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	err = execPreparedTx(tx, "log_block_image", board, id, by)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec(`notify blocklist_updated`)
+	return
+}
+
+// UnblockImage removes hash from the blocklist.
+func UnblockImage(phash uint64) (err error) {
+	err = execPrepared("unblock_image", int64(phash))
+	if err != nil {
+		return
+	}
+	_, err = db.Exec(`notify blocklist_updated`)
+	return
+}
+
+// GetBlocklist retrieves all blocklist entries, newest first.
+func GetBlocklist() (entries []common.BlockedImage, err error) {
+	entries = make([]common.BlockedImage, 0)
+	r, err := prepared["get_blocklist"].Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var e common.BlockedImage
+		var hash int64
+		var created time.Time
+		if err = r.Scan(&hash, &e.SHA1, &e.By, &created); err != nil {
+			return
+		}
+		e.PHash = FormatPHash(uint64(hash))
+		e.Created = created.Unix()
+		entries = append(entries, e)
+	}
+	err = r.Err()
+	return
+}
+
+// FormatPHash encodes perceptual hash as fixed-width hex string.
+func FormatPHash(phash uint64) string {
+	return fmt.Sprintf("%016x", phash)
+}
+
+// ParsePHash decodes perceptual hash encoded by FormatPHash.
+func ParsePHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
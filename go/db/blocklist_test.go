@@ -0,0 +1,40 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestIsBlockedImage(t *testing.T) {
+	const blocked = 0xF0F0F0F0F0F0F0F0
+	blocklistMu.Lock()
+	blocklist = []uint64{blocked}
+	blocklistMu.Unlock()
+	defer func() {
+		blocklistMu.Lock()
+		blocklist = nil
+		blocklistMu.Unlock()
+	}()
+
+	cases := [...]struct {
+		name      string
+		phash     uint64
+		threshold int
+		blocked   bool
+	}{
+		{"same", blocked, 8, true},
+		{"close", blocked ^ 0x7F, 8, true},
+		{"at threshold", blocked ^ 0xFF, 8, false},
+		{"far", ^uint64(blocked), 8, false},
+		{"higher threshold", blocked ^ 0xFF, 9, true},
+		{"no hash", 0, 64, false},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			if IsBlockedImage(c.phash, c.threshold) != c.blocked {
+				t.Fatalf("expected blocked = %v", c.blocked)
+			}
+		})
+	}
+}
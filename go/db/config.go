@@ -1,7 +1,9 @@
 package db
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/util"
@@ -16,6 +18,39 @@ func loadServerConfig() error {
 	return listenFunc("config_updates", updateServerConfig)
 }
 
+// Secret key shared by all server instances. Keys hashes of data, that must
+// not be reversible, like reporter IPs.
+var serverSecret []byte
+
+// ServerSecret returns the secret key of this deployment
+func ServerSecret() []byte {
+	return serverSecret
+}
+
+// Load the server secret, generating and storing a new one, if there is none
+// yet. Concurrently started instances all end up with the first stored key.
+func loadServerSecret() (err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return
+	}
+	_, err = db.Exec(
+		`INSERT INTO main (id, val) VALUES ('secret', $1)
+			ON CONFLICT (id) DO NOTHING`,
+		hex.EncodeToString(buf),
+	)
+	if err != nil {
+		return
+	}
+	var s string
+	err = db.QueryRow(`SELECT val FROM main WHERE id = 'secret'`).Scan(&s)
+	if err != nil {
+		return
+	}
+	serverSecret, err = hex.DecodeString(s)
+	return
+}
+
 func getServerConfig() (c config.ServerConfig, err error) {
 	var data []byte
 	err = db.QueryRow(`SELECT val FROM main WHERE id = 'config'`).Scan(&data)
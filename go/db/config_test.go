@@ -7,30 +7,29 @@ import (
 	. "github.com/cutechan/cutechan/go/test"
 )
 
-func TestLoadConfigs(t *testing.T) {
-	config.Clear()
-	assertExec(t, `UPDATE main SET val = '{"captcha":true}' WHERE id = 'config'`)
+func TestGetServerConfig(t *testing.T) {
+	assertExec(t, `UPDATE main SET val = '{"maxFiles":3}' WHERE id = 'config'`)
 
-	if err := loadConfigs(); err != nil {
+	conf, err := getServerConfig()
+	if err != nil {
 		t.Fatal(err)
 	}
-
-	AssertDeepEquals(t, config.Get(), &config.Configs{
-		Public: config.Public{
-			Captcha: true,
+	AssertDeepEquals(t, conf, config.ServerConfig{
+		ServerPublic: config.ServerPublic{
+			MaxFiles: 3,
 		},
 	})
 }
 
-func TestUpdateConfigs(t *testing.T) {
-	config.Set(config.Configs{})
+func TestUpdateServerConfig(t *testing.T) {
+	config.Set(config.ServerConfig{})
 
-	std := config.Configs{
-		Public: config.Public{
-			Captcha: true,
+	std := config.ServerConfig{
+		ServerPublic: config.ServerPublic{
+			MaxFiles: 3,
 		},
 	}
-	if err := updateConfigs(`{"captcha":true}`); err != nil {
+	if err := updateServerConfig(`{"maxFiles":3}`); err != nil {
 		t.Fatal(err)
 	}
 	AssertDeepEquals(t, config.Get(), &std)
@@ -38,61 +37,53 @@ func TestUpdateConfigs(t *testing.T) {
 
 func TestUpdateOnRemovedBoard(t *testing.T) {
 	assertTableClear(t, "boards")
-	config.Clear()
-	config.SetBoardConfigs(config.BoardConfigs{
-		ID: "a",
+	err := config.SetBoardConfig(config.BoardConfig{
+		BoardPublic: config.BoardPublic{
+			ID: "a",
+		},
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if err := updateBoardConfigs("a"); err != nil {
+	if err := updateBoardConfig("a"); err != nil {
 		t.Fatal(err)
 	}
 
-	AssertDeepEquals(
-		t,
-		config.GetBoardConfigs("a"),
-		config.BoardConfContainer{},
-	)
-	AssertDeepEquals(t, config.GetBoards(), []string{})
+	if config.IsBoard("a") {
+		t.Fatal("board not removed")
+	}
 }
 
 func TestUpdateOnAddBoard(t *testing.T) {
 	assertTableClear(t, "boards")
-	config.Clear()
+	config.RemoveBoard("a")
 
-	std := BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
-			ID: "a",
-			BoardPublic: config.BoardPublic{
-				Title: "123",
-			},
+	std := config.BoardConfig{
+		BoardPublic: config.BoardPublic{
+			ID:    "a",
+			Title: "123",
 		},
 	}
 	if err := WriteBoard(nil, std); err != nil {
 		t.Fatal(err)
 	}
 
-	if err := updateBoardConfigs("a"); err != nil {
+	if err := updateBoardConfig("a"); err != nil {
 		t.Fatal(err)
 	}
 
-	AssertDeepEquals(
-		t,
-		config.GetBoardConfigs("a").BoardConfigs,
-		std.BoardConfigs,
-	)
-	AssertDeepEquals(t, config.GetBoards(), []string{"a"})
+	AssertDeepEquals(t, config.GetBoardConfig("a").BoardPublic, std.BoardPublic)
 }
 
 func TestUpdateBoardConfigs(t *testing.T) {
 	assertTableClear(t, "boards")
-	config.Clear()
+	config.RemoveBoard("a")
 
-	std := BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
-			ID: "a",
-			BoardPublic: config.BoardPublic{
-				Title: "123",
-			},
+	std := config.BoardConfig{
+		BoardPublic: config.BoardPublic{
+			ID:    "a",
+			Title: "123",
 		},
 	}
 	if err := WriteBoard(nil, std); err != nil {
@@ -102,27 +93,17 @@ func TestUpdateBoardConfigs(t *testing.T) {
 	if err := loadBoardConfigs(); err != nil {
 		t.Fatal(err)
 	}
-
-	AssertDeepEquals(
-		t,
-		config.GetBoardConfigs("a").BoardConfigs,
-		std.BoardConfigs,
-	)
+	AssertDeepEquals(t, config.GetBoardConfig("a").BoardPublic, std.BoardPublic)
 
 	assertExec(t,
 		`UPDATE boards
-			SET title = 'foo'
+			SET settings = settings || '{"title": "foo"}'
 			WHERE id = 'a'`,
 	)
-
-	if err := updateBoardConfigs("a"); err != nil {
+	if err := updateBoardConfig("a"); err != nil {
 		t.Fatal(err)
 	}
 
 	std.Title = "foo"
-	AssertDeepEquals(
-		t,
-		config.GetBoardConfigs("a").BoardConfigs,
-		std.BoardConfigs,
-	)
+	AssertDeepEquals(t, config.GetBoardConfig("a").BoardPublic, std.BoardPublic)
 }
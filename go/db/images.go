@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"io"
 	"time"
 
 	"github.com/cutechan/cutechan/go/auth"
@@ -20,21 +22,175 @@ const (
 // WriteImage writes a processed image record to the DB.
 func WriteImage(tx *sql.Tx, i common.ImageCommon) error {
 	dims := pq.GenericArray{A: i.Dims}
-	_, err := getStatement(tx, "write_image").Exec(
+	variants, err := marshalVariants(i.Variants)
+	if err != nil {
+		return err
+	}
+	contents := i.Contents
+	if contents == nil {
+		contents = []common.ArchiveEntry{}
+	}
+	contentsBuf, err := json.Marshal(contents)
+	if err != nil {
+		return err
+	}
+	_, err = getStatement(tx, "write_image").Exec(
 		i.APNG, i.Audio, i.Video, i.FileType, i.ThumbType, dims, i.Length,
-		i.Size, i.MD5, i.SHA1, i.Title, i.Artist,
+		i.Size, i.MD5, i.SHA1, i.Title, i.Artist, variants, contentsBuf,
+		int64(i.PHash),
 	)
 	return err
 }
 
+func marshalVariants(variants []common.ThumbVariant) ([]byte, error) {
+	if variants == nil {
+		variants = []common.ThumbVariant{}
+	}
+	return json.Marshal(variants)
+}
+
 // GetImage retrieves a thumbnailed image record from the DB.
 func GetImage(SHA1 string) (common.ImageCommon, error) {
 	return scanImage(prepared["get_image"].QueryRow(SHA1))
 }
 
+// GetImageByOriginal retrieves an image record by SHA1 of the file as it
+// was uploaded, before stripping its metadata. Also returns summary of
+// stripped metadata, if it was kept.
+func GetImageByOriginal(hash string) (
+	img common.ImageCommon,
+	meta *common.MetadataSummary,
+	err error,
+) {
+	var fs fileScanner
+	var buf []byte
+	args := append(fs.ScanArgs(), &buf)
+	err = prepared["get_image_by_original"].QueryRow(hash).Scan(args...)
+	if err != nil {
+		return
+	}
+	img = fs.Val().ImageCommon
+	meta, err = decodeMetadata(buf)
+	return
+}
+
+// WriteImageOriginal records SHA1 and MD5 of the uploaded file, which was
+// stored with its metadata stripped.
+func WriteImageOriginal(
+	hash, MD5, SHA1 string,
+	meta *common.MetadataSummary,
+) error {
+	buf, err := encodeMetadata(meta)
+	if err != nil {
+		return err
+	}
+	return execPrepared("write_image_original", hash, MD5, SHA1, buf)
+}
+
+// Encode metadata summary for nullable column.
+func encodeMetadata(meta *common.MetadataSummary) (interface{}, error) {
+	if meta == nil {
+		return nil, nil
+	}
+	return json.Marshal(meta)
+}
+
+func decodeMetadata(buf []byte) (meta *common.MetadataSummary, err error) {
+	if len(buf) == 0 {
+		return
+	}
+	meta = &common.MetadataSummary{}
+	err = json.Unmarshal(buf, meta)
+	return
+}
+
+// GetFilePosts retrieves up to limit posts, newest first, using the file
+// with either given SHA1 or MD5. Empty hashes are not matched. Hashes of
+// the uploaded file are also matched, if it was stored with metadata
+// stripped. Posts on hidden boards are skipped.
+//
+// MD5 of images stored by old versions may be missing, so they can only be
+// found by SHA1.
+func GetFilePosts(
+	SHA1, MD5 string,
+	hidden []string,
+	limit int,
+) (refs []common.PostReference, err error) {
+	refs = make([]common.PostReference, 0)
+	if hidden == nil {
+		// NULL array would exclude all boards
+		hidden = []string{}
+	}
+	r, err := prepared["get_file_posts"].Query(
+		nullString(SHA1), nullString(MD5), pq.StringArray(hidden), limit,
+	)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var ref common.PostReference
+		if err = r.Scan(&ref.ID, &ref.Time, &ref.OP, &ref.Board); err != nil {
+			return
+		}
+		refs = append(refs, ref)
+	}
+	err = r.Err()
+	return
+}
+
+// UpdateImageThumb sets thumbnail dimensions, type, variants and
+// perceptual hash of an image record.
+func UpdateImageThumb(
+	SHA1 string,
+	thumbType uint8,
+	dims [4]uint16,
+	variants []common.ThumbVariant,
+	phash uint64,
+) error {
+	buf, err := marshalVariants(variants)
+	if err != nil {
+		return err
+	}
+	return execPrepared(
+		"update_image_thumb",
+		SHA1, pq.GenericArray{A: dims}, thumbType, buf, int64(phash),
+	)
+}
+
+// GetImagesAfter retrieves up to limit image records with SHA1 greater than
+// the passed one, ordered by SHA1. Used for walking all images in batches.
+func GetImagesAfter(SHA1 string, limit int) (images []common.ImageCommon, err error) {
+	r, err := prepared["get_images_after"].Query(SHA1, limit)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	images = make([]common.ImageCommon, 0, limit)
+	for r.Next() {
+		var img common.ImageCommon
+		img, err = scanImage(r)
+		if err != nil {
+			return
+		}
+		images = append(images, img)
+	}
+	err = r.Err()
+	return
+}
+
 // NewImageToken inserts a new image allocation token into the DB and
-// returns it's ID.
-func NewImageToken(SHA1 string) (token string, err error) {
+// returns it's ID. Metadata summary, if any, is passed on to the post.
+func NewImageToken(
+	SHA1 string,
+	meta *common.MetadataSummary,
+) (token string, err error) {
+	buf, err := encodeMetadata(meta)
+	if err != nil {
+		return
+	}
 	// Loop in case there is a primary key collision
 	for {
 		token, err = auth.RandomID(64)
@@ -43,7 +199,7 @@ func NewImageToken(SHA1 string) (token string, err error) {
 		}
 		expires := time.Now().Add(imageTokenTimeout)
 
-		err = execPrepared("write_image_token", token, SHA1, expires)
+		err = execPrepared("write_image_token", token, SHA1, expires, buf)
 		switch {
 		case err == nil:
 			return
@@ -55,20 +211,37 @@ func NewImageToken(SHA1 string) (token string, err error) {
 	}
 }
 
+// ExtendImageToken postpones expiration of an image allocation token.
+// Resumable uploads may take a while to finish, while tokens of files
+// uploaded earlier should stay valid.
+func ExtendImageToken(token string, timeout time.Duration) error {
+	return execPrepared("extend_image_token", token, time.Now().Add(timeout))
+}
+
 // UseImageToken deletes an image allocation token and returns the
-// matching processed image. If no token exists, returns
-// ErrInvalidToken.
-func UseImageToken(tx *sql.Tx, token string) (img common.ImageCommon, err error) {
+// matching processed image along with its metadata summary. If no token
+// exists, returns ErrInvalidToken.
+func UseImageToken(tx *sql.Tx, token string) (img common.Image, err error) {
 	if len(token) != common.LenImageToken {
 		err = ErrInvalidToken
 		return
 	}
 	var sha1 string
-	err = getStatement(tx, "use_image_token").QueryRow(token).Scan(&sha1)
+	var buf []byte
+	err = getStatement(tx, "use_image_token").QueryRow(token).Scan(&sha1, &buf)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		err = ErrInvalidToken
+		return
+	default:
+		return
+	}
+	img.ImageCommon, err = scanImage(getStatement(tx, "get_image").QueryRow(sha1))
 	if err != nil {
 		return
 	}
-	img, err = scanImage(getStatement(tx, "get_image").QueryRow(sha1))
+	img.Metadata, err = decodeMetadata(buf)
 	return
 }
 
@@ -79,7 +252,12 @@ func DeleteImageToken(token string) (err error) {
 
 // AllocateImage allocates an image's file resources to their respective
 // served directories and write its data to the database.
-func AllocateImage(src, thumb []byte, img common.ImageCommon) (err error) {
+func AllocateImage(
+	src io.ReadSeeker,
+	thumb []byte,
+	variants map[string][]byte,
+	img common.ImageCommon,
+) (err error) {
 	tx, err := BeginTx()
 	if err != nil {
 		return
@@ -93,6 +271,14 @@ func AllocateImage(src, thumb []byte, img common.ImageCommon) (err error) {
 	}
 
 	err = file.Backend.Write(img.SHA1, img.FileType, img.ThumbType, src, thumb)
+	if err == nil {
+		for _, v := range img.Variants {
+			err = file.Backend.WriteVariant(img.SHA1, v.Name, variants[v.Name])
+			if err != nil {
+				break
+			}
+		}
+	}
 	if err != nil {
 		err = cleanUpFailedAllocation(img, err)
 	}
@@ -100,9 +286,24 @@ func AllocateImage(src, thumb []byte, img common.ImageCommon) (err error) {
 	return
 }
 
+// Delete all files of an image, including thumbnail variants.
+func deleteImageFiles(img common.ImageCommon) (err error) {
+	err = file.Backend.Delete(img.SHA1, img.FileType, img.ThumbType)
+	if err != nil {
+		return
+	}
+	for _, v := range img.Variants {
+		err = file.Backend.DeleteVariant(img.SHA1, v.Name)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
 // Delete any dangling image files in case of a failed image allocation.
 func cleanUpFailedAllocation(img common.ImageCommon, err error) error {
-	delErr := file.Backend.Delete(img.SHA1, img.FileType, img.ThumbType)
+	delErr := deleteImageFiles(img)
 	if delErr != nil {
 		err = util.WrapError(err.Error(), delErr)
 	}
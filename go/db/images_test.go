@@ -4,12 +4,27 @@ import (
 	"bytes"
 	"database/sql"
 	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/file"
 	. "github.com/cutechan/cutechan/go/test"
 )
 
+// Sample image record. Empty lists as they are read back from the DB.
+var sampleImage = common.ImageCommon{
+	SHA1:      "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+	MD5:       "1B2M2Y8AsgTpgAmY7PhCfg",
+	Size:      300792,
+	FileType:  common.JPEG,
+	ThumbType: common.JPEG,
+	Dims:      [4]uint16{1080, 1080, 150, 150},
+	Variants:  []common.ThumbVariant{},
+	Contents:  []common.ArchiveEntry{},
+}
+
 func TestGetImage(t *testing.T) {
 	assertTableClear(t, "images")
 	writeSampleImage(t)
@@ -24,26 +39,32 @@ func TestGetImage(t *testing.T) {
 	t.Run("existent", func(t *testing.T) {
 		t.Parallel()
 
-		img, err := GetImage(assets.StdJPEG.SHA1)
+		img, err := GetImage(sampleImage.SHA1)
 		if err != nil {
 			t.Fatal(err)
 		}
-		AssertDeepEquals(t, img, assets.StdJPEG.ImageCommon)
+		AssertDeepEquals(t, img, sampleImage)
 	})
 }
 
 func writeSampleImage(t *testing.T) {
-	if err := WriteImage(nil, assets.StdJPEG.ImageCommon); err != nil {
+	if err := WriteImage(nil, sampleImage); err != nil {
 		t.Fatal(err)
 	}
 }
 
+// Serve files from a temporary directory for the duration of the test.
 func setupImageDirs(t *testing.T) func() {
-	if err := assets.CreateDirs(); err != nil {
+	dir, err := ioutil.TempDir("", "cutechan-test-uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.StartBackend(file.Config{Backend: "fs", Dir: dir})
+	if err != nil {
 		t.Fatal(err)
 	}
 	return func() {
-		if err := assets.DeleteDirs(); err != nil {
+		if err := os.RemoveAll(dir); err != nil {
 			t.Fatal(err)
 		}
 	}
@@ -53,43 +74,30 @@ func TestAllocateImage(t *testing.T) {
 	assertTableClear(t, "images")
 	defer setupImageDirs(t)()
 
-	id := GenString(40)
-	var files [2][]byte
-	for i, name := range [...]string{"sample", "thumb"} {
-		files[i] = ReadSample(t, name+".jpg")
-	}
-	std := common.ImageCommon{
-		SHA1:     id,
-		MD5:      GenString(22),
-		FileType: common.JPEG,
-	}
+	files := [2][]byte{[]byte("source"), []byte("thumb")}
+	std := sampleImage
+	std.SHA1 = GenString(40)
 
-	if err := AllocateImage(files[0], files[1], std); err != nil {
+	err := AllocateImage(bytes.NewReader(files[0]), files[1], nil, std)
+	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Assert files and remove them
 	t.Run("files", func(t *testing.T) {
-		for i, path := range assets.GetFilePaths(id, common.JPEG, common.JPEG) {
-			buf, err := ioutil.ReadFile(path)
-			if err != nil {
-				t.Error(err)
-			}
-			if !bytes.Equal(buf, files[i]) {
-				t.Error("invalid file")
-			}
+		src, thumb, err := file.Backend.Read(std.SHA1, std.FileType, std.ThumbType)
+		if err != nil {
+			t.Fatal(err)
 		}
+		AssertBufferEquals(t, src, files[0])
+		AssertBufferEquals(t, thumb, files[1])
 	})
 
-	// Assert database record
 	t.Run("db row", func(t *testing.T) {
-		img, err := GetImage(id)
+		img, err := GetImage(std.SHA1)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if img != std {
-			LogUnexpected(t, std, img)
-		}
+		AssertDeepEquals(t, img, std)
 	})
 }
 
@@ -97,17 +105,116 @@ func TestImageTokens(t *testing.T) {
 	assertTableClear(t, "images")
 	writeSampleImage(t)
 
-	token, err := NewImageToken(assets.StdJPEG.SHA1)
+	token, err := NewImageToken(sampleImage.SHA1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	img, err := UseImageToken(token)
+	var img common.Image
+	inTx(t, func(tx *sql.Tx) (err error) {
+		img, err = UseImageToken(tx, token)
+		return
+	})
+	AssertDeepEquals(t, img.ImageCommon, sampleImage)
+
+	// Tokens are single use
+	inTx(t, func(tx *sql.Tx) (err error) {
+		_, err = UseImageToken(tx, token)
+		if err != ErrInvalidToken {
+			UnexpectedError(t, err)
+		}
+		return nil
+	})
+}
+
+func TestGetFilePosts(t *testing.T) {
+	assertTableClear(t, "boards", "images")
+	writeBoard(t, "a")
+	writeBoard(t, "m")
+
+	// Stored before MD5 was computed
+	legacy := sampleImage
+	legacy.SHA1 = "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12"
+	legacy.MD5 = ""
+	for _, img := range [...]common.ImageCommon{sampleImage, legacy} {
+		if err := WriteImage(nil, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const (
+		origSHA1 = "de9f2c7fd25e1b3afad3e85a0bd17d9b100db4b3"
+		origMD5  = "kutf/uauL+w61xx3dTFXjw"
+	)
+	err := WriteImageOriginal(origSHA1, origMD5, sampleImage.SHA1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	std := assets.StdJPEG.ImageCommon
-	if img != std {
-		LogUnexpected(t, img, std)
+
+	now := time.Now().Unix()
+	threads := [...]struct {
+		id    uint64
+		board string
+		img   common.ImageCommon
+	}{
+		{1, "a", sampleImage},
+		{2, "a", legacy},
+		{3, "m", sampleImage},
+	}
+	for _, th := range threads {
+		writeThread(t, Post{
+			StandalonePost: common.StandalonePost{
+				Post: common.Post{
+					ID:    th.id,
+					Time:  now,
+					Files: common.Files{{ImageCommon: th.img}},
+				},
+				OP:    th.id,
+				Board: th.board,
+			},
+		})
+	}
+
+	cases := [...]struct {
+		name, SHA1, MD5 string
+		hidden          []string
+		posts           []uint64
+	}{
+		{"by SHA1", sampleImage.SHA1, "", nil, []uint64{3, 1}},
+		{"by MD5", "", sampleImage.MD5, nil, []uint64{3, 1}},
+		{"by original SHA1", origSHA1, "", nil, []uint64{3, 1}},
+		{"by original MD5", "", origMD5, nil, []uint64{3, 1}},
+		{"hidden board", sampleImage.SHA1, "", []string{"m"}, []uint64{1}},
+		{"legacy by SHA1", legacy.SHA1, "", nil, []uint64{2}},
+		{"unknown SHA1 skips missing MD5", GenString(40), "", nil, []uint64{}},
+		{"unknown MD5", "", GenString(22), nil, []uint64{}},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			refs, err := GetFilePosts(c.SHA1, c.MD5, c.hidden, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]uint64, 0, len(refs))
+			for _, r := range refs {
+				ids = append(ids, r.ID)
+			}
+			AssertDeepEquals(t, ids, c.posts)
+		})
 	}
+
+	t.Run("limit", func(t *testing.T) {
+		t.Parallel()
+
+		refs, err := GetFilePosts(sampleImage.SHA1, "", nil, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(refs) != 1 || refs[0].ID != 3 {
+			t.Fatalf("unexpected posts: %v", refs)
+		}
+	})
 }
@@ -30,6 +30,10 @@ var (
 	// during tests
 	IsTest bool
 
+	// NoCleanup disables periodic clean up tasks. Used by command line
+	// utilities, that run alongside the server.
+	NoCleanup bool
+
 	// Stores the postgres database instance
 	db *sql.DB
 )
@@ -167,10 +171,196 @@ var upgrades = []func(*sql.Tx) error{
 			`CREATE INDEX posts_op_time ON posts (op, time)`,
 		)
 	},
+	// Thread watching and reply notifications.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE posts
+				ADD COLUMN account varchar(20) REFERENCES accounts ON DELETE SET NULL`,
+			`CREATE INDEX posts_account ON posts (account)`,
+			`CREATE TABLE thread_watches (
+				account varchar(20) NOT NULL REFERENCES accounts ON DELETE CASCADE,
+				thread bigint NOT NULL REFERENCES threads ON DELETE CASCADE,
+				PRIMARY KEY (account, thread)
+			)`,
+			`CREATE INDEX thread_watches_thread ON thread_watches (thread)`,
+			`DROP FUNCTION insert_thread(id bigint, op bigint, now bigint, board text, auth character varying, name character varying, body text, ip inet, links bigint[], commands json[], file_cnt bigint, subject character varying)`,
+		)
+	},
+	// Full-text search.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE posts ADD COLUMN body_tsv tsvector`,
+			`ALTER TABLE threads ADD COLUMN subject_tsv tsvector`,
+			`UPDATE posts SET body_tsv = to_tsvector('simple', body)`,
+			`UPDATE threads SET subject_tsv = to_tsvector('simple', subject)`,
+			`CREATE INDEX posts_body_tsv ON posts USING gin (body_tsv)`,
+			`CREATE INDEX threads_subject_tsv ON threads USING gin (subject_tsv)`,
+			`CREATE TRIGGER posts_body_tsv_update
+				BEFORE INSERT OR UPDATE OF body ON posts
+				FOR EACH ROW EXECUTE PROCEDURE
+				tsvector_update_trigger(body_tsv, 'pg_catalog.simple', body)`,
+			`CREATE TRIGGER threads_subject_tsv_update
+				BEFORE INSERT OR UPDATE OF subject ON threads
+				FOR EACH ROW EXECUTE PROCEDURE
+				tsvector_update_trigger(subject_tsv, 'pg_catalog.simple', subject)`,
+		)
+	},
+	// Thread archive.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE threads
+				ADD COLUMN archived boolean NOT NULL DEFAULT false`,
+			`CREATE INDEX threads_archived ON threads (archived)`,
+		)
+	},
+	// Thumbnail variants.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE images
+				ADD COLUMN variants jsonb NOT NULL DEFAULT '[]'`,
+		)
+	},
+	// Archive listings.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE images
+				ADD COLUMN contents jsonb NOT NULL DEFAULT '[]'`,
+		)
+	},
+	// Metadata stripping.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE TABLE image_originals (
+				hash char(40) PRIMARY KEY,
+				sha1 char(40) NOT NULL REFERENCES images ON DELETE CASCADE,
+				metadata jsonb
+			)`,
+			`CREATE INDEX image_originals_sha1 ON image_originals (sha1)`,
+			`ALTER TABLE image_tokens ADD COLUMN metadata jsonb`,
+			`ALTER TABLE post_files ADD COLUMN metadata jsonb`,
+		)
+	},
+	// Perceptual hash blocklist.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE images
+				ADD COLUMN phash bigint NOT NULL DEFAULT 0`,
+			`CREATE TABLE phash_blocklist (
+				phash bigint PRIMARY KEY,
+				sha1 char(40) NOT NULL,
+				by varchar(20) NOT NULL,
+				created timestamp NOT NULL DEFAULT (now() at time zone 'utc')
+			)`,
+		)
+	},
+	// File lookup by MD5.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE INDEX images_md5 ON images (MD5)`,
+		)
+	},
+	// Spoilered post files.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE post_files
+				ADD COLUMN spoiler boolean NOT NULL DEFAULT false`,
+		)
+	},
+	// Thread locking and moving.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE threads
+				ADD COLUMN locked boolean NOT NULL DEFAULT false`,
+			`ALTER TABLE boards
+				ADD COLUMN modTime bigint NOT NULL DEFAULT 0`,
+		)
+	},
+	// Post reports.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE TABLE reports (
+				id bigserial PRIMARY KEY,
+				board text NOT NULL REFERENCES boards ON DELETE CASCADE,
+				post bigint NOT NULL REFERENCES posts ON DELETE CASCADE,
+				reason varchar(100) NOT NULL,
+				ip_hash char(64) NOT NULL,
+				created timestamp NOT NULL DEFAULT (now() at time zone 'utc'),
+				UNIQUE (post, ip_hash)
+			)`,
+			`CREATE INDEX reports_board ON reports (board)`,
+			`CREATE INDEX reports_ip_hash ON reports (ip_hash, created)`,
+		)
+	},
+	// Reversible post deletion.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE posts
+				ADD COLUMN deletedTime bigint`,
+			`CREATE INDEX posts_deleted_time ON posts (deletedTime)
+				WHERE deleted`,
+		)
+	},
+	// Network range bans.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE INDEX bans_ip ON bans USING gist (ip inet_ops)`,
+		)
+	},
+	// Ban appeals.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`CREATE TABLE appeals (
+				id bigserial PRIMARY KEY,
+				board text NOT NULL REFERENCES boards ON DELETE CASCADE,
+				ip inet NOT NULL,
+				forPost bigint NOT NULL,
+				body varchar(500) NOT NULL,
+				rejected boolean NOT NULL DEFAULT false,
+				created timestamp NOT NULL DEFAULT (now() at time zone 'utc'),
+				UNIQUE (ip, board)
+			)`,
+			`CREATE INDEX appeals_board ON appeals (board)`,
+		)
+	},
+	// Reversible thread deletion.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE threads
+				ADD COLUMN deleted boolean NOT NULL DEFAULT false`,
+		)
+	},
+	// Configs written before deleted post retention existed decode it as 0,
+	// which disables purging. Set the default instead.
+	func(tx *sql.Tx) (err error) {
+		_, err = tx.Exec(
+			`UPDATE main
+				SET val = jsonb_set(
+					val::jsonb, '{deletedRetention}', to_jsonb($1::int)
+				)::text
+				WHERE id = 'config'
+					AND COALESCE((val::jsonb->>'deletedRetention')::int, 0) = 0`,
+			common.DefaultDeletedRetention,
+		)
+		return
+	},
+	// File lookup by MD5 of the original upload. Not known for files
+	// stripped before.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`ALTER TABLE image_originals ADD COLUMN md5 char(22)`,
+			`CREATE INDEX image_originals_md5 ON image_originals (md5)`,
+		)
+	},
+	// Bump limit passed by the server, see config.GetBumpLimit.
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`DROP FUNCTION bump_thread(id bigint, addPost boolean, delPost boolean, bump boolean, file_cnt bigint)`,
+		)
+	},
 }
 
 func StartDB() (err error) {
-	if db, err = sql.Open("postgres", ConnArgs); err != nil {
+	if db, err = sql.Open(driverName, ConnArgs); err != nil {
 		return
 	}
 
@@ -190,12 +380,18 @@ func StartDB() (err error) {
 	if !exists {
 		tasks = append(tasks, createAdminAccount)
 	}
-	tasks = append(tasks, loadServerConfig, loadBoardConfigs, loadBans)
+	tasks = append(
+		tasks,
+		loadServerConfig, loadServerSecret, loadBoardConfigs, loadBans,
+		loadBlocklist,
+	)
 	if err = util.Waterfall(tasks...); err != nil {
 		return
 	}
 
-	go runCleanupTasks()
+	if !NoCleanup {
+		go runCleanupTasks()
+	}
 	return
 }
 
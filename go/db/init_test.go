@@ -5,7 +5,7 @@ import "testing"
 func init() {
 	ConnArgs = TestConnArgs
 	IsTest = true
-	if err := LoadDB(); err != nil {
+	if err := StartDB(); err != nil {
 		panic(err)
 	}
 }
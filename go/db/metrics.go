@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/cutechan/cutechan/go/metrics"
+
+	"github.com/lib/pq"
+)
+
+// Name of the registered database/sql driver, that records prepared
+// statement latencies.
+const driverName = "postgres-metrics"
+
+var (
+	// Prepared statement names by query text. Filled once on startup by
+	// genPrepared.
+	statementNames = make(map[string]string)
+)
+
+func init() {
+	sql.Register(driverName, metricsDriver{&pq.Driver{}})
+}
+
+// Wraps pq driver to time execution of named prepared statements.
+type metricsDriver struct {
+	driver.Driver
+}
+
+func (d metricsDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return metricsConn{c}, nil
+}
+
+type metricsConn struct {
+	driver.Conn
+}
+
+func (c metricsConn) Prepare(query string) (driver.Stmt, error) {
+	st, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := statementNames[query]
+	if !ok {
+		return st, nil
+	}
+	return metricsStmt{st, name}, nil
+}
+
+func (c metricsConn) BeginTx(
+	ctx context.Context,
+	opts driver.TxOptions,
+) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c metricsConn) Exec(
+	query string,
+	args []driver.Value,
+) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.Execer); ok {
+		return e.Exec(query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c metricsConn) Query(
+	query string,
+	args []driver.Value,
+) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.Queryer); ok {
+		return q.Query(query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c metricsConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+type metricsStmt struct {
+	driver.Stmt
+	name string
+}
+
+func (s metricsStmt) Exec(args []driver.Value) (driver.Result, error) {
+	defer s.observe(time.Now())
+	return s.Stmt.Exec(args)
+}
+
+func (s metricsStmt) Query(args []driver.Value) (driver.Rows, error) {
+	defer s.observe(time.Now())
+	return s.Stmt.Query(args)
+}
+
+func (s metricsStmt) observe(start time.Time) {
+	metrics.DBStatementDuration.
+		WithLabelValues(s.name).
+		Observe(metrics.Since(start))
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
 	"strconv"
 	"time"
 
@@ -28,14 +29,19 @@ var (
 
 	// Occurs when client tries to retrieve too much tokens.
 	ErrTokenForbidden = errors.New("token forbidden")
+
+	// Occurs when moderating files of a post without any.
+	ErrNoImage = errors.New("post has no image")
 )
 
 // Post is for writing new posts to a database. It contains the Password
-// field, which is never exposed publically through Post.
+// and Account fields, which are never exposed publically through Post.
 type Post struct {
 	common.StandalonePost
 	Password []byte
 	IP       string
+	// Account of the logged in author, if any
+	Account string
 }
 
 // Thread is a template for writing new threads to the database
@@ -188,9 +194,20 @@ func (c commandRow) Value() (driver.Value, error) {
 	return strArr.Value()
 }
 
-// ValidateOP confirms the specified thread exists on specific board
-func ValidateOP(id uint64, board string) (valid bool, err error) {
-	err = prepared["validate_op"].QueryRow(id, board).Scan(&valid)
+// ValidateOP confirms the specified thread exists on specific board and is
+// not deleted
+func ValidateOP(id uint64, board string) (bool, error) {
+	return validateOP(id, board, false)
+}
+
+// ValidateOPWithDeleted is like ValidateOP, but also accepts deleted threads,
+// that only moderators can see
+func ValidateOPWithDeleted(id uint64, board string) (bool, error) {
+	return validateOP(id, board, true)
+}
+
+func validateOP(id uint64, board string, deleted bool) (valid bool, err error) {
+	err = prepared["validate_op"].QueryRow(id, board, deleted).Scan(&valid)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -267,9 +284,17 @@ func getPostCreationArgs(p Post) []interface{} {
 	}
 }
 
+// Post author account or NULL for anonymous posts.
+func accountArg(p Post) *string {
+	if p.Account == "" {
+		return nil
+	}
+	return &p.Account
+}
+
 // InsertThread inserts a new thread into the database.
 func InsertThread(tx *sql.Tx, p Post, subject string) (err error) {
-	args := append(getPostCreationArgs(p), subject)
+	args := append(getPostCreationArgs(p), subject, accountArg(p))
 	err = execPreparedTx(tx, "insert_thread", args...)
 	if err != nil {
 		return
@@ -278,9 +303,13 @@ func InsertThread(tx *sql.Tx, p Post, subject string) (err error) {
 	return
 }
 
-// InsertPost inserts a post into an existing thread.
+// InsertPost inserts a post into an existing thread. Open posts are
+// written with their reclamation password.
 func InsertPost(tx *sql.Tx, p Post) (err error) {
-	args := getPostCreationArgs(p)
+	args := append(
+		getPostCreationArgs(p),
+		p.Editing, p.Password, accountArg(p), config.GetBumpLimit(p.Board),
+	)
 	err = execPreparedTx(tx, "insert_post", args...)
 	if err != nil {
 		return
@@ -292,7 +321,12 @@ func InsertPost(tx *sql.Tx, p Post) (err error) {
 // Write post files in current transaction.
 func InsertFiles(tx *sql.Tx, p Post) (err error) {
 	for _, f := range p.Files {
-		err = execPreparedTx(tx, "insert_post_file", p.ID, f.SHA1)
+		var meta interface{}
+		meta, err = encodeMetadata(f.Metadata)
+		if err != nil {
+			return
+		}
+		err = execPreparedTx(tx, "insert_post_file", p.ID, f.SHA1, meta)
 		if err != nil {
 			return
 		}
@@ -300,6 +334,59 @@ func InsertFiles(tx *sql.Tx, p Post) (err error) {
 	return
 }
 
+// GetPostFilesMetadata retrieves summaries of metadata stripped from post
+// files, in the order of files. Summary is nil if there was nothing to
+// strip or it wasn't kept.
+func GetPostFilesMetadata(id uint64) (metas []*common.MetadataSummary, err error) {
+	r, err := prepared["get_post_files_metadata"].Query(id)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var buf []byte
+		if err = r.Scan(&buf); err != nil {
+			return
+		}
+		var meta *common.MetadataSummary
+		if meta, err = decodeMetadata(buf); err != nil {
+			return
+		}
+		metas = append(metas, meta)
+	}
+	err = r.Err()
+	return
+}
+
+// SetOpenBody replaces the body of an open post.
+func SetOpenBody(id uint64, body []byte) error {
+	return execPrepared("replace_body", id, string(body))
+}
+
+// ClosePost closes an open post and writes its final body, links and
+// commands.
+func ClosePost(
+	tx *sql.Tx,
+	id uint64,
+	body string,
+	links common.Links,
+	commands common.Commands,
+) error {
+	_, err := getExecutor(tx, "close_post").Exec(
+		id, body, linkRow(links), commandRow(commands),
+	)
+	return err
+}
+
+// GetPostPassword retrieves the reclamation password hash of an open
+// post. Returns sql.ErrNoRows, if the post does not exist or is already
+// closed.
+func GetPostPassword(id uint64) (hash []byte, err error) {
+	err = prepared["get_post_password"].QueryRow(id).Scan(&hash)
+	return
+}
+
 // Token operations
 
 func NewPostToken(ip string) (token string, err error) {
@@ -339,7 +426,7 @@ func UsePostToken(token string) (err error) {
 	return
 }
 
-func CanCreateThread(ip string) bool {
+func CanCreateThread(ip string, cooldown time.Duration) bool {
 	var unix int64
 	err := prepared["get_last_thread_time_by_ip"].QueryRow(ip).Scan(&unix)
 	switch err {
@@ -352,10 +439,10 @@ func CanCreateThread(ip string) bool {
 
 	created := time.Unix(unix, 0)
 	passed := time.Since(created)
-	return passed.Minutes() >= 1.0
+	return passed >= cooldown
 }
 
-func CanCreatePost(ip string) bool {
+func CanCreatePost(ip string, cooldown time.Duration) bool {
 	var unix int64
 	err := prepared["get_last_post_time_by_ip"].QueryRow(ip).Scan(&unix)
 	switch err {
@@ -368,5 +455,5 @@ func CanCreatePost(ip string) bool {
 
 	created := time.Unix(unix, 0)
 	passed := time.Since(created)
-	return passed.Seconds() >= 1.0
+	return passed >= cooldown
 }
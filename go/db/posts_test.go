@@ -1,10 +1,12 @@
 package db
 
 import (
-	"github.com/cutechan/cutechan/go/common"
-	"github.com/cutechan/cutechan/go/config"
+	"database/sql"
 	"testing"
 	"time"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
 )
 
 func TestValidateOp(t *testing.T) {
@@ -37,9 +39,13 @@ func TestValidateOp(t *testing.T) {
 }
 
 func writeSampleBoard(t *testing.T) {
-	b := BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
-			ID: "a",
+	writeBoard(t, "a")
+}
+
+func writeBoard(t *testing.T, id string) {
+	b := config.BoardConfig{
+		BoardPublic: config.BoardPublic{
+			ID: id,
 		},
 	}
 	if err := WriteBoard(nil, b); err != nil {
@@ -48,20 +54,40 @@ func writeSampleBoard(t *testing.T) {
 }
 
 func writeSampleThread(t *testing.T) {
-	thread := Thread{
-		ID:    1,
-		Board: "a",
-	}
-	op := Post{
+	writeThread(t, Post{
 		StandalonePost: common.StandalonePost{
 			Post: common.Post{
 				ID:   1,
 				Time: time.Now().Unix(),
 			},
-			OP: 1,
+			OP:    1,
+			Board: "a",
 		},
+	})
+}
+
+// Write a thread with the passed OP
+func writeThread(t *testing.T, op Post) {
+	inTx(t, func(tx *sql.Tx) error {
+		return InsertThread(tx, op, "")
+	})
+}
+
+// Write a reply into an existing thread
+func writePost(t *testing.T, p Post) {
+	inTx(t, func(tx *sql.Tx) error {
+		return InsertPost(tx, p)
+	})
+}
+
+func inTx(t *testing.T, fn func(tx *sql.Tx) error) {
+	tx, err := BeginTx()
+	if err != nil {
+		t.Fatal(err)
 	}
-	if err := WriteThread(nil, thread, op); err != nil {
+	err = fn(tx)
+	EndTx(tx, &err)
+	if err != nil {
 		t.Fatal(err)
 	}
 }
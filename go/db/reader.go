@@ -2,19 +2,25 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 
 	"github.com/cutechan/cutechan/go/common"
 
 	"github.com/lib/pq"
 )
 
+const (
+	// ArchivePageSize is the number of threads per archive page.
+	ArchivePageSize = 100
+)
+
 type threadScanner struct {
 	common.Thread
 }
 
 func (t *threadScanner) ScanArgs() []interface{} {
 	return []interface{}{
-		&t.Sticky, &t.Board,
+		&t.Sticky, &t.Archived, &t.Locked, &t.Board,
 		&t.PostCtr, &t.ImageCtr,
 		&t.ReplyTime, &t.BumpTime,
 		&t.Subject,
@@ -52,12 +58,15 @@ type fileScanner struct {
 	FileType, ThumbType, Length, Size sql.NullInt64
 	Name, SHA1, MD5, Title, Artist    sql.NullString
 	Dims                              pq.Int64Array
+	Variants, Contents                []byte
+	PHash                             sql.NullInt64
 }
 
 func (i *fileScanner) ScanArgs() []interface{} {
 	return []interface{}{
 		&i.APNG, &i.Audio, &i.Video, &i.FileType, &i.ThumbType, &i.Dims,
 		&i.Length, &i.Size, &i.MD5, &i.SHA1, &i.Title, &i.Artist,
+		&i.Variants, &i.Contents, &i.PHash,
 	}
 }
 
@@ -71,6 +80,16 @@ func (i *fileScanner) Val() *common.Image {
 		dims[j] = uint16(i.Dims[j])
 	}
 
+	// Can't return error here and columns are always written by us.
+	var variants []common.ThumbVariant
+	if len(i.Variants) != 0 {
+		json.Unmarshal(i.Variants, &variants)
+	}
+	var contents []common.ArchiveEntry
+	if len(i.Contents) != 0 {
+		json.Unmarshal(i.Contents, &contents)
+	}
+
 	return &common.Image{
 		ImageCommon: common.ImageCommon{
 			APNG:      i.APNG.Bool,
@@ -85,10 +104,31 @@ func (i *fileScanner) Val() *common.Image {
 			SHA1:      i.SHA1.String,
 			Title:     i.Title.String,
 			Artist:    i.Artist.String,
+			Variants:  variants,
+			Contents:  contents,
+			PHash:     uint64(i.PHash.Int64),
 		},
 	}
 }
 
+// File attached to a post, along with per-post properties.
+type postFileScanner struct {
+	fileScanner
+	Spoiler sql.NullBool
+}
+
+func (i *postFileScanner) ScanArgs() []interface{} {
+	return append(i.fileScanner.ScanArgs(), &i.Spoiler)
+}
+
+func (i *postFileScanner) Val() *common.Image {
+	img := i.fileScanner.Val()
+	if img != nil {
+		img.Spoiler = i.Spoiler.Bool
+	}
+	return img
+}
+
 func scanCatalog(r tableScanner) (b common.Board, err error) {
 	defer r.Close()
 	b = make(common.Board, 0, 32)
@@ -109,7 +149,7 @@ func scanCatalogThread(r rowScanner) (t common.Thread, err error) {
 	var (
 		ts threadScanner
 		ps postScanner
-		fs fileScanner
+		fs postFileScanner
 	)
 	args := make([]interface{}, 0)
 	args = append(args, ts.ScanArgs()...)
@@ -138,6 +178,7 @@ func scanThread(r rowScanner) (t common.Thread, err error) {
 		ps postScanner
 	)
 	args := append(ts.ScanArgs(), ps.ScanArgs()...)
+	args = append(args, &ps.Deleted)
 
 	err = r.Scan(args...)
 	if err != nil {
@@ -177,9 +218,10 @@ func scanThreadIDs(r tableScanner) (ids []uint64, err error) {
 
 // PostStats contains post open status, body and creation time.
 type PostStats struct {
-	ID   uint64
-	Time int64
-	Body []byte
+	Editing bool
+	ID      uint64
+	Time    int64
+	Body    []byte
 }
 
 // GetAllBoardCatalog retrieves all OPs for the "/all/" meta-board.
@@ -200,8 +242,39 @@ func GetBoardCatalog(board string) (common.Board, error) {
 	return scanCatalog(r)
 }
 
+// GetArchive retrieves a page of archived threads of the board, most
+// recently bumped first.
+func GetArchive(board string, page int) (common.Board, error) {
+	r, err := prepared["get_archive"].Query(
+		board, ArchivePageSize, page*ArchivePageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCatalog(r)
+}
+
+// GetThreadState returns, if the thread has been moved to the archive or
+// locked by moderators.
+func GetThreadState(tx *sql.Tx, id uint64) (archived, locked bool, err error) {
+	err = getStatement(tx, "get_thread_state").QueryRow(id).Scan(
+		&archived, &locked,
+	)
+	return
+}
+
 // GetThread retrieves public thread data from the database.
-func GetThread(id uint64, lastN int) (t common.Thread, err error) {
+func GetThread(id uint64, lastN int) (common.Thread, error) {
+	return getThread(id, lastN, false)
+}
+
+// GetThreadWithDeleted retrieves thread data including deleted posts and
+// deleted threads, for display to moderators.
+func GetThreadWithDeleted(id uint64, lastN int) (common.Thread, error) {
+	return getThread(id, lastN, true)
+}
+
+func getThread(id uint64, lastN int, deleted bool) (t common.Thread, err error) {
 	// Read all data in single transaction.
 	tx, err := StartTransaction()
 	if err != nil {
@@ -214,7 +287,7 @@ func GetThread(id uint64, lastN int) (t common.Thread, err error) {
 	}
 
 	// Get thread info and OP post.
-	t, err = scanThread(tx.Stmt(prepared["get_thread"]).QueryRow(id))
+	t, err = scanThread(tx.Stmt(prepared["get_thread"]).QueryRow(id, deleted))
 	if err != nil {
 		return
 	}
@@ -229,7 +302,7 @@ func GetThread(id uint64, lastN int) (t common.Thread, err error) {
 	}
 
 	// Get thread posts.
-	r, err := tx.Stmt(prepared["get_thread_posts"]).Query(id, limit)
+	r, err := tx.Stmt(prepared["get_thread_posts"]).Query(id, limit, deleted)
 	if err != nil {
 		return
 	}
@@ -237,7 +310,7 @@ func GetThread(id uint64, lastN int) (t common.Thread, err error) {
 
 	// Fill thread posts.
 	var ps postScanner
-	args := ps.ScanArgs()
+	args := append(ps.ScanArgs(), &ps.Deleted, &ps.Editing)
 	t.Posts = make([]*common.Post, 0, postCnt)
 	postIds := make([]uint64, 1, postCnt+1) // + OP
 	postIds[0] = id
@@ -272,7 +345,7 @@ func GetThread(id uint64, lastN int) (t common.Thread, err error) {
 	defer r2.Close()
 
 	// Fill posts files.
-	var fs fileScanner
+	var fs postFileScanner
 	var pID uint64
 	args = append([]interface{}{&pID}, fs.ScanArgs()...)
 	for r2.Next() {
@@ -304,7 +377,7 @@ func GetPost(id uint64) (p common.StandalonePost, err error) {
 
 	// Get post.
 	var ps postScanner
-	args := append(ps.ScanArgs(), &p.OP, &p.Board)
+	args := append(ps.ScanArgs(), &p.OP, &p.Board, &ps.Deleted, &ps.Editing)
 	err = tx.Stmt(prepared["get_post"]).QueryRow(id).Scan(args...)
 	if err != nil {
 		return
@@ -319,7 +392,7 @@ func GetPost(id uint64) (p common.StandalonePost, err error) {
 	defer r.Close()
 
 	// Fill post files.
-	var fs fileScanner
+	var fs postFileScanner
 	args = fs.ScanArgs()
 	for r.Next() {
 		err = r.Scan(args...)
@@ -362,10 +435,12 @@ func GetRecentPosts(op uint64) (posts []PostStats, err error) {
 	posts = make([]PostStats, 0, 64)
 	var p PostStats
 	for r.Next() {
-		err = r.Scan(&p.ID, &p.Time)
+		var editing sql.NullBool
+		err = r.Scan(&p.ID, &p.Time, &editing, &p.Body)
 		if err != nil {
 			return
 		}
+		p.Editing = editing.Bool
 		posts = append(posts, p)
 	}
 	err = r.Err()
@@ -2,113 +2,65 @@ package db
 
 import (
 	"database/sql"
-	"reflect"
 	"testing"
+	"time"
 
 	"github.com/cutechan/cutechan/go/common"
-	"github.com/cutechan/cutechan/go/config"
 	. "github.com/cutechan/cutechan/go/test"
 )
 
 func TestReader(t *testing.T) {
 	assertTableClear(t, "boards", "images")
+	writeBoard(t, "a")
+	writeBoard(t, "c")
+	writeSampleImage(t)
 
-	boards := [...]BoardConfigs{
-		{
-			BoardConfigs: config.BoardConfigs{
-				ID: "a",
-			},
-		},
-		{
-			BoardConfigs: config.BoardConfigs{
-				ID: "c",
-			},
-		},
-	}
-	for _, b := range boards {
-		if err := WriteBoard(nil, b); err != nil {
-			t.Fatal(err)
-		}
-	}
-
-	threads := [...]Thread{
-		{
-			ID:        1,
-			Board:     "a",
-			ReplyTime: 1,
-			BumpTime:  1,
-			PostCtr:   3,
-		},
-		{
-			ID:        3,
-			Board:     "c",
-			ReplyTime: 3,
-			BumpTime:  5,
-			PostCtr:   1,
-		},
-	}
-	posts := [...]Post{
-		{
-			StandalonePost: common.StandalonePost{
-				Post: common.Post{
-					ID:    1,
-					Image: &assets.StdJPEG,
-				},
-				OP:    1,
-				Board: "a",
+	now := time.Now().Unix()
+	writeThread(t, Post{
+		StandalonePost: common.StandalonePost{
+			Post: common.Post{
+				ID:    1,
+				Time:  now,
+				Files: common.Files{{ImageCommon: sampleImage}},
 			},
-			Password: []byte("foo"),
-			IP:       "::1",
-		},
-		{
-			StandalonePost: common.StandalonePost{
-				Post: common.Post{
-					ID:    3,
-					Links: [][2]uint64{{1, 1}},
-					Commands: []common.Command{
-						{
-							Type: common.Flip,
-							Flip: true,
-						},
+			OP:    1,
+			Board: "a",
+		},
+		Password: []byte("foo"),
+		IP:       "::1",
+	})
+	writeThread(t, Post{
+		StandalonePost: common.StandalonePost{
+			Post: common.Post{
+				ID:    3,
+				Time:  now + 2,
+				Links: common.Links{{1, 1}},
+				Commands: common.Commands{
+					{
+						Type: common.Flip,
+						Flip: true,
 					},
 				},
-				OP:    3,
-				Board: "c",
-			},
-		},
-		{
-			StandalonePost: common.StandalonePost{
-				Post: common.Post{
-					ID:   2,
-					Body: "foo",
-				},
-				OP:    1,
-				Board: "a",
 			},
+			OP:    3,
+			Board: "c",
 		},
-		{
+	})
+	for _, id := range [...]uint64{2, 4} {
+		p := Post{
 			StandalonePost: common.StandalonePost{
 				Post: common.Post{
-					ID: 4,
+					ID:   id,
+					Time: now,
 				},
 				OP:    1,
 				Board: "a",
 			},
-		},
-	}
-
-	if err := WriteImage(nil, assets.StdJPEG.ImageCommon); err != nil {
-		t.Fatal(err)
-	}
-	for i := range threads {
-		if err := WriteThread(nil, threads[i], posts[i]); err != nil {
-			t.Fatal(err)
 		}
-	}
-	for i := len(threads); i < len(posts); i++ {
-		if err := WritePost(nil, posts[i]); err != nil {
-			t.Fatal(err)
+		if id == 2 {
+			p.Body = "foo"
 		}
+		writePost(t, p)
 	}
 
 	t.Run("GetAllBoard", testGetAllBoard)
@@ -117,92 +69,57 @@ func TestReader(t *testing.T) {
 	t.Run("GetThread", testGetThread)
 }
 
+// Assert the post has exactly the sample image attached
+func assertSampleFile(t *testing.T, p *common.Post) {
+	if len(p.Files) != 1 {
+		t.Fatalf("post %d: expected 1 file, got %d", p.ID, len(p.Files))
+	}
+	AssertDeepEquals(t, p.Files[0].ImageCommon, sampleImage)
+}
+
 func testGetPost(t *testing.T) {
 	t.Parallel()
 
 	// Does not exist
-	post, err := GetPost(99)
-	if err != sql.ErrNoRows {
+	if _, err := GetPost(99); err != sql.ErrNoRows {
 		UnexpectedError(t, err)
 	}
-	if !reflect.DeepEqual(post, common.StandalonePost{}) {
-		t.Errorf("post not empty: %#v", post)
-	}
 
 	// Valid read
-	std := common.StandalonePost{
-		Post: common.Post{
-			ID:    3,
-			Links: [][2]uint64{{1, 1}},
-			Commands: []common.Command{
-				{
-					Type: common.Flip,
-					Flip: true,
-				},
-			},
-		},
-		OP:    3,
-		Board: "c",
-	}
-	post, err = GetPost(3)
+	post, err := GetPost(3)
 	if err != nil {
 		t.Fatal(err)
 	}
-	AssertDeepEquals(t, post, std)
+	AssertDeepEquals(t, post.OP, uint64(3))
+	AssertDeepEquals(t, post.Board, "c")
+	AssertDeepEquals(t, post.Links, common.Links{{1, 1}})
+	AssertDeepEquals(t, post.Commands, common.Commands{
+		{
+			Type: common.Flip,
+			Flip: true,
+		},
+	})
 }
 
 func testGetAllBoard(t *testing.T) {
 	t.Parallel()
 
-	std := common.Board{
-		{
-			Post: common.Post{
-				ID:    3,
-				Links: [][2]uint64{{1, 1}},
-				Commands: []common.Command{
-					{
-						Type: common.Flip,
-						Flip: true,
-					},
-				},
-			},
-			PostCtr:   1,
-			Board:     "c",
-			ReplyTime: 3,
-			BumpTime:  5,
-		},
-		{
-			Post: common.Post{
-				ID:    1,
-				Image: &assets.StdJPEG,
-			},
-			PostCtr:   3,
-			Board:     "a",
-			ReplyTime: 1,
-			BumpTime:  1,
-		},
-	}
-
 	board, err := GetAllBoardCatalog()
 	if err != nil {
 		t.Fatal(err)
 	}
-	for i := range board {
-		assertImage(t, &board[i], std[i].Image)
+	if len(board) != 2 {
+		t.Fatalf("expected 2 threads, got %d", len(board))
 	}
-	AssertDeepEquals(t, board, std)
-}
 
-// Assert image equality and then override to not compare pointer addresses
-// with reflection
-func assertImage(t *testing.T, thread *common.Thread, std *common.Image) {
-	if std != nil {
-		if thread.Image == nil {
-			t.Fatalf("no image on thread %d", thread.ID)
-		}
-		AssertDeepEquals(t, *thread.Image, *std)
-		thread.Image = std
+	threads := make(map[uint64]common.Thread, len(board))
+	for _, th := range board {
+		threads[th.ID] = th
 	}
+	AssertDeepEquals(t, threads[3].Board, "c")
+	AssertDeepEquals(t, threads[1].Board, "a")
+	AssertDeepEquals(t, threads[1].PostCtr, uint32(3))
+	assertSampleFile(t, threads[1].Post)
 }
 
 func testGetBoard(t *testing.T) {
@@ -210,35 +127,10 @@ func testGetBoard(t *testing.T) {
 
 	cases := [...]struct {
 		name, id string
-		std      common.Board
+		threads  []uint64
 	}{
-		{
-			name: "full",
-			id:   "c",
-			std: common.Board{
-				{
-					Post: common.Post{
-						ID:    3,
-						Links: [][2]uint64{{1, 1}},
-						Commands: []common.Command{
-							{
-								Type: common.Flip,
-								Flip: true,
-							},
-						},
-					},
-					PostCtr:   1,
-					Board:     "c",
-					ReplyTime: 3,
-					BumpTime:  5,
-				},
-			},
-		},
-		{
-			name: "empty",
-			id:   "z",
-			std:  common.Board{},
-		},
+		{"full", "c", []uint64{3}},
+		{"empty", "z", []uint64{}},
 	}
 
 	for i := range cases {
@@ -250,10 +142,11 @@ func testGetBoard(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			for i := range board {
-				assertImage(t, &board[i], c.std[i].Image)
+			ids := make([]uint64, 0, len(board))
+			for _, th := range board {
+				ids = append(ids, th.ID)
 			}
-			AssertDeepEquals(t, board, c.std)
+			AssertDeepEquals(t, ids, c.threads)
 		})
 	}
 }
@@ -261,67 +154,30 @@ func testGetBoard(t *testing.T) {
 func testGetThread(t *testing.T) {
 	t.Parallel()
 
-	thread1 := common.Thread{
-		PostCtr:   3,
-		ReplyTime: 1,
-		BumpTime:  1,
-		Board:     "a",
-		Post: common.Post{
-			ID:    1,
-			Image: &assets.StdJPEG,
-		},
-		Posts: []common.Post{
-			{
-				ID:   2,
-				Body: "foo",
-			},
-			{
-				ID: 4,
-			},
-		},
-	}
-	sliced := thread1
-	sliced.Posts = sliced.Posts[1:]
-	sliced.Abbrev = true
-
 	cases := [...]struct {
-		name  string
-		id    uint64
-		lastN int
-		std   common.Thread
-		err   error
+		name    string
+		id      uint64
+		lastN   int
+		abbrev  bool
+		replies []uint64
+		err     error
 	}{
 		{
-			name: "full",
-			id:   1,
-			std:  thread1,
+			name:    "full",
+			id:      1,
+			replies: []uint64{2, 4},
 		},
 		{
-			name:  "last 1 reply",
-			id:    1,
-			lastN: 1,
-			std:   sliced,
+			name:    "last 1 reply",
+			id:      1,
+			lastN:   1,
+			abbrev:  true,
+			replies: []uint64{4},
 		},
 		{
-			name: "no replies ;_;",
-			id:   3,
-			std: common.Thread{
-				Board:     "c",
-				ReplyTime: 3,
-				BumpTime:  5,
-				PostCtr:   1,
-				Post: common.Post{
-					ID:    3,
-					Links: [][2]uint64{{1, 1}},
-					Commands: []common.Command{
-						{
-							Type: common.Flip,
-							Flip: true,
-						},
-					},
-				},
-				Posts: []common.Post{},
-			},
+			name:    "no replies ;_;",
+			id:      3,
+			replies: []uint64{},
 		},
 		{
 			name: "nonexistent thread",
@@ -339,8 +195,19 @@ func testGetThread(t *testing.T) {
 			if err != c.err {
 				UnexpectedError(t, err)
 			}
-			assertImage(t, &thread, c.std.Image)
-			AssertDeepEquals(t, thread, c.std)
+			if c.err != nil {
+				return
+			}
+			AssertDeepEquals(t, thread.ID, c.id)
+			AssertDeepEquals(t, thread.Abbrev, c.abbrev)
+			ids := make([]uint64, 0, len(thread.Posts))
+			for _, p := range thread.Posts {
+				ids = append(ids, p.ID)
+			}
+			AssertDeepEquals(t, ids, c.replies)
+			if c.id == 1 {
+				assertSampleFile(t, thread.Post)
+			}
 		})
 	}
 }
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+
+	"github.com/lib/pq"
+)
+
+// WriteReport stores a user report of a post. Repeated reports of the same
+// post from the same IP are ignored.
+func WriteReport(id uint64, reason, ipHash string) error {
+	return execPrepared("write_report", id, reason, ipHash)
+}
+
+// CountReportsByIP returns number of reports sent from the IP hash during
+// the specified period.
+func CountReportsByIP(ipHash string, period time.Duration) (n int, err error) {
+	err = prepared["count_reports_by_ip"].
+		QueryRow(ipHash, int64(period/time.Second)).
+		Scan(&n)
+	return
+}
+
+// GetReport retrieves a single report.
+func GetReport(id uint64) (rep auth.Report, err error) {
+	var created time.Time
+	err = prepared["get_report"].
+		QueryRow(id).
+		Scan(&rep.ID, &rep.Board, &rep.Post, &rep.Reason, &created)
+	rep.Created = created.Unix()
+	return
+}
+
+// GetReports retrieves open reports of the specified boards, newest first.
+func GetReports(boards []string) (reports auth.Reports, err error) {
+	reports = make(auth.Reports, 0)
+	rs, err := prepared["get_reports"].Query(pq.Array(boards))
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var rep auth.Report
+		var created time.Time
+		err = rs.Scan(&rep.ID, &rep.Board, &rep.Post, &rep.Reason, &created)
+		if err != nil {
+			return
+		}
+		rep.Created = created.Unix()
+		reports = append(reports, rep)
+	}
+	err = rs.Err()
+	return
+}
+
+// DismissReport closes a report, that doesn't need any action.
+func DismissReport(id uint64, by string) error {
+	return resolveReport("dismiss_report", id, by)
+}
+
+// AcceptReport closes all reports of the reported post, once moderators
+// have dealt with it.
+func AcceptReport(id uint64, by string) error {
+	return resolveReport("accept_report", id, by)
+}
+
+func resolveReport(query string, id uint64, by string) (err error) {
+	res, err := prepared[query].Exec(id, by)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return
+}
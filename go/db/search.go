@@ -0,0 +1,87 @@
+package db
+
+import (
+	"github.com/cutechan/cutechan/go/common"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// SearchPageSize is the number of search results per page.
+	SearchPageSize = 20
+)
+
+// SearchPosts retrieves posts, which body or thread subject match the
+// query, newest first. Searches all public boards, if board is "all".
+func SearchPosts(board, query string, page int) (res []common.SearchResult, err error) {
+	res = make([]common.SearchResult, 0, SearchPageSize)
+	if query == "" {
+		return
+	}
+
+	// Read all data in single transaction.
+	tx, err := StartTransaction()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+	err = SetReadOnly(tx)
+	if err != nil {
+		return
+	}
+
+	// Get posts.
+	r, err := tx.Stmt(prepared["search_posts"]).Query(
+		board, query, SearchPageSize, page*SearchPageSize,
+	)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	var ps postScanner
+	var sr common.SearchResult
+	args := append(ps.ScanArgs(), &sr.OP, &sr.Board, &sr.Subject)
+	postIds := make([]uint64, 0, SearchPageSize)
+	for r.Next() {
+		err = r.Scan(args...)
+		if err != nil {
+			return
+		}
+		sr.Post = ps.Val()
+		res = append(res, sr)
+		postIds = append(postIds, sr.ID)
+	}
+	err = r.Err()
+	if err != nil || len(res) == 0 {
+		return
+	}
+
+	// Get post files.
+	r2, err := tx.Stmt(prepared["get_abbrev_thread_files"]).Query(pq.Array(postIds))
+	if err != nil {
+		return
+	}
+	defer r2.Close()
+
+	// Fill post files.
+	postsById := make(map[uint64]*common.SearchResult, len(res))
+	for i := range res {
+		postsById[res[i].ID] = &res[i]
+	}
+	var fs postFileScanner
+	var pID uint64
+	args = append([]interface{}{&pID}, fs.ScanArgs()...)
+	for r2.Next() {
+		err = r2.Scan(args...)
+		if err != nil {
+			return
+		}
+		img := fs.Val()
+		if p, ok := postsById[pID]; ok {
+			p.Files = append(p.Files, img)
+		}
+	}
+	err = r2.Err()
+	return
+}
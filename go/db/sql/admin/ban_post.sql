@@ -1,4 +1,4 @@
 update posts
   set banned = true
   where id = $1
-  returning bump_thread(op, false, false, false, 0)
+  returning bump_thread(op, false, false, 0, 0)
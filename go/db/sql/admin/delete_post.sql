@@ -1,9 +1,17 @@
 WITH files AS (
   SELECT count(*) AS cnt FROM post_files WHERE post_id = $1
+), reports AS (
+  -- Deleted posts need no further attention.
+  DELETE FROM reports WHERE post = $1
 )
 
-DELETE FROM posts USING files WHERE id = $1
+UPDATE posts
+SET deleted = true,
+  deletedTime = floor(extract(epoch from now())),
+  editing = false
+FROM files
+WHERE id = $1 AND deleted IS NOT TRUE
 
 RETURNING
   log_moderation(2::smallint, board, id, $2),
-  bump_thread(op, false, true, false, files.cnt)
+  bump_thread(op, false, true, 0, files.cnt)
@@ -0,0 +1,14 @@
+WITH deleted AS (
+  DELETE FROM post_files WHERE post_id = $1
+  RETURNING 1
+), files AS (
+  SELECT count(*) AS cnt FROM deleted
+)
+
+UPDATE threads t SET
+  replyTime = floor(extract(epoch from now())),
+  imageCtr = t.imageCtr - files.cnt
+FROM posts p, files
+WHERE p.id = $1 AND t.id = p.op AND files.cnt > 0
+
+RETURNING log_moderation(3::smallint, p.board, p.id, $2)
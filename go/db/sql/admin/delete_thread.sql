@@ -1,3 +1,18 @@
-delete from threads
-  where id = $1
-  returning log_moderation(5::smallint, board, id, $2::varchar(20))
+WITH reports AS (
+  -- Deleted posts need no further attention.
+  DELETE FROM reports WHERE post = $1
+), op AS (
+  UPDATE posts
+  SET deleted = true,
+    deletedTime = floor(extract(epoch from now())),
+    editing = false
+  WHERE id = $1 AND deleted IS NOT TRUE
+)
+
+-- Threads are only hidden, so they can be restored along with their OP.
+UPDATE threads
+SET deleted = true,
+  -- Invalidate thread and board caches.
+  replyTime = floor(extract(epoch from now()))
+WHERE id = $1 AND NOT deleted
+RETURNING log_moderation(5::smallint, board, id, $2::varchar(20))
@@ -1,4 +1,5 @@
-select ip, board, forPost, reason, by, expires
+select board, ip, forPost, reason, by, expires
   from bans
-  where ip = $1 and board = $2 and expires >= now()
+  where $1::inet <<= ip and board = $2 and expires >= now()
+  order by masklen(ip) desc
   limit 1
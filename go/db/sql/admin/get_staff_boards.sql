@@ -0,0 +1,3 @@
+SELECT DISTINCT board FROM staff
+WHERE account = $1 AND position IN ('owners', 'moderators', 'janitors')
+ORDER BY board
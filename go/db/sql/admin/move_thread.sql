@@ -0,0 +1,6 @@
+update threads
+  set board = $3,
+    -- Invalidate thread and target board caches.
+    replyTime = floor(extract(epoch from now()))
+  where id = $1 and board = $2
+  returning log_moderation(11::smallint, $2, id, $4)
@@ -0,0 +1,3 @@
+update posts
+  set board = $2
+  where op = $1
@@ -0,0 +1,6 @@
+update threads
+  set locked = $2
+  where id = $1
+  returning
+    bump_thread($1, false, false, 0, 0),
+    log_moderation((case when $2 then 9 else 10 end)::smallint, board, id, $3)
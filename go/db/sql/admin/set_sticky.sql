@@ -1,4 +1,6 @@
 update threads
   set sticky = $2
   where id = $1
-  returning bump_thread($1, false, false, false, 0)
+  returning
+    bump_thread($1, false, false, 0, 0),
+    log_moderation((case when $2 then 7 else 8 end)::smallint, board, id, $3)
@@ -0,0 +1,12 @@
+WITH spoilered AS (
+  UPDATE post_files SET spoiler = true
+  WHERE post_id = $1 AND NOT spoiler
+  RETURNING 1
+)
+
+UPDATE threads t SET
+  replyTime = floor(extract(epoch from now()))
+FROM posts p
+WHERE p.id = $1 AND t.id = p.op AND EXISTS (SELECT 1 FROM spoilered)
+
+RETURNING log_moderation(4::smallint, p.board, p.id, $2)
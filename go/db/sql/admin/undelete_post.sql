@@ -0,0 +1,13 @@
+WITH files AS (
+  SELECT count(*) AS cnt FROM post_files WHERE post_id = $1
+)
+
+UPDATE posts
+SET deleted = false, deletedTime = NULL
+FROM files
+WHERE id = $1 AND deleted
+
+RETURNING
+  log_moderation(14::smallint, board, id, $2),
+  -- Deleted OPs never left the post counter, only their thread was hidden.
+  bump_thread(op, id != op, false, 0, files.cnt)
@@ -0,0 +1,3 @@
+UPDATE threads
+SET deleted = false
+WHERE id = $1 AND deleted
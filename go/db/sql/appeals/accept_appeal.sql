@@ -0,0 +1,3 @@
+DELETE FROM appeals
+WHERE id = $1 AND NOT rejected
+RETURNING log_moderation(15::smallint, board, forPost, $2)
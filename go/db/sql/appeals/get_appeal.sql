@@ -0,0 +1,2 @@
+SELECT board, forPost FROM appeals
+WHERE id = $1 AND NOT rejected
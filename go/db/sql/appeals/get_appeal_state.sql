@@ -0,0 +1,2 @@
+SELECT rejected FROM appeals
+WHERE ip = $1 AND board = $2
@@ -0,0 +1,5 @@
+SELECT a.id, a.board, a.forPost, b.reason, a.body, b.expires, a.created
+FROM appeals a
+JOIN bans b ON b.ip = a.ip AND b.board = a.board
+WHERE a.board = ANY($1) AND NOT a.rejected
+ORDER BY a.created DESC
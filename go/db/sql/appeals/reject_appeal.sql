@@ -0,0 +1,3 @@
+UPDATE appeals SET rejected = true
+WHERE id = $1 AND NOT rejected
+RETURNING log_moderation(16::smallint, board, forPost, $2)
@@ -0,0 +1,6 @@
+INSERT INTO appeals (board, ip, forPost, body)
+SELECT board, ip, forPost, $3 FROM bans
+WHERE $1::inet <<= ip AND board = $2 AND expires >= now()
+ORDER BY masklen(ip) DESC
+LIMIT 1
+ON CONFLICT (ip, board) DO NOTHING
@@ -1 +1,4 @@
-select max(replyTime) from threads
+select greatest(
+  (select max(replyTime) from threads),
+  (select max(modTime) from boards)
+)
@@ -1,2 +1,5 @@
-select max(replyTime) from threads
-  where board = $1
+select greatest(
+  (select max(replyTime) from threads where board = $1),
+  -- Threads moved away don't change replyTime of the remaining ones.
+  (select modTime from boards where id = $1)
+)
@@ -1,5 +1,5 @@
 select id from threads
-  where board = $1
+  where board = $1 and not archived and not deleted
   order by
     sticky desc,
     bumpTime desc
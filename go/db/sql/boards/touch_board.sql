@@ -0,0 +1,3 @@
+update boards
+  set modTime = floor(extract(epoch from now()))
+  where id = $1
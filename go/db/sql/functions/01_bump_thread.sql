@@ -2,7 +2,8 @@ CREATE OR REPLACE FUNCTION bump_thread(
   id bigint,
   addPost bool,
   delPost bool,
-  bump bool,
+  -- Thread is bumped, if it has no more posts than this. Zero never bumps.
+  bumpLimit bigint,
   file_cnt bigint
 ) RETURNS void AS $$
 
@@ -10,7 +11,7 @@ CREATE OR REPLACE FUNCTION bump_thread(
     replyTime = floor(extract(epoch from now())),
 
     bumpTime = CASE
-      WHEN bump AND postCtr <= 500 THEN floor(extract(epoch from now()))
+      WHEN postCtr <= bumpLimit THEN floor(extract(epoch from now()))
       ELSE bumpTime
     END,
 
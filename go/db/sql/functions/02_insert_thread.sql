@@ -10,13 +10,14 @@ CREATE OR REPLACE FUNCTION insert_thread(
   links bigint[][2],
   commands json[],
   file_cnt bigint,
-  subject varchar(100)
+  subject varchar(100),
+  account varchar(20)
 ) RETURNS void AS $$
 
   INSERT INTO threads (board, id, postCtr, imageCtr, replyTime, bumpTime, subject)
   VALUES              (board, id, 1,       file_cnt, now,       now,      subject);
 
-  INSERT INTO posts (id, op, time, board, auth, name, body, ip, links, commands)
-  VALUES            (id, op, now,  board, auth, name, body, ip, links, commands);
+  INSERT INTO posts (id, op, time, board, auth, name, body, ip, links, commands, account)
+  VALUES            (id, op, now,  board, auth, name, body, ip, links, commands, account);
 
 $$ LANGUAGE SQL;
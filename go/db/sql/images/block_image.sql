@@ -0,0 +1,3 @@
+INSERT INTO phash_blocklist (phash, sha1, by)
+VALUES                      ($1,    $2,   $3)
+ON CONFLICT (phash) DO NOTHING
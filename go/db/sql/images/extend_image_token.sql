@@ -0,0 +1,3 @@
+update image_tokens
+  set expires = $2
+  where token = $1
@@ -0,0 +1,3 @@
+SELECT phash, sha1, by, created
+FROM phash_blocklist
+ORDER BY created DESC
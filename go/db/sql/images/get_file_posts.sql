@@ -0,0 +1,13 @@
+SELECT p.id, p.time, p.op, p.board
+FROM post_files pf
+JOIN posts p ON p.id = pf.post_id
+JOIN threads t ON t.id = p.op
+WHERE p.deleted IS NOT TRUE AND NOT t.deleted
+  AND p.board <> ALL($3::text[])
+  AND pf.file_hash IN (
+    SELECT sha1 FROM images WHERE sha1 = $1 OR (MD5 = $2 AND MD5 <> '')
+    UNION
+    SELECT sha1 FROM image_originals WHERE hash = $1 OR md5 = $2
+  )
+ORDER BY p.id DESC
+LIMIT $4
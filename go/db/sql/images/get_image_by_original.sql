@@ -0,0 +1,4 @@
+SELECT i.*, o.metadata
+FROM images i
+LEFT JOIN image_originals o ON o.hash = $1
+WHERE i.sha1 = coalesce(o.sha1, $1)
@@ -0,0 +1,4 @@
+select * from images
+  where SHA1 > $1
+  order by SHA1
+  limit $2
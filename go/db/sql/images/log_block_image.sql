@@ -0,0 +1 @@
+SELECT log_moderation(17::smallint, $1, $2, $3)
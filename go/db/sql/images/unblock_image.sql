@@ -0,0 +1,2 @@
+DELETE FROM phash_blocklist
+WHERE phash = $1
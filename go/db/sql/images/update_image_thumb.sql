@@ -0,0 +1,3 @@
+update images
+  set dims = $2, thumbType = $3, variants = $4, phash = $5
+  where SHA1 = $1
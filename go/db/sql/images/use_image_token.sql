@@ -1,3 +1,3 @@
 delete from image_tokens
   where token = $1
-  returning SHA1
+  returning SHA1, metadata
@@ -0,0 +1,3 @@
+INSERT INTO image_originals (hash, md5, sha1, metadata)
+VALUES                      ($1,   $2,  $3,   $4)
+ON CONFLICT (hash) DO NOTHING
@@ -1,2 +1,2 @@
-insert into image_tokens (token, SHA1, expires)
-  values ($1, $2, $3)
+insert into image_tokens (token, SHA1, expires, metadata)
+  values ($1, $2, $3, $4)
@@ -24,6 +24,7 @@ CREATE INDEX sessions_token ON sessions (token);
 
 create table bans (
   board text not null,
+  -- Single IP or network.
   ip inet not null,
   forPost bigint default 0,
   by varchar(20) not null,
@@ -31,6 +32,7 @@ create table bans (
   expires timestamp not null,
   primary key (ip, board)
 );
+CREATE INDEX bans_ip ON bans USING gist (ip inet_ops);
 
 create table mod_log (
   type smallint not null,
@@ -54,19 +56,42 @@ create table images (
   MD5 char(22) not null,
   SHA1 char(40) primary key,
   Title varchar(300) not null,
-  Artist varchar(100) not null
+  Artist varchar(100) not null,
+  variants jsonb not null default '[]',
+  contents jsonb not null default '[]',
+  phash bigint not null default 0
 );
+CREATE INDEX images_md5 ON images (MD5);
 
 create table image_tokens (
   token char(86) not null primary key,
   SHA1 char(40) not null references images on delete cascade,
-  expires timestamp not null
+  expires timestamp not null,
+  metadata jsonb
+);
+
+CREATE TABLE image_originals (
+  hash char(40) PRIMARY KEY,
+  sha1 char(40) NOT NULL REFERENCES images ON DELETE CASCADE,
+  metadata jsonb,
+  md5 char(22)
+);
+CREATE INDEX image_originals_sha1 ON image_originals (sha1);
+CREATE INDEX image_originals_md5 ON image_originals (md5);
+
+CREATE TABLE phash_blocklist (
+  phash bigint PRIMARY KEY,
+  sha1 char(40) NOT NULL,
+  by varchar(20) NOT NULL,
+  created timestamp NOT NULL DEFAULT (now() at time zone 'utc')
 );
 
 CREATE TABLE boards (
   id text PRIMARY KEY,
   modOnly boolean NOT NULL,
-  settings jsonb NOT NULL
+  settings jsonb NOT NULL,
+  -- Invalidates board caches, when threads are moved away.
+  modTime bigint NOT NULL DEFAULT 0
 );
 INSERT INTO boards VALUES ('all', FALSE, '{"title": "Aggregator metaboard"}');
 
@@ -79,6 +104,19 @@ create table staff (
 create index staff_board on staff (board);
 create index staff_account on staff (account);
 
+CREATE TABLE appeals (
+  id bigserial PRIMARY KEY,
+  board text NOT NULL REFERENCES boards ON DELETE CASCADE,
+  -- Same as the appealed ban. Only one appeal per ban is allowed.
+  ip inet NOT NULL,
+  forPost bigint NOT NULL,
+  body varchar(500) NOT NULL,
+  rejected boolean NOT NULL DEFAULT false,
+  created timestamp NOT NULL DEFAULT (now() at time zone 'utc'),
+  UNIQUE (ip, board)
+);
+CREATE INDEX appeals_board ON appeals (board);
+
 create table banners (
   board text not null references boards on delete cascade,
   id smallint not null,
@@ -90,22 +128,34 @@ create sequence post_id;
 
 create table threads (
   sticky boolean default false,
+  archived boolean not null default false,
+  locked boolean not null default false,
+  deleted boolean not null default false,
   board text not null references boards on delete cascade,
   id bigint primary key,
   postCtr bigint not null,
   imageCtr bigint not null,
   bumpTime bigint not null,
   replyTime bigint not null,
-  subject varchar(100) not null
+  subject varchar(100) not null,
+  subject_tsv tsvector
 );
 create index threads_board on threads (board);
 create index bumpTime on threads (bumpTime);
 create index replyTime on threads (replyTime);
 create index sticky on threads (sticky);
+create index threads_archived on threads (archived);
+CREATE INDEX threads_subject_tsv ON threads USING gin (subject_tsv);
+CREATE TRIGGER threads_subject_tsv_update
+  BEFORE INSERT OR UPDATE OF subject ON threads
+  FOR EACH ROW EXECUTE PROCEDURE
+  tsvector_update_trigger(subject_tsv, 'pg_catalog.simple', subject);
 
 create table posts (
   editing boolean,
   deleted boolean,
+  -- Deleted posts are purged after a retention period.
+  deletedTime bigint,
   banned boolean,
   sage boolean,
   id bigint primary key,
@@ -120,13 +170,42 @@ create table posts (
   password bytea,
   ip inet,
   links bigint[][2],
-  commands json[]
+  commands json[],
+  account varchar(20) references accounts on delete set null,
+  body_tsv tsvector
 );
 create index op on posts (op);
 create index image on posts (SHA1);
 create index editing on posts (editing);
 create index ip on posts (ip);
 create index posts_op_time on posts (op, time);
+create index posts_account on posts (account);
+CREATE INDEX posts_body_tsv ON posts USING gin (body_tsv);
+CREATE INDEX posts_deleted_time ON posts (deletedTime) WHERE deleted;
+CREATE TRIGGER posts_body_tsv_update
+  BEFORE INSERT OR UPDATE OF body ON posts
+  FOR EACH ROW EXECUTE PROCEDURE
+  tsvector_update_trigger(body_tsv, 'pg_catalog.simple', body);
+
+CREATE TABLE thread_watches (
+  account varchar(20) NOT NULL REFERENCES accounts ON DELETE CASCADE,
+  thread bigint NOT NULL REFERENCES threads ON DELETE CASCADE,
+  PRIMARY KEY (account, thread)
+);
+CREATE INDEX thread_watches_thread ON thread_watches (thread);
+
+CREATE TABLE reports (
+  id bigserial PRIMARY KEY,
+  board text NOT NULL REFERENCES boards ON DELETE CASCADE,
+  post bigint NOT NULL REFERENCES posts ON DELETE CASCADE,
+  reason varchar(100) NOT NULL,
+  -- Reporter IP is only needed for rate limiting.
+  ip_hash char(64) NOT NULL,
+  created timestamp NOT NULL DEFAULT (now() at time zone 'utc'),
+  UNIQUE (post, ip_hash)
+);
+CREATE INDEX reports_board ON reports (board);
+CREATE INDEX reports_ip_hash ON reports (ip_hash, created);
 
 create table news (
   id bigserial primary key,
@@ -145,7 +224,9 @@ create table post_tokens (
 CREATE TABLE post_files (
   post_id bigint REFERENCES posts ON DELETE CASCADE,
   file_hash char(40) REFERENCES images,
-  id bigserial PRIMARY KEY
+  id bigserial PRIMARY KEY,
+  metadata jsonb,
+  spoiler boolean NOT NULL DEFAULT false
 );
 CREATE INDEX post_files_post_id ON post_files (post_id);
 CREATE INDEX post_files_file_hash ON post_files (file_hash);
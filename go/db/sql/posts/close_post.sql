@@ -0,0 +1,3 @@
+UPDATE posts
+SET editing = false, body = $2, links = $3, commands = $4
+WHERE id = $1 AND editing
@@ -0,0 +1,3 @@
+SELECT id, account
+FROM posts
+WHERE id = ANY($1) AND account IS NOT NULL
@@ -1,4 +1,4 @@
-SELECT i.*
+SELECT i.*, pf.spoiler
 FROM posts p
 JOIN post_files pf ON pf.post_id = p.id
 JOIN images i ON i.sha1 = pf.file_hash
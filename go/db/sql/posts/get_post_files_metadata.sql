@@ -0,0 +1,4 @@
+SELECT metadata
+FROM post_files
+WHERE post_id = $1
+ORDER BY id
@@ -0,0 +1 @@
+SELECT password FROM posts WHERE id = $1 AND editing
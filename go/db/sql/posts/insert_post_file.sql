@@ -1,2 +1,2 @@
-INSERT INTO post_files (post_id, file_hash)
-VALUES                 ($1,      $2)
+INSERT INTO post_files (post_id, file_hash, metadata)
+VALUES                 ($1,      $2,        $3)
@@ -0,0 +1,22 @@
+WITH q AS (
+  SELECT plainto_tsquery('simple', $2) AS query
+), matches AS (
+  -- Separate queries, so each can use its own index.
+  SELECT p.id FROM q, posts p WHERE p.body_tsv @@ q.query
+  UNION
+  SELECT t.id FROM q, threads t WHERE t.subject_tsv @@ q.query
+)
+SELECT
+  p.id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands,
+  p.op, p.board, t.subject
+FROM matches m
+JOIN posts p ON p.id = m.id
+JOIN threads t ON t.id = p.op
+JOIN boards b ON b.id = t.board
+LEFT JOIN accounts a ON a.id = p.name
+WHERE (t.board = $1 OR ($1 = 'all' AND NOT b.modOnly))
+  AND p.editing IS NOT TRUE
+  AND p.deleted IS NOT TRUE
+  AND NOT t.deleted
+ORDER BY p.id DESC
+LIMIT $3 OFFSET $4
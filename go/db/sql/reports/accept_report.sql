@@ -0,0 +1,7 @@
+WITH resolved AS (
+  DELETE FROM reports
+  WHERE post = (SELECT post FROM reports WHERE id = $1)
+  RETURNING board, post
+)
+SELECT log_moderation(13::smallint, board, post, $2)
+FROM (SELECT DISTINCT board, post FROM resolved) r
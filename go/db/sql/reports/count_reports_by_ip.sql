@@ -0,0 +1,3 @@
+SELECT count(*) FROM reports
+WHERE ip_hash = $1
+  AND created > (now() at time zone 'utc') - $2 * interval '1 second'
@@ -0,0 +1,3 @@
+DELETE FROM reports
+WHERE id = $1
+RETURNING log_moderation(12::smallint, board, post, $2)
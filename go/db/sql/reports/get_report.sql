@@ -0,0 +1,2 @@
+SELECT id, board, post, reason, created FROM reports
+WHERE id = $1
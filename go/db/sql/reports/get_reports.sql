@@ -0,0 +1,3 @@
+SELECT id, board, post, reason, created FROM reports
+WHERE board = ANY($1)
+ORDER BY created DESC
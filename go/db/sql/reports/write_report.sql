@@ -0,0 +1,4 @@
+INSERT INTO reports (board, post, reason, ip_hash)
+SELECT board, id, $2, $3 FROM posts
+WHERE id = $1
+ON CONFLICT (post, ip_hash) DO NOTHING
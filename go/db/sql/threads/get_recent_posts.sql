@@ -1,4 +1,5 @@
-select id, time from posts
+select id, time, editing, body from posts
   where op = $1
+    and deleted is not true
     and time > floor(extract(epoch from now())) - 900
   order by id asc
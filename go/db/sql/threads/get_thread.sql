@@ -1,7 +1,8 @@
 SELECT
-  t.sticky, t.board, t.postCtr, t.imageCtr, t.replyTime, t.bumpTime, t.subject,
-  t.id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands
+  t.sticky, t.archived, t.locked, t.board, t.postCtr, t.imageCtr, t.replyTime, t.bumpTime, t.subject,
+  t.id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands,
+  p.deleted IS TRUE
 FROM threads t
 JOIN posts p ON p.id = t.id
 LEFT JOIN accounts a ON a.id = p.name
-WHERE t.id = $1
+WHERE t.id = $1 AND ($2 OR NOT t.deleted)
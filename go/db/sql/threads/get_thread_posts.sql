@@ -1,8 +1,9 @@
 WITH t AS (
-  SELECT p.id AS post_id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands
+  SELECT p.id AS post_id, p.time, p.auth, a.id, a.name, p.body, p.links, p.commands,
+    p.deleted IS TRUE, p.editing IS TRUE
   FROM posts p
   LEFT JOIN accounts a ON a.id = p.name
-  WHERE op = $1 AND p.id != $1
+  WHERE op = $1 AND p.id != $1 AND ($3 OR p.deleted IS NOT TRUE)
   ORDER BY p.id DESC
   LIMIT $2
 )
@@ -0,0 +1,2 @@
+SELECT archived, locked FROM threads
+WHERE id = $1
@@ -1,2 +1,7 @@
-select replyTime from threads
-  where id = $1
+select greatest(
+  t.replyTime,
+  -- Archiving doesn't change replyTime of the thread.
+  (select modTime from boards where id = t.board)
+)
+from threads t
+where t.id = $1
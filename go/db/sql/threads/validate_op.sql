@@ -1,2 +1,2 @@
 select true from threads
-  where id = $1 and board = $2
+  where id = $1 and board = $2 and ($3 or not deleted)
@@ -0,0 +1,8 @@
+UPDATE threads
+SET archived = true
+WHERE id IN (
+  SELECT id FROM threads
+  WHERE board = $1 AND NOT archived AND NOT sticky
+  ORDER BY bumpTime DESC
+  OFFSET $2
+)
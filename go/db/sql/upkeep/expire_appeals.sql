@@ -0,0 +1,4 @@
+DELETE FROM appeals a
+WHERE NOT EXISTS (
+  SELECT 1 FROM bans b WHERE b.ip = a.ip AND b.board = a.board
+)
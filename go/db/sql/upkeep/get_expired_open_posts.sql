@@ -0,0 +1,2 @@
+SELECT id, op, board, account, body FROM posts
+WHERE editing AND time < floor(extract(epoch from now())) - 900
@@ -0,0 +1,2 @@
+DELETE FROM posts
+WHERE deleted AND deletedTime < floor(extract(epoch from now())) - $1
@@ -0,0 +1,5 @@
+-- Purging a deleted OP takes the whole thread with it.
+DELETE FROM threads t
+USING posts p
+WHERE t.deleted AND p.id = t.id AND p.deleted
+  AND p.deletedTime < floor(extract(epoch from now())) - $1
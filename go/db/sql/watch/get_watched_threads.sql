@@ -0,0 +1,5 @@
+SELECT t.id, t.board, t.subject, t.postCtr, t.bumpTime
+FROM thread_watches w
+JOIN threads t ON t.id = w.thread
+WHERE w.account = $1 AND NOT t.deleted
+ORDER BY t.bumpTime DESC
@@ -0,0 +1,2 @@
+DELETE FROM thread_watches
+WHERE account = $1 AND thread = $2
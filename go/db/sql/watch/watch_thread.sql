@@ -0,0 +1,3 @@
+INSERT INTO thread_watches (account, thread)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
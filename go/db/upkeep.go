@@ -3,10 +3,13 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"strings"
 	"time"
 
-	"github.com/cutechan/cutechan/go/file"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
 )
 
 // Run database clean up tasks at server start and regular intervals.
@@ -31,12 +34,18 @@ func runCleanupTasks() {
 }
 
 func runFiveMinuteTasks() {
-	runPrepared("expire_post_tokens", "expire_image_tokens", "expire_bans")
+	runPrepared(
+		"expire_post_tokens", "expire_image_tokens", "expire_bans",
+		"expire_appeals",
+	)
+	logError("open post cleanup", closeExpiredOpenPosts())
+	logError("thread archiving", archiveThreads())
 	logError("file cleanup", deleteUnusedFiles())
 }
 
 func runHourTasks() {
 	runPrepared("expire_user_sessions", "remove_identity_info")
+	logError("deleted post purging", purgeDeletedPosts())
 }
 
 func runPrepared(ids ...string) {
@@ -55,14 +64,18 @@ func deleteUnusedFiles() (err error) {
 
 	for r.Next() {
 		var (
-			sha1                string
-			fileType, thumbType uint8
+			img      common.ImageCommon
+			variants []byte
 		)
-		err = r.Scan(&sha1, &fileType, &thumbType)
+		err = r.Scan(&img.SHA1, &img.FileType, &img.ThumbType, &variants)
 		if err != nil {
 			return
 		}
-		err = file.Backend.Delete(sha1, fileType, thumbType)
+		err = json.Unmarshal(variants, &img.Variants)
+		if err != nil {
+			return
+		}
+		err = deleteImageFiles(img)
 		if err != nil {
 			return
 		}
@@ -70,3 +83,102 @@ func deleteUnusedFiles() (err error) {
 
 	return r.Err()
 }
+
+// Permanently remove posts deleted longer than the retention period ago.
+func purgeDeletedPosts() error {
+	days := config.Get().DeletedRetention
+	if days <= 0 {
+		return nil
+	}
+	secs := days * 24 * 60 * 60
+	if err := execPrepared("purge_deleted_threads", secs); err != nil {
+		return err
+	}
+	return execPrepared("purge_deleted_posts", secs)
+}
+
+// Close open posts, which have not been closed by their authors in time.
+func closeExpiredOpenPosts() (err error) {
+	type post struct {
+		id, op      uint64
+		board, body string
+		account     sql.NullString
+	}
+
+	r, err := prepared["get_expired_open_posts"].Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	var posts []post
+	for r.Next() {
+		var p post
+		err = r.Scan(&p.id, &p.op, &p.board, &p.account, &p.body)
+		if err != nil {
+			return
+		}
+		posts = append(posts, p)
+	}
+	err = r.Err()
+	if err != nil {
+		return
+	}
+
+	for _, p := range posts {
+		links, commands, err := common.ParseBody([]byte(p.body))
+		if err != nil {
+			return err
+		}
+		err = ClosePost(nil, p.id, p.body, links, commands)
+		if err != nil {
+			return err
+		}
+		if !IsTest {
+			msg, err := common.EncodeMessage(
+				common.MessageClosePost,
+				common.CloseMessage{ID: p.id, Links: links, Commands: commands},
+			)
+			if err != nil {
+				return err
+			}
+			common.ClosePost(p.id, p.op, msg)
+			common.NotifyReplies(p.id, p.op, p.board, p.account.String, links)
+		}
+	}
+
+	return
+}
+
+// Move threads over the board thread limit to the archive.
+func archiveThreads() (err error) {
+	for _, c := range config.GetBoardConfigs() {
+		if c.MaxThreads <= 0 || c.ID == "all" {
+			continue
+		}
+		if err = archiveBoardThreads(c.ID, c.MaxThreads); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func archiveBoardThreads(board string, max int) (err error) {
+	tx, err := BeginTx()
+	if err != nil {
+		return
+	}
+	defer EndTx(tx, &err)
+
+	res, err := tx.Stmt(prepared["archive_threads"]).Exec(board, max)
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return
+	}
+	// Archived threads keep their replyTime, so invalidate board caches
+	// explicitly.
+	err = execPreparedTx(tx, "touch_board", board)
+	return
+}
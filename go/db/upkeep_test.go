@@ -1,33 +1,24 @@
 package db
 
 import (
-	"fmt"
-	"github.com/cutechan/cutechan/go/common"
-	"github.com/cutechan/cutechan/go/config"
-	. "github.com/cutechan/cutechan/go/test"
 	"testing"
 	"time"
-)
 
-const eightDays = time.Hour * 24 * 8
-
-type threadExpiryCases []struct {
-	id    uint64
-	board string
-	time  time.Time
-}
+	"github.com/cutechan/cutechan/go/common"
+	. "github.com/cutechan/cutechan/go/test"
+)
 
 func TestOpenPostClosing(t *testing.T) {
 	assertTableClear(t, "boards")
 	writeSampleBoard(t)
 	writeSampleThread(t)
-	common.ParseBody = func(_ []byte, _ string) (
-		[][2]uint64, []common.Command, error,
+	common.ParseBody = func(_ []byte) (
+		common.Links, common.Commands, error,
 	) {
 		return nil, nil, nil
 	}
 
-	tooOld := time.Now().Add(-time.Minute * 31).Unix()
+	tooOld := time.Now().Add(-time.Minute * 16).Unix()
 	posts := [...]Post{
 		{
 			StandalonePost: common.StandalonePost{
@@ -36,7 +27,8 @@ func TestOpenPostClosing(t *testing.T) {
 					Editing: true,
 					Time:    tooOld,
 				},
-				OP: 1,
+				OP:    1,
+				Board: "a",
 			},
 		},
 		{
@@ -46,17 +38,16 @@ func TestOpenPostClosing(t *testing.T) {
 					Editing: true,
 					Time:    time.Now().Unix(),
 				},
-				OP: 1,
+				OP:    1,
+				Board: "a",
 			},
 		},
 	}
 	for _, p := range posts {
-		if err := WritePost(nil, p); err != nil {
-			t.Fatal(err)
-		}
+		writePost(t, p)
 	}
 
-	if err := closeDanglingPosts(); err != nil {
+	if err := closeExpiredOpenPosts(); err != nil {
 		t.Fatal(err)
 	}
 
@@ -85,194 +76,51 @@ func TestOpenPostClosing(t *testing.T) {
 			}
 		})
 	}
-
-}
-
-func assertDeleted(t *testing.T, q string, del bool) {
-	q = fmt.Sprintf(`select exists (select 1 %s)`, q)
-	var exists bool
-	err := db.QueryRow(q).Scan(&exists)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	deleted := !exists
-	if deleted != del {
-		LogUnexpected(t, del, deleted)
-	}
-}
-
-func assertBoardDeleted(t *testing.T, id string, del bool) {
-	q := fmt.Sprintf(`from boards where id = '%s'`, id)
-	assertDeleted(t, q, del)
 }
 
-func assertThreadDeleted(t *testing.T, id uint64, del bool) {
-	q := fmt.Sprintf(`from threads where id = '%d'`, id)
-	assertDeleted(t, q, del)
-}
-
-func TestDeleteUnusedBoards(t *testing.T) {
+func TestArchiveBoardThreads(t *testing.T) {
 	assertTableClear(t, "boards")
-	config.Set(config.Configs{
-		BoardExpiry: 7,
-	})
-
-	t.Run("no boards", func(t *testing.T) {
-		(*config.Get()).PruneBoards = true
-
-		if err := deleteUnusedBoards(); err != nil {
-			t.Fatal(err)
-		}
-	})
-
-	t.Run("board with no threads", testBoardNoThreads)
-	t.Run("pruning disabled", testBoardPruningDisabled)
-	t.Run("board with threads", testDeleteUnusedBoards)
-}
-
-func testBoardNoThreads(t *testing.T) {
-	(*config.Get()).PruneBoards = true
-
-	err := WriteBoard(nil, BoardConfigs{
-		Created: time.Now().Add(-eightDays),
-		BoardConfigs: config.BoardConfigs{
-			ID: "l",
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if err := deleteUnusedBoards(); err != nil {
-		t.Fatal(err)
-	}
-	assertBoardDeleted(t, "l", true)
-}
-
-func testBoardPruningDisabled(t *testing.T) {
-	(*config.Get()).PruneBoards = false
-
-	err := WriteBoard(nil, BoardConfigs{
-		Created: time.Now().Add(-eightDays),
-		BoardConfigs: config.BoardConfigs{
-			ID: "x",
-		},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if err := deleteUnusedBoards(); err != nil {
-		t.Fatal(err)
-	}
-	assertBoardDeleted(t, "x", false)
-}
-
-func testDeleteUnusedBoards(t *testing.T) {
-	(*config.Get()).PruneBoards = true
-	fresh := time.Now()
-	expired := fresh.Add(-eightDays)
+	writeSampleBoard(t)
 
-	for _, id := range [...]string{"a", "c"} {
-		err := WriteBoard(nil, BoardConfigs{
-			Created: expired,
-			BoardConfigs: config.BoardConfigs{
-				ID: id,
+	now := time.Now().Unix()
+	for i := uint64(1); i <= 3; i++ {
+		writeThread(t, Post{
+			StandalonePost: common.StandalonePost{
+				Post: common.Post{
+					ID:   i,
+					Time: now + int64(i),
+				},
+				OP:    i,
+				Board: "a",
 			},
 		})
-		if err != nil {
-			t.Fatal(err)
-		}
 	}
 
-	writeExpiringThreads(t, threadExpiryCases{
-		{1, "a", expired},
-		{3, "c", fresh},
-	})
-
-	if err := deleteUnusedBoards(); err != nil {
+	if err := archiveBoardThreads("a", 2); err != nil {
 		t.Fatal(err)
 	}
 
 	cases := [...]struct {
-		name, board string
-		deleted     bool
+		name     string
+		id       uint64
+		archived bool
 	}{
-		{"deleted", "a", true},
-		{"untouched", "c", false},
+		{"oldest", 1, true},
+		{"kept", 2, false},
+		{"newest", 3, false},
 	}
 
 	for i := range cases {
 		c := cases[i]
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
-			assertBoardDeleted(t, c.board, c.deleted)
+			archived, _, err := GetThreadState(nil, c.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if archived != c.archived {
+				LogUnexpected(t, c.archived, archived)
+			}
 		})
 	}
 }
-
-func writeExpiringThreads(t *testing.T, ops threadExpiryCases) {
-	for _, op := range ops {
-		unix := op.time.Unix()
-		thread := Thread{
-			ID:        op.id,
-			Board:     op.board,
-			ReplyTime: unix,
-			BumpTime:  unix,
-		}
-		post := Post{
-			StandalonePost: common.StandalonePost{
-				Post: common.Post{
-					ID: op.id,
-				},
-				Board: op.board,
-				OP:    op.id,
-			},
-		}
-		if err := WriteThread(nil, thread, post); err != nil {
-			t.Fatal(err)
-		}
-	}
-}
-
-func TestDeleteOldThreads(t *testing.T) {
-	assertTableClear(t, "boards")
-	writeSampleBoard(t)
-	config.Set(config.Configs{
-		Public: config.Public{
-			ThreadExpiryMin: 7,
-			ThreadExpiryMax: 7,
-		},
-	})
-
-	t.Run("no threads", func(t *testing.T) {
-		(*config.Get()).PruneThreads = true
-		if err := deleteOldThreads(); err != nil {
-			t.Fatal(err)
-		}
-	})
-
-	writeExpiringThreads(t, threadExpiryCases{
-		{1, "a", time.Now().Add(-eightDays)},
-		{2, "a", time.Now()},
-	})
-
-	t.Run("pruning disabled", func(t *testing.T) {
-		(*config.Get()).PruneThreads = false
-		if err := deleteOldThreads(); err != nil {
-			t.Fatal(err)
-		}
-		assertThreadDeleted(t, 1, false)
-		assertThreadDeleted(t, 2, false)
-	})
-
-	t.Run("deleted", func(t *testing.T) {
-		(*config.Get()).PruneThreads = true
-		if err := deleteOldThreads(); err != nil {
-			t.Fatal(err)
-		}
-		assertThreadDeleted(t, 1, true)
-		assertThreadDeleted(t, 2, false)
-	})
-}
@@ -67,7 +67,9 @@ func genPrepared() error {
 	for _, id := range left {
 		var err error
 		k := strings.TrimSuffix(filepath.Base(id), ".sql")
-		prepared[k], err = db.Prepare(getQuery(id))
+		q := getQuery(id)
+		statementNames[q] = k
+		prepared[k], err = db.Prepare(q)
 		if err != nil {
 			return makeError(id, err)
 		}
@@ -122,7 +124,7 @@ func execPrepared(id string, args ...interface{}) error {
 func execPreparedTx(tx *sql.Tx, id string, args ...interface{}) error {
 	stmt, ok := prepared[id]
 	if !ok {
-		return fmt.Errorf("no such prepared id: %s', id")
+		return fmt.Errorf("no such prepared id: %s", id)
 	}
 	_, err := tx.Stmt(stmt).Exec(args...)
 	return err
@@ -196,6 +198,22 @@ func listenFunc(event string, fn func(msg string) error) error {
 	return nil
 }
 
+// ListenFunc assigns a function to listen to Postgres notifications on a
+// channel. Exported for packages, that need to receive events published by
+// other server instances.
+func ListenFunc(event string, fn func(msg string) error) error {
+	return listenFunc(event, fn)
+}
+
+// Notify publishes a notification event with the payload on a channel
+func Notify(event, msg string) error {
+	if IsTest {
+		return nil
+	}
+	_, err := db.Exec(`SELECT pg_notify($1, $2)`, event, msg)
+	return err
+}
+
 // Listen starts listening for notification events on a specific channel
 func Listen(event string) (*pq.Listener, error) {
 	l := pq.NewListener(
@@ -219,3 +237,18 @@ func execAll(tx *sql.Tx, q ...string) error {
 	}
 	return nil
 }
+
+// Pass empty strings as NULL, so they never match anything
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// ClearTables deletes the contents of all passed tables. Only used in tests.
+func ClearTables(tables ...string) error {
+	for _, t := range tables {
+		if _, err := db.Exec(`DELETE FROM ` + t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"github.com/lib/pq"
+)
+
+// WatchedThread is a short summary of a thread watched by an account.
+type WatchedThread struct {
+	ID       uint64 `json:"id"`
+	Board    string `json:"board"`
+	Subject  string `json:"subject"`
+	PostCtr  uint32 `json:"postCtr"`
+	BumpTime int64  `json:"bumpTime"`
+}
+
+// WatchThread adds thread to the account's watch list.
+func WatchThread(account string, thread uint64) error {
+	return execPrepared("watch_thread", account, thread)
+}
+
+// UnwatchThread removes thread from the account's watch list.
+func UnwatchThread(account string, thread uint64) error {
+	return execPrepared("unwatch_thread", account, thread)
+}
+
+// GetWatchedThreads retrieves all threads watched by the account, most
+// recently bumped first.
+func GetWatchedThreads(account string) (threads []WatchedThread, err error) {
+	threads = make([]WatchedThread, 0)
+	rs, err := prepared["get_watched_threads"].Query(account)
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var t WatchedThread
+		err = rs.Scan(&t.ID, &t.Board, &t.Subject, &t.PostCtr, &t.BumpTime)
+		if err != nil {
+			return
+		}
+		threads = append(threads, t)
+	}
+	err = rs.Err()
+	return
+}
+
+// GetPostAccounts retrieves author accounts of the specified posts.
+// Anonymous posts are omitted.
+func GetPostAccounts(ids []uint64) (accounts map[uint64]string, err error) {
+	accounts = make(map[uint64]string, len(ids))
+	rs, err := prepared["get_post_accounts"].Query(pq.Array(ids))
+	if err != nil {
+		return
+	}
+	defer rs.Close()
+	for rs.Next() {
+		var id uint64
+		var account string
+		err = rs.Scan(&id, &account)
+		if err != nil {
+			return
+		}
+		accounts[id] = account
+	}
+	err = rs.Err()
+	return
+}
@@ -1,8 +1,11 @@
 package feeds
 
 import (
-	"github.com/cutechan/cutechan/go/common"
+	"net"
 	"sync"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
 )
 
 // Clients stores all synchronized websocket clients in a thread-safe map
@@ -12,7 +15,7 @@ var clients = ClientMap{
 }
 
 func init() {
-	common.GetByIPAndBoard = GetByIPAndBoard
+	common.GetByNetAndBoard = GetByNetAndBoard
 }
 
 // ClientMap is a thread-safe store for all clients connected to this server
@@ -73,14 +76,50 @@ func GetSync(cl common.Client) (synced bool, op uint64, board string) {
 	return
 }
 
-// GetByIPAndBoard retrieves all Clients that match the passed IP on a board
-func GetByIPAndBoard(ip, board string) []common.Client {
+// GetByNetAndBoard retrieves all Clients with IPs inside the passed network
+// on a board
+func GetByNetAndBoard(network, board string) []common.Client {
+	n, err := auth.ParseBanRange(network)
+	if err != nil {
+		return nil
+	}
+
+	clients.RLock()
+	defer clients.RUnlock()
+
+	cls := make([]common.Client, 0, 16)
+	for cl, sync := range clients.clients {
+		ip := net.ParseIP(cl.IP())
+		if ip != nil && n.Contains(ip) &&
+			(board == "all" || sync.board == board) {
+			cls = append(cls, cl)
+		}
+	}
+	return cls
+}
+
+// GetByThread retrieves all Clients synced to a thread
+func GetByThread(op uint64) []common.Client {
 	clients.RLock()
 	defer clients.RUnlock()
 
 	cls := make([]common.Client, 0, 16)
 	for cl, sync := range clients.clients {
-		if cl.IP() == ip && (board == "all" || sync.board == board) {
+		if sync.op == op {
+			cls = append(cls, cl)
+		}
+	}
+	return cls
+}
+
+// GetByAccount retrieves all Clients logged in as the specified account
+func GetByAccount(account string) []common.Client {
+	clients.RLock()
+	defer clients.RUnlock()
+
+	cls := make([]common.Client, 0, 4)
+	for cl := range clients.clients {
+		if cl.Account() == account {
 			cls = append(cls, cl)
 		}
 	}
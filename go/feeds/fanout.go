@@ -0,0 +1,232 @@
+// Cross-instance feed event fan-out over PostgreSQL LISTEN/NOTIFY
+
+package feeds
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+)
+
+const (
+	// Postgres channel feed events are published on
+	fanoutChannel = "feed_events"
+	// NOTIFY payloads must be shorter than this many bytes
+	maxPayloadSize = 8000
+)
+
+type feedEventType uint8
+
+const (
+	eventInsertPost feedEventType = iota
+	eventBanPost
+	eventDeletePost
+	eventDeleteImage
+	eventSpoilerImage
+	eventMoveThread
+	eventInsertOpenPost
+	eventSetOpenBody
+	eventClosePost
+	eventNotification
+	eventReplyNotification
+)
+
+// Identifies this server instance, so it can skip its own events
+var instanceID string
+
+// Event published to all server instances. Mostly only IDs are sent, because
+// NOTIFY payloads are limited to 8000 bytes. Receivers regenerate messages
+// on their own.
+type feedEvent struct {
+	Origin string        `json:"origin"`
+	Type   feedEventType `json:"type"`
+	ID     uint64        `json:"id"`
+	OP     uint64        `json:"op"`
+	Board  string        `json:"board,omitempty"`
+	Text   string        `json:"text,omitempty"`
+	// Reply notification target post and its author
+	Target  uint64 `json:"target,omitempty"`
+	Account string `json:"account,omitempty"`
+	// Open post edit message and the resulting body. Dropped, if they don't
+	// fit the payload, so receivers read the body from the database.
+	Msg  string `json:"msg,omitempty"`
+	Body string `json:"body,omitempty"`
+}
+
+// Listen starts receiving feed events published by other server instances
+// and replaying them on the local feeds
+func Listen() (err error) {
+	instanceID, err = auth.RandomID(16)
+	if err != nil {
+		return
+	}
+	return db.ListenFunc(fanoutChannel, handleEvent)
+}
+
+// Publish a feed event to other server instances
+func publish(typ feedEventType, id, op uint64) error {
+	return publishEvent(feedEvent{
+		Type: typ,
+		ID:   id,
+		OP:   op,
+	})
+}
+
+// Publish an open post edit to other server instances
+func publishOpenBody(id, op uint64, body, msg []byte) error {
+	return publishEvent(feedEvent{
+		Type: eventSetOpenBody,
+		ID:   id,
+		OP:   op,
+		Msg:  string(msg),
+		Body: string(body),
+	})
+}
+
+func publishEvent(e feedEvent) error {
+	buf, err := encodeEvent(e)
+	if err != nil {
+		return err
+	}
+	return db.Notify(fanoutChannel, string(buf))
+}
+
+func encodeEvent(e feedEvent) (buf []byte, err error) {
+	e.Origin = instanceID
+	buf, err = json.Marshal(e)
+	if err != nil || len(buf) < maxPayloadSize || e.Msg == "" {
+		return
+	}
+	e.Msg = ""
+	e.Body = ""
+	return json.Marshal(e)
+}
+
+// Replay a feed event received from another server instance
+func handleEvent(msg string) (err error) {
+	var e feedEvent
+	if err = json.Unmarshal([]byte(msg), &e); err != nil {
+		return
+	}
+	if e.Origin == instanceID {
+		return
+	}
+
+	switch e.Type {
+	case eventInsertPost, eventInsertOpenPost:
+		err = replayInsertPost(e.ID, e.OP)
+	case eventSetOpenBody:
+		if e.Msg != "" {
+			setOpenBodyLocal(e.ID, e.OP, []byte(e.Body), []byte(e.Msg))
+		} else {
+			err = replaySetOpenBody(e.ID, e.OP)
+		}
+	case eventClosePost:
+		err = replayClosePost(e.ID, e.OP)
+	case eventBanPost:
+		err = banPostLocal(e.ID, e.OP)
+	case eventDeletePost:
+		err = deletePostLocal(e.ID, e.OP)
+	case eventDeleteImage:
+		err = deleteImageLocal(e.ID, e.OP)
+	case eventSpoilerImage:
+		err = spoilerImageLocal(e.ID, e.OP)
+	case eventMoveThread:
+		moveThreadLocal(e.OP, e.Board)
+	case eventNotification:
+		err = sendNotificationLocal(e.Text)
+	case eventReplyNotification:
+		err = sendReplyNotificationLocal(e.Account, common.ReplyNotification{
+			Type:   "reply",
+			ID:     e.ID,
+			OP:     e.OP,
+			Board:  e.Board,
+			Target: e.Target,
+		})
+	default:
+		err = fmt.Errorf("feeds: unknown event type: %d", e.Type)
+	}
+	return
+}
+
+// Insert post created on another instance. The post is only read from the
+// database, if anyone is listening to the thread on this instance.
+func replayInsertPost(id, op uint64) (err error) {
+	if !hasFeed(op) {
+		return
+	}
+	post, err := db.GetPost(id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil
+	default:
+		return
+	}
+	msg, err := common.EncodeMessage(common.MessageInsertPost, post.Post)
+	if err != nil {
+		return
+	}
+	if post.Editing {
+		insertOpenPostLocal(post, []byte(post.Body), msg)
+	} else {
+		insertPostLocal(post, msg)
+	}
+	return
+}
+
+// Set the body of an open post edited on another instance, when the edit did
+// not fit the event payload. The feed replaces the whole body instead.
+func replaySetOpenBody(id, op uint64) (err error) {
+	if !hasFeed(op) {
+		return
+	}
+	post, err := db.GetPost(id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil
+	default:
+		return
+	}
+	if post.Editing {
+		setOpenBodyLocal(id, op, []byte(post.Body), nil)
+	}
+	return
+}
+
+// Close an open post closed on another instance
+func replayClosePost(id, op uint64) (err error) {
+	if !hasFeed(op) {
+		return
+	}
+	post, err := db.GetPost(id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil
+	default:
+		return
+	}
+	msg, err := common.EncodeMessage(common.MessageClosePost, common.CloseMessage{
+		ID:       id,
+		Links:    post.Links,
+		Commands: post.Commands,
+	})
+	if err != nil {
+		return
+	}
+	closePostLocal(id, op, msg)
+	return
+}
+
+// Returns, if a feed for the thread is running on this instance
+func hasFeed(id uint64) bool {
+	feeds.mu.RLock()
+	defer feeds.mu.RUnlock()
+	return feeds.feeds[id] != nil
+}
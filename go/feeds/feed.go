@@ -3,8 +3,10 @@ package feeds
 import (
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/metrics"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
 type postMessageType uint8
@@ -83,9 +85,11 @@ func (f *Feed) Start() (err error) {
 	f.open = make(map[uint64]openPostCacheEntry, 16)
 	for _, p := range recent {
 		f.recent[p.ID] = p.Time
-		f.open[p.ID] = openPostCacheEntry{
-			created: p.Time,
-			body:    p.Body,
+		if p.Editing {
+			f.open[p.ID] = openPostCacheEntry{
+				created: p.Time,
+				body:    p.Body,
+			}
 		}
 	}
 
@@ -134,6 +138,8 @@ func (f *Feed) Start() (err error) {
 				if buf := f.flush(); buf == nil {
 					f.pause()
 				} else {
+					metrics.FeedFlushSize.Observe(float64(len(buf)))
+					metrics.FeedFlushClients.Observe(float64(len(f.clients)))
 					for _, c := range f.clients {
 						c.Send(buf)
 					}
@@ -172,10 +178,17 @@ func (f *Feed) Start() (err error) {
 			// Set the body of an open post and propagate
 			case msg := <-f.setOpenBody:
 				f.startIfPaused()
-				p := f.open[msg.id]
-				p.body = msg.body
-				f.open[msg.id] = p
-				f.write(msg.msg)
+				p, ok := f.open[msg.id]
+				if msg.msg == nil && ok {
+					msg.msg = encodeBodyReplacement(msg.id, p.body, msg.body)
+				}
+				if ok {
+					p.body = msg.body
+					f.open[msg.id] = p
+				}
+				if msg.msg != nil {
+					f.write(msg.msg)
+				}
 
 			// Various post-related messages
 			case msg := <-f.sendPostMessage:
@@ -278,6 +291,16 @@ func (f *Feed) genSyncMessage() []byte {
 	return b
 }
 
+// Encode a splice message replacing the whole body of an open post
+func encodeBodyReplacement(id uint64, old, body []byte) []byte {
+	msg, _ := common.EncodeMessage(common.MessageSplice, common.SpliceMessage{
+		ID:   id,
+		Len:  uint(utf8.RuneCount(old)),
+		Text: string(body),
+	})
+	return msg
+}
+
 // Send unique IP count to all connected clients
 func (f *Feed) sendIPCount() {
 	ips := make(map[string]struct{}, len(f.clients))
@@ -294,6 +317,7 @@ func (f *Feed) sendIPCount() {
 func (f *Feed) InsertPost(post common.StandalonePost, body, msg []byte) {
 	f.insertPost <- postCreationMessage{
 		id:       post.ID,
+		open:     post.Editing,
 		hasImage: len(post.Files) > 0,
 		time:     post.Time,
 		body:     body,
@@ -335,7 +359,8 @@ func (f *Feed) deleteImage(id uint64, msg []byte) {
 	f._sendPostMessage(deleteImage, id, msg)
 }
 
-// Set body of an open post and send update message to clients
+// Set body of an open post and send update message to clients. A nil message
+// replaces the whole body on the clients.
 func (f *Feed) SetOpenBody(id uint64, body, msg []byte) {
 	f.setOpenBody <- postBodyModMessage{
 		id:   id,
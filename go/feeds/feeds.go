@@ -5,6 +5,8 @@ package feeds
 
 import (
 	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/metrics"
+	"log"
 	"sync"
 )
 
@@ -22,6 +24,8 @@ func init() {
 	common.DeletePost = DeletePost
 	common.DeleteImage = DeleteImage
 	common.SpoilerImage = SpoilerImage
+	common.MoveThread = MoveThread
+	common.NotifyReplies = NotifyReplies
 }
 
 // Container for managing client<->update-feed assignment and interaction
@@ -50,6 +54,7 @@ func addToFeed(id uint64, c common.Client) (feed *Feed, err error) {
 			messageBuffer:   make([]byte, 0, 1<<10),
 		}
 		feeds.feeds[id] = feed
+		metrics.Feeds.Inc()
 		err = feed.Start()
 		if err != nil {
 			return
@@ -73,6 +78,7 @@ func removeFromFeed(id uint64, c common.Client) {
 	// If the feeds sends a non-nil, it means it closed
 	if nil != <-feed.remove {
 		delete(feeds.feeds, feed.id)
+		metrics.Feeds.Dec()
 	}
 }
 
@@ -94,16 +100,61 @@ func sendIfExists(id uint64, fn func(*Feed)) error {
 	return nil
 }
 
-// InsertPostInto inserts a post into a tread feed, if it exists. Only use for
-// already closed posts.
+// InsertPostInto inserts a post into a tread feed, if it exists, and publishes
+// the event to other server instances. Only use for already closed posts.
 func InsertPostInto(post common.StandalonePost, msg []byte) {
+	insertPostLocal(post, msg)
+	if err := publish(eventInsertPost, post.ID, post.OP); err != nil {
+		log.Printf("feeds: publishing post %d: %s\n", post.ID, err)
+	}
+}
+
+func insertPostLocal(post common.StandalonePost, msg []byte) {
 	sendIfExists(post.OP, func(f *Feed) {
 		f.InsertPost(post, nil, msg)
 	})
 }
 
-// ClosePost closes a post in a feed, if it exists
+// InsertOpenPost inserts an open post into a thread feed, if it exists, and
+// publishes the event to other server instances
+func InsertOpenPost(post common.StandalonePost, body, msg []byte) {
+	insertOpenPostLocal(post, body, msg)
+	if err := publish(eventInsertOpenPost, post.ID, post.OP); err != nil {
+		log.Printf("feeds: publishing open post %d: %s\n", post.ID, err)
+	}
+}
+
+func insertOpenPostLocal(post common.StandalonePost, body, msg []byte) {
+	sendIfExists(post.OP, func(f *Feed) {
+		f.InsertPost(post, body, msg)
+	})
+}
+
+// SetOpenBody sets the body of an open post in a thread feed, if it exists,
+// and publishes the event to other server instances
+func SetOpenBody(id, op uint64, body, msg []byte) {
+	setOpenBodyLocal(id, op, body, msg)
+	if err := publishOpenBody(id, op, body, msg); err != nil {
+		log.Printf("feeds: publishing body of post %d: %s\n", id, err)
+	}
+}
+
+func setOpenBodyLocal(id, op uint64, body, msg []byte) {
+	sendIfExists(op, func(f *Feed) {
+		f.SetOpenBody(id, body, msg)
+	})
+}
+
+// ClosePost closes a post in a feed, if it exists, and publishes the event
+// to other server instances
 func ClosePost(id, op uint64, msg []byte) {
+	closePostLocal(id, op, msg)
+	if err := publish(eventClosePost, id, op); err != nil {
+		log.Printf("feeds: publishing closing of post %d: %s\n", id, err)
+	}
+}
+
+func closePostLocal(id, op uint64, msg []byte) {
 	sendIfExists(op, func(f *Feed) {
 		f.ClosePost(id, msg)
 	})
@@ -111,6 +162,13 @@ func ClosePost(id, op uint64, msg []byte) {
 
 // Propagate a message about a post being banned
 func BanPost(id, op uint64) error {
+	if err := banPostLocal(id, op); err != nil {
+		return err
+	}
+	return publish(eventBanPost, id, op)
+}
+
+func banPostLocal(id, op uint64) error {
 	msg, err := common.EncodeMessage(common.MessageBanned, id)
 	if err != nil {
 		return err
@@ -123,6 +181,13 @@ func BanPost(id, op uint64) error {
 
 // Propagate a message about a post being deleted
 func DeletePost(id, op uint64) error {
+	if err := deletePostLocal(id, op); err != nil {
+		return err
+	}
+	return publish(eventDeletePost, id, op)
+}
+
+func deletePostLocal(id, op uint64) error {
 	msg, err := common.EncodeMessage(common.MessageDeletePost, id)
 	if err != nil {
 		return err
@@ -134,6 +199,13 @@ func DeletePost(id, op uint64) error {
 
 // Propagate a message about an image being deleted from a post
 func DeleteImage(id, op uint64) error {
+	if err := deleteImageLocal(id, op); err != nil {
+		return err
+	}
+	return publish(eventDeleteImage, id, op)
+}
+
+func deleteImageLocal(id, op uint64) error {
 	msg, err := common.EncodeMessage(common.MessageDeleteImage, id)
 	if err != nil {
 		return err
@@ -145,6 +217,13 @@ func DeleteImage(id, op uint64) error {
 
 // Propagate a message about an image being spoilered
 func SpoilerImage(id, op uint64) error {
+	if err := spoilerImageLocal(id, op); err != nil {
+		return err
+	}
+	return publish(eventSpoilerImage, id, op)
+}
+
+func spoilerImageLocal(id, op uint64) error {
 	msg, err := common.EncodeMessage(common.MessageSpoiler, id)
 	if err != nil {
 		return err
@@ -154,9 +233,51 @@ func SpoilerImage(id, op uint64) error {
 	})
 }
 
+// Redirect clients of a thread moved to another board
+func MoveThread(op uint64, board string) error {
+	moveThreadLocal(op, board)
+	return publishEvent(feedEvent{
+		Type:  eventMoveThread,
+		OP:    op,
+		Board: board,
+	})
+}
+
+func moveThreadLocal(op uint64, board string) {
+	for _, cl := range GetByThread(op) {
+		cl.Redirect(board)
+	}
+}
+
+// Send a textual message to all clients connected to any server instance.
+// Published first, so the message is either shown by all instances or
+// reported as failed without reaching anyone.
+func SendNotification(text string) error {
+	err := publishEvent(feedEvent{
+		Type: eventNotification,
+		Text: text,
+	})
+	if err != nil {
+		return err
+	}
+	return sendNotificationLocal(text)
+}
+
+func sendNotificationLocal(text string) error {
+	msg, err := common.EncodeMessage(common.MessageNotification, text)
+	if err != nil {
+		return err
+	}
+	for _, cl := range All() {
+		cl.Send(msg)
+	}
+	return nil
+}
+
 // Remove all existing feeds and clients. Used only in tests.
 func Clear() {
 	feeds.mu.Lock()
 	defer feeds.mu.Unlock()
 	feeds.feeds = make(map[uint64]*Feed, 32)
+	metrics.Feeds.Set(0)
 }
@@ -1,7 +1,9 @@
 package feeds
 
 import (
+	"fmt"
 	. "github.com/cutechan/cutechan/go/test"
+	"strings"
 	"testing"
 )
 
@@ -17,3 +19,65 @@ func TestWriteMultipleToBuffer(t *testing.T) {
 		LogUnexpected(t, std, s)
 	}
 }
+
+func TestHandleOwnEvent(t *testing.T) {
+	instanceID = "self"
+	defer func() {
+		instanceID = ""
+	}()
+
+	err := handleEvent(`{"origin":"self","type":255,"id":1,"op":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = handleEvent(`{"origin":"other","type":255,"id":1,"op":1}`)
+	if err == nil {
+		t.Fatal("expected error on unknown event type")
+	}
+}
+
+func TestEncodeBodyReplacement(t *testing.T) {
+	t.Parallel()
+
+	msg := encodeBodyReplacement(2, []byte("αβc"), []byte("abcd"))
+	const std = `05{"id":2,"start":0,"len":3,"text":"abcd"}`
+	if s := string(msg); s != std {
+		LogUnexpected(t, std, s)
+	}
+}
+
+func TestEncodeOpenBodyEvent(t *testing.T) {
+	instanceID = "self"
+	defer func() {
+		instanceID = ""
+	}()
+
+	e := feedEvent{
+		Type: eventSetOpenBody,
+		ID:   2,
+		OP:   1,
+		Msg:  `03[2,100]`,
+		Body: "abcd",
+	}
+	buf, err := encodeEvent(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := fmt.Sprintf(`{"origin":"self","type":%d,"id":2,"op":1,`+
+		`"msg":"03[2,100]","body":"abcd"}`, eventSetOpenBody)
+	if s := string(buf); s != std {
+		LogUnexpected(t, std, s)
+	}
+
+	// Too large edits are replayed from the database
+	e.Body = strings.Repeat("a", maxPayloadSize)
+	buf, err = encodeEvent(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std = fmt.Sprintf(`{"origin":"self","type":%d,"id":2,"op":1}`,
+		eventSetOpenBody)
+	if s := string(buf); s != std {
+		LogUnexpected(t, std, s)
+	}
+}
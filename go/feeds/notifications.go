@@ -0,0 +1,73 @@
+// Reply notifications
+
+package feeds
+
+import (
+	"log"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+)
+
+// NotifyReplies notifies logged in authors of the posts linked by a closed
+// post on any server instance. Errors are only logged, as the post has
+// already been written at this point.
+func NotifyReplies(id, op uint64, board, account string, links common.Links) {
+	if len(links) == 0 {
+		return
+	}
+
+	ids := make([]uint64, len(links))
+	for i, l := range links {
+		ids[i] = l[0]
+	}
+	accounts, err := db.GetPostAccounts(ids)
+	if err != nil {
+		log.Printf("reply notifications: %s\n", err)
+		return
+	}
+
+	for target, acc := range accounts {
+		// Don't notify about replies to own posts
+		if acc == account {
+			continue
+		}
+		err := SendReplyNotification(acc, common.ReplyNotification{
+			Type:   "reply",
+			ID:     id,
+			OP:     op,
+			Board:  board,
+			Target: target,
+		})
+		if err != nil {
+			log.Printf("reply notifications: %s\n", err)
+		}
+	}
+}
+
+// SendReplyNotification sends a reply notification to all clients logged in
+// as the account on any server instance
+func SendReplyNotification(account string, n common.ReplyNotification) error {
+	if err := sendReplyNotificationLocal(account, n); err != nil {
+		return err
+	}
+	return publishEvent(feedEvent{
+		Type:    eventReplyNotification,
+		ID:      n.ID,
+		OP:      n.OP,
+		Board:   n.Board,
+		Target:  n.Target,
+		Account: account,
+	})
+}
+
+func sendReplyNotificationLocal(account string, n common.ReplyNotification) error {
+	msg, err := common.EncodeMessage(common.MessageNotification, n)
+	if err != nil {
+		return err
+	}
+	for _, cl := range GetByAccount(account) {
+		cl.Send(msg)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package feeds
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+// Client, which only records the messages sent to it
+type mockClient struct {
+	account string
+	mu      sync.Mutex
+	msgs    []string
+}
+
+func (c *mockClient) Send(msg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgs = append(c.msgs, string(msg))
+}
+
+func (c *mockClient) Redirect(string) {}
+
+func (c *mockClient) IP() string {
+	return "::1"
+}
+
+func (c *mockClient) Account() string {
+	return c.account
+}
+
+func (c *mockClient) Close(error) {}
+
+func (c *mockClient) messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.msgs...)
+}
+
+// Connect clients to the board page, so they are not assigned any feed
+func connectMockClients(t *testing.T, cls ...*mockClient) func() {
+	for _, cl := range cls {
+		if _, err := SyncClient(cl, 0, "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for _, cl := range cls {
+			RemoveClient(cl)
+		}
+	}
+}
+
+func TestSendReplyNotification(t *testing.T) {
+	db.IsTest = true
+	target := &mockClient{account: "user1"}
+	other := &mockClient{account: "user2"}
+	anon := &mockClient{}
+	defer connectMockClients(t, target, other, anon)()
+
+	n := common.ReplyNotification{
+		Type:   "reply",
+		ID:     3,
+		OP:     1,
+		Board:  "a",
+		Target: 2,
+	}
+	if err := SendReplyNotification("user1", n); err != nil {
+		t.Fatal(err)
+	}
+
+	const std = `38{"type":"reply","id":3,"op":1,"board":"a","target":2}`
+	AssertDeepEquals(t, target.messages(), []string{std})
+	AssertDeepEquals(t, other.messages(), []string{})
+	AssertDeepEquals(t, anon.messages(), []string{})
+}
+
+func TestReplayReplyNotification(t *testing.T) {
+	instanceID = "self"
+	defer func() {
+		instanceID = ""
+	}()
+	target := &mockClient{account: "user1"}
+	other := &mockClient{account: "user2"}
+	defer connectMockClients(t, target, other)()
+
+	const event = `{"origin":"other","type":%d,"id":3,"op":1,"board":"a",` +
+		`"target":2,"account":"user1"}`
+	if err := handleEvent(fmt.Sprintf(event, eventReplyNotification)); err != nil {
+		t.Fatal(err)
+	}
+
+	const std = `38{"type":"reply","id":3,"op":1,"board":"a","target":2}`
+	AssertDeepEquals(t, target.messages(), []string{std})
+	AssertDeepEquals(t, other.messages(), []string{})
+}
@@ -2,6 +2,8 @@
 package file
 
 import (
+	"bytes"
+	"io"
 	"net/http"
 	"strings"
 
@@ -22,13 +24,30 @@ type Config struct {
 	Password  string
 	AuthURL   string
 	Container string
+	Endpoint  string
+	Bucket    string
+	Region    string
+	PathStyle bool
 }
 
 type fileBackend interface {
 	IsServable() bool
 	Serve(w http.ResponseWriter, r *http.Request)
-	Write(sha1 string, fileType, thumbType uint8, src, thumb []byte) error
+	// Write stores file assets. Source is read from its current position
+	// till the end. Thumbnail might be nil.
+	Write(sha1 string, fileType, thumbType uint8, src io.ReadSeeker, thumb []byte) error
+	// ReplaceThumb overwrites thumbnail of the stored file. Thumbnail of
+	// the old type is removed, if type differs.
+	ReplaceThumb(sha1 string, oldType, newType uint8, thumb []byte) error
+	// Read returns file assets. Absent assets are returned as nil.
+	Read(sha1 string, fileType, thumbType uint8) (src, thumb []byte, err error)
 	Delete(sha1 string, fileType, thumbType uint8) error
+	// WriteVariant stores named thumbnail variant, overwriting the
+	// existing one.
+	WriteVariant(sha1, name string, data []byte) error
+	// ReadVariant returns named thumbnail variant or nil, if it's absent.
+	ReadVariant(sha1, name string) ([]byte, error)
+	DeleteVariant(sha1, name string) error
 }
 
 const (
@@ -40,18 +59,49 @@ const (
 
 // StartBackend initializes file backend.
 func StartBackend(conf Config) (err error) {
+	Backend, err = MakeBackend(conf)
+	return
+}
+
+// MakeBackend creates a new file backend without setting it as current.
+func MakeBackend(conf Config) (b fileBackend, err error) {
 	if conf.Backend == "fs" {
-		Backend, err = makeFSBackend(conf)
+		b, err = makeFSBackend(conf)
 	} else if conf.Backend == "sftp" {
-		Backend, err = makeSFTPBackend(conf)
+		b, err = makeSFTPBackend(conf)
 	} else if conf.Backend == "swift" {
-		Backend, err = makeSwiftBackend(conf)
+		b, err = makeSwiftBackend(conf)
+	} else if conf.Backend == "s3" {
+		b, err = makeS3Backend(conf)
 	} else {
 		panic("unknown backend")
 	}
 	return
 }
 
+// Wrap optional file data into reader. Returns nil for absent data.
+func newOptionalReader(data []byte) io.Reader {
+	if data == nil {
+		return nil
+	}
+	return bytes.NewReader(data)
+}
+
+// Get number of bytes left till the end of reader.
+func getRemainingSize(rs io.Seeker) (size int64, err error) {
+	cur, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	size = end - cur
+	_, err = rs.Seek(cur, io.SeekStart)
+	return
+}
+
 func getImageRoot() string {
 	r := config.Get().ImageRootOverride
 	if r != "" {
@@ -64,6 +114,11 @@ func getImageURL(root string, dir string, typ uint8, sha1 string) string {
 	return strings.Join([]string{root, dir, sha1[:2], sha1[2:] + "." + common.Extensions[typ]}, "/")
 }
 
+// Variants are stored next to the main thumbnail.
+func getVariantURL(root string, name string, sha1 string) string {
+	return strings.Join([]string{root, thumbDir, sha1[:2], sha1[2:] + "-" + name + "." + common.Extensions[common.WEBP]}, "/")
+}
+
 // SourcePath returns URL to file source.
 func SourcePath(fileType uint8, sha1 string) string {
 	return getImageURL(getImageRoot(), srcDir, fileType, sha1)
@@ -73,3 +128,8 @@ func SourcePath(fileType uint8, sha1 string) string {
 func ThumbPath(thumbType uint8, sha1 string) string {
 	return getImageURL(getImageRoot(), thumbDir, thumbType, sha1)
 }
+
+// VariantPath returns URL to named thumbnail variant.
+func VariantPath(name string, sha1 string) string {
+	return getVariantURL(getImageRoot(), name, sha1)
+}
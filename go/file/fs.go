@@ -1,7 +1,10 @@
 package file
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -67,11 +70,11 @@ func fsGetPaths(root string, SHA1 string, fileType, thumbType uint8) (paths [2]s
 }
 
 // Write a single file to disk with the appropriate permissions and flags
-func fsWriteFile(path string, data []byte) error {
+func fsWriteFile(path string, r io.Reader) error {
 	// One of the files might be empty (e.g. thumbnail in case of audio
 	// record).
 	// TODO(Kagami): Don't call for empty files?
-	if data == nil {
+	if r == nil {
 		return nil
 	}
 
@@ -86,12 +89,12 @@ func fsWriteFile(path string, data []byte) error {
 	}
 	defer file.Close()
 
-	_, err = file.Write(data)
+	_, err = io.Copy(file, r)
 	return err
 }
 
 // Write writes file assets to disk
-func (b *fsBackend) Write(SHA1 string, fileType, thumbType uint8, src, thumb []byte) error {
+func (b *fsBackend) Write(SHA1 string, fileType, thumbType uint8, src io.ReadSeeker, thumb []byte) error {
 	paths := fsGetPaths(b.dir, SHA1, fileType, thumbType)
 
 	ch := make(chan error)
@@ -99,7 +102,7 @@ func (b *fsBackend) Write(SHA1 string, fileType, thumbType uint8, src, thumb []b
 		ch <- fsWriteFile(paths[0], src)
 	}()
 
-	for _, err := range [...]error{fsWriteFile(paths[1], thumb), <-ch} {
+	for _, err := range [...]error{fsWriteFile(paths[1], newOptionalReader(thumb)), <-ch} {
 		switch {
 		// Ignore files already written by another thread or process
 		case err == nil, os.IsExist(err):
@@ -110,6 +113,52 @@ func (b *fsBackend) Write(SHA1 string, fileType, thumbType uint8, src, thumb []b
 	return nil
 }
 
+// Write to temporary file and rename, so file is replaced atomically
+func fsReplaceFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := fsWriteFile(tmp, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReplaceThumb overwrites thumbnail on disk
+func (b *fsBackend) ReplaceThumb(SHA1 string, oldType, newType uint8, thumb []byte) error {
+	path := filepath.FromSlash(getImageURL(b.dir, thumbDir, newType, SHA1))
+	if err := fsReplaceFile(path, thumb); err != nil {
+		return err
+	}
+
+	if oldType != newType {
+		path = filepath.FromSlash(getImageURL(b.dir, thumbDir, oldType, SHA1))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read reads file assets from disk
+func (b *fsBackend) Read(SHA1 string, fileType, thumbType uint8) (src, thumb []byte, err error) {
+	paths := fsGetPaths(b.dir, SHA1, fileType, thumbType)
+	if src, err = fsReadFile(paths[0]); err != nil {
+		return
+	}
+	thumb, err = fsReadFile(paths[1])
+	return
+}
+
+func fsReadFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
 // Delete deletes file assets belonging to a single upload
 func (b *fsBackend) Delete(SHA1 string, fileType, thumbType uint8) error {
 	for _, path := range fsGetPaths(b.dir, SHA1, fileType, thumbType) {
@@ -121,6 +170,25 @@ func (b *fsBackend) Delete(SHA1 string, fileType, thumbType uint8) error {
 	return nil
 }
 
+// WriteVariant writes thumbnail variant to disk
+func (b *fsBackend) WriteVariant(SHA1, name string, data []byte) error {
+	return fsReplaceFile(filepath.FromSlash(getVariantURL(b.dir, name, SHA1)), data)
+}
+
+// ReadVariant reads thumbnail variant from disk
+func (b *fsBackend) ReadVariant(SHA1, name string) ([]byte, error) {
+	return fsReadFile(filepath.FromSlash(getVariantURL(b.dir, name, SHA1)))
+}
+
+// DeleteVariant deletes thumbnail variant from disk
+func (b *fsBackend) DeleteVariant(SHA1, name string) error {
+	err := os.Remove(filepath.FromSlash(getVariantURL(b.dir, name, SHA1)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func fsCreateDirs(root string) error {
 	for _, dir := range [...]string{srcDir, thumbDir} {
 		path := filepath.Join(root, dir)
@@ -0,0 +1,203 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v6"
+	"github.com/minio/minio-go/v6/pkg/credentials"
+)
+
+type s3Backend struct {
+	bucket string
+	client *minio.Client
+}
+
+func (b *s3Backend) IsServable() bool {
+	return false
+}
+
+// Served by CDN or directly from the bucket.
+func (b *s3Backend) Serve(w http.ResponseWriter, r *http.Request) {
+	panic("non-servable backend")
+}
+
+// Object keys must not start with slash.
+func getS3SourceName(fileType uint8, sha1 string) string {
+	return strings.TrimPrefix(getImageURL("", srcDir, fileType, sha1), "/")
+}
+
+func getS3ThumbName(thumbType uint8, sha1 string) string {
+	return strings.TrimPrefix(getImageURL("", thumbDir, thumbType, sha1), "/")
+}
+
+func getS3VariantName(name string, sha1 string) string {
+	return strings.TrimPrefix(getVariantURL("", name, sha1), "/")
+}
+
+func (b *s3Backend) writeFile(name string, r io.Reader, size int64) (err error) {
+	if r == nil {
+		return
+	}
+	_, err = b.client.PutObject(
+		b.bucket,
+		name,
+		r,
+		size,
+		minio.PutObjectOptions{
+			ContentType:  mime.TypeByExtension(path.Ext(name)),
+			CacheControl: fileHeaders["Cache-Control"],
+		},
+	)
+	if err != nil {
+		err = fmt.Errorf("cannot create S3 object %s in %s: %v", name, b.bucket, err)
+	}
+	return
+}
+
+func (b *s3Backend) Write(sha1 string, fileType, thumbType uint8, src io.ReadSeeker, thumb []byte) error {
+	// Known object size allows to upload without buffering.
+	size, err := getRemainingSize(src)
+	if err != nil {
+		return err
+	}
+	ch := make(chan error)
+	go func() {
+		log.Printf("[s3] creating <%s>", SourcePath(fileType, sha1))
+		ch <- b.writeFile(getS3SourceName(fileType, sha1), src, size)
+	}()
+	go func() {
+		log.Printf("[s3] creating <%s>", ThumbPath(thumbType, sha1))
+		ch <- b.writeFile(getS3ThumbName(thumbType, sha1), newOptionalReader(thumb), int64(len(thumb)))
+	}()
+	for _, err := range [...]error{<-ch, <-ch} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *s3Backend) readFile(name string) (data []byte, err error) {
+	defer func() {
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				data, err = nil, nil
+				return
+			}
+			err = fmt.Errorf("cannot read S3 object %s from %s: %v", name, b.bucket, err)
+		}
+	}()
+
+	obj, err := b.client.GetObject(b.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return
+	}
+	defer obj.Close()
+	data, err = ioutil.ReadAll(obj)
+	return
+}
+
+func (b *s3Backend) Read(sha1 string, fileType, thumbType uint8) (src, thumb []byte, err error) {
+	src, err = b.readFile(getS3SourceName(fileType, sha1))
+	if err != nil {
+		return
+	}
+	thumb, err = b.readFile(getS3ThumbName(thumbType, sha1))
+	return
+}
+
+func (b *s3Backend) ReplaceThumb(sha1 string, oldType, newType uint8, thumb []byte) (err error) {
+	log.Printf("[s3] replacing <%s>", ThumbPath(newType, sha1))
+	err = b.writeFile(getS3ThumbName(newType, sha1), bytes.NewReader(thumb), int64(len(thumb)))
+	if err != nil {
+		return
+	}
+	if oldType != newType {
+		log.Printf("[s3] deleting <%s>", ThumbPath(oldType, sha1))
+		err = b.deleteFile(getS3ThumbName(oldType, sha1))
+	}
+	return
+}
+
+// Removing absent object is not an error in S3.
+func (b *s3Backend) deleteFile(name string) (err error) {
+	err = b.client.RemoveObject(b.bucket, name)
+	if err != nil {
+		err = fmt.Errorf("cannot delete S3 object %s from %s: %v", name, b.bucket, err)
+	}
+	return
+}
+
+func (b *s3Backend) Delete(sha1 string, fileType, thumbType uint8) error {
+	ch := make(chan error)
+	go func() {
+		log.Printf("[s3] deleting <%s>", SourcePath(fileType, sha1))
+		ch <- b.deleteFile(getS3SourceName(fileType, sha1))
+	}()
+	go func() {
+		log.Printf("[s3] deleting <%s>", ThumbPath(thumbType, sha1))
+		ch <- b.deleteFile(getS3ThumbName(thumbType, sha1))
+	}()
+	for _, err := range [...]error{<-ch, <-ch} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *s3Backend) WriteVariant(sha1, name string, data []byte) error {
+	log.Printf("[s3] creating <%s>", VariantPath(name, sha1))
+	return b.writeFile(getS3VariantName(name, sha1), bytes.NewReader(data), int64(len(data)))
+}
+
+func (b *s3Backend) ReadVariant(sha1, name string) ([]byte, error) {
+	return b.readFile(getS3VariantName(name, sha1))
+}
+
+func (b *s3Backend) DeleteVariant(sha1, name string) error {
+	log.Printf("[s3] deleting <%s>", VariantPath(name, sha1))
+	return b.deleteFile(getS3VariantName(name, sha1))
+}
+
+func makeS3Backend(conf Config) (b fileBackend, err error) {
+	u, err := url.Parse(conf.Endpoint)
+	if err != nil || u.Host == "" {
+		err = fmt.Errorf("bad S3 endpoint: %s", conf.Endpoint)
+		return
+	}
+	lookup := minio.BucketLookupAuto
+	if conf.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	c, err := minio.NewWithOptions(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(conf.Username, conf.Password, ""),
+		Secure:       u.Scheme == "https",
+		Region:       conf.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		err = fmt.Errorf("cannot create S3 client: %v", err)
+		return
+	}
+	exists, err := c.BucketExists(conf.Bucket)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("cannot access S3 bucket %s: %v", conf.Bucket, err)
+		return
+	case !exists:
+		err = fmt.Errorf("S3 bucket %s doesn't exist", conf.Bucket)
+		return
+	}
+	b = &s3Backend{bucket: conf.Bucket, client: c}
+	return
+}
@@ -0,0 +1,153 @@
+package file
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
+)
+
+// Minimal in-memory stand-in for an S3-compatible server with path-style
+// bucket URLs. Authentication is not checked.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/"+s.bucket || r.URL.Path == "/"+s.bucket+"/" {
+		if r.Method != "HEAD" {
+			w.WriteHeader(400)
+		}
+		return
+	}
+	switch r.Method {
+	case "PUT":
+		buf, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			buf = decodeAWSChunked(buf)
+		}
+		s.objects[r.URL.Path] = buf
+		w.Header().Set("ETag", `"etag"`)
+	case "GET":
+		buf, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(404)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+		w.Write(buf)
+	case "DELETE":
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(204)
+	default:
+		w.WriteHeader(400)
+	}
+}
+
+// Strip chunk headers from a signed streaming upload body
+func decodeAWSChunked(buf []byte) (data []byte) {
+	for {
+		i := bytes.Index(buf, []byte("\r\n"))
+		if i == -1 {
+			return
+		}
+		size, err := strconv.ParseUint(strings.Split(string(buf[:i]), ";")[0], 16, 64)
+		if err != nil || size == 0 {
+			return
+		}
+		buf = buf[i+2:]
+		data = append(data, buf[:size]...)
+		buf = buf[size+2:]
+	}
+}
+
+func TestS3Backend(t *testing.T) {
+	config.Set(config.ServerConfig{})
+	fake := &fakeS3{bucket: "uploads", objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	b, err := makeS3Backend(Config{
+		Username:  "access",
+		Password:  "secret",
+		Endpoint:  srv.URL,
+		Bucket:    "uploads",
+		Region:    "us-east-1",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const sha1 = "0123456789abcdef0123456789abcdef01234567"
+	err = b.Write(sha1, common.PNG, common.JPEG, bytes.NewReader([]byte("src")), []byte("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := "/uploads/src/01/23456789abcdef0123456789abcdef01234567.png"
+	thumb := "/uploads/thumb/01/23456789abcdef0123456789abcdef01234567.jpg"
+	if s := string(fake.objects[src]); s != "src" {
+		t.Fatalf("unexpected source: %q", s)
+	}
+	if s := string(fake.objects[thumb]); s != "thumb" {
+		t.Fatalf("unexpected thumbnail: %q", s)
+	}
+
+	srcData, thumbData, err := b.Read(sha1, common.PNG, common.JPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(srcData) != "src" || string(thumbData) != "thumb" {
+		t.Fatalf("unexpected read: %q %q", srcData, thumbData)
+	}
+
+	if err := b.Delete(sha1, common.PNG, common.JPEG); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.objects) != 0 {
+		t.Fatalf("objects not deleted: %v", fake.objects)
+	}
+	srcData, thumbData, err = b.Read(sha1, common.PNG, common.JPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srcData != nil || thumbData != nil {
+		t.Fatal("expected absent files")
+	}
+}
+
+func TestS3BackendNoBucket(t *testing.T) {
+	fake := &fakeS3{bucket: "uploads", objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	_, err := makeS3Backend(Config{
+		Endpoint:  srv.URL,
+		Bucket:    "missing",
+		Region:    "us-east-1",
+		PathStyle: true,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -3,6 +3,8 @@ package file
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -31,8 +33,8 @@ func (b *sftpBackend) Serve(w http.ResponseWriter, r *http.Request) {
 	panic("non-servable backend")
 }
 
-func (b *sftpBackend) writeFile(fpath string, data []byte) error {
-	if data == nil {
+func (b *sftpBackend) writeFile(fpath string, r io.Reader) error {
+	if r == nil {
 		return nil
 	}
 
@@ -60,7 +62,7 @@ func (b *sftpBackend) writeFile(fpath string, data []byte) error {
 	}
 	defer file.Close()
 
-	_, err = file.ReadFrom(bytes.NewReader(data))
+	_, err = file.ReadFrom(r)
 	return err
 }
 
@@ -72,13 +74,70 @@ func getSFTPThumbPath(thumbType uint8, sha1 string) string {
 	return getImageURL(DefaultUploadsRoot, thumbDir, thumbType, sha1)
 }
 
-func (b *sftpBackend) Write(sha1 string, fileType, thumbType uint8, src, thumb []byte) (err error) {
+func (b *sftpBackend) Write(sha1 string, fileType, thumbType uint8, src io.ReadSeeker, thumb []byte) (err error) {
 	// TODO(Kagami): Concurrent writes for faster upload?
 	err = b.writeFile(getSFTPSourcePath(fileType, sha1), src)
 	if err != nil {
 		return
 	}
-	err = b.writeFile(getSFTPThumbPath(thumbType, sha1), thumb)
+	err = b.writeFile(getSFTPThumbPath(thumbType, sha1), newOptionalReader(thumb))
+	return
+}
+
+func (b *sftpBackend) readFile(fpath string) ([]byte, error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.client == nil {
+		return nil, errNoConnection
+	}
+
+	file, err := b.client.Open(fpath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ioutil.ReadAll(file)
+}
+
+func (b *sftpBackend) Read(sha1 string, fileType, thumbType uint8) (src, thumb []byte, err error) {
+	src, err = b.readFile(getSFTPSourcePath(fileType, sha1))
+	if err != nil {
+		return
+	}
+	thumb, err = b.readFile(getSFTPThumbPath(thumbType, sha1))
+	return
+}
+
+// Write to temporary file and rename, so file is replaced atomically
+func (b *sftpBackend) replaceFile(fpath string, data []byte) error {
+	tmp := fpath + ".tmp"
+	if err := b.deleteFile(tmp); err != nil {
+		return err
+	}
+	if err := b.writeFile(tmp, bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if b.client == nil {
+		return errNoConnection
+	}
+	// Plain rename doesn't overwrite existing files in SFTP.
+	return b.client.PosixRename(tmp, fpath)
+}
+
+func (b *sftpBackend) ReplaceThumb(sha1 string, oldType, newType uint8, thumb []byte) (err error) {
+	if err = b.replaceFile(getSFTPThumbPath(newType, sha1), thumb); err != nil {
+		return
+	}
+	if oldType != newType {
+		err = b.deleteFile(getSFTPThumbPath(oldType, sha1))
+	}
 	return
 }
 
@@ -106,6 +165,22 @@ func (b *sftpBackend) Delete(sha1 string, fileType, thumbType uint8) (err error)
 	return
 }
 
+func getSFTPVariantPath(name string, sha1 string) string {
+	return getVariantURL(DefaultUploadsRoot, name, sha1)
+}
+
+func (b *sftpBackend) WriteVariant(sha1, name string, data []byte) error {
+	return b.replaceFile(getSFTPVariantPath(name, sha1), data)
+}
+
+func (b *sftpBackend) ReadVariant(sha1, name string) ([]byte, error) {
+	return b.readFile(getSFTPVariantPath(name, sha1))
+}
+
+func (b *sftpBackend) DeleteVariant(sha1, name string) error {
+	return b.deleteFile(getSFTPVariantPath(name, sha1))
+}
+
 func connect(addr string, conf *ssh.ClientConfig) (*sftp.Client, error) {
 	sshClient, err := ssh.Dial("tcp", addr, conf)
 	if err != nil {
@@ -1,7 +1,9 @@
 package file
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 
@@ -30,8 +32,12 @@ func getSwiftThumbName(thumbType uint8, sha1 string) string {
 	return getImageURL("", thumbDir, thumbType, sha1)
 }
 
-func (b *swiftBackend) writeFile(name string, data []byte) (err error) {
-	if data == nil {
+func getSwiftVariantName(name string, sha1 string) string {
+	return getVariantURL("", name, sha1)
+}
+
+func (b *swiftBackend) writeFile(name string, r io.Reader) (err error) {
+	if r == nil {
 		return
 	}
 	defer func() {
@@ -44,14 +50,14 @@ func (b *swiftBackend) writeFile(name string, data []byte) (err error) {
 	if err != nil {
 		return
 	}
-	if _, err = f.Write(data); err != nil {
+	if _, err = io.Copy(f, r); err != nil {
 		return
 	}
 	err = f.Close()
 	return
 }
 
-func (b *swiftBackend) Write(sha1 string, fileType, thumbType uint8, src, thumb []byte) error {
+func (b *swiftBackend) Write(sha1 string, fileType, thumbType uint8, src io.ReadSeeker, thumb []byte) error {
 	ch := make(chan error)
 	go func() {
 		// Full path logging might be useful for later manual PURGE.
@@ -60,7 +66,7 @@ func (b *swiftBackend) Write(sha1 string, fileType, thumbType uint8, src, thumb
 	}()
 	go func() {
 		log.Printf("[swift] creating <%s>", ThumbPath(thumbType, sha1))
-		ch <- b.writeFile(getSwiftThumbName(thumbType, sha1), thumb)
+		ch <- b.writeFile(getSwiftThumbName(thumbType, sha1), newOptionalReader(thumb))
 	}()
 	for _, err := range [...]error{<-ch, <-ch} {
 		if err != nil {
@@ -70,6 +76,39 @@ func (b *swiftBackend) Write(sha1 string, fileType, thumbType uint8, src, thumb
 	return nil
 }
 
+func (b *swiftBackend) readFile(name string) (data []byte, err error) {
+	data, err = b.conn.ObjectGetBytes(b.container, name)
+	if err == swift.ObjectNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		err = fmt.Errorf("cannot read Swift object %s from %s: %v", name, b.container, err)
+	}
+	return
+}
+
+func (b *swiftBackend) Read(sha1 string, fileType, thumbType uint8) (src, thumb []byte, err error) {
+	src, err = b.readFile(getSwiftSourceName(fileType, sha1))
+	if err != nil {
+		return
+	}
+	thumb, err = b.readFile(getSwiftThumbName(thumbType, sha1))
+	return
+}
+
+func (b *swiftBackend) ReplaceThumb(sha1 string, oldType, newType uint8, thumb []byte) (err error) {
+	log.Printf("[swift] replacing <%s>", ThumbPath(newType, sha1))
+	err = b.writeFile(getSwiftThumbName(newType, sha1), bytes.NewReader(thumb))
+	if err != nil {
+		return
+	}
+	if oldType != newType {
+		log.Printf("[swift] deleting <%s>", ThumbPath(oldType, sha1))
+		err = b.deleteFile(getSwiftThumbName(oldType, sha1))
+	}
+	return
+}
+
 // TODO(Kagami): PURGE?
 func (b *swiftBackend) deleteFile(name string) (err error) {
 	err = b.conn.ObjectDelete(b.container, name)
@@ -101,6 +140,20 @@ func (b *swiftBackend) Delete(sha1 string, fileType, thumbType uint8) error {
 	return nil
 }
 
+func (b *swiftBackend) WriteVariant(sha1, name string, data []byte) error {
+	log.Printf("[swift] creating <%s>", VariantPath(name, sha1))
+	return b.writeFile(getSwiftVariantName(name, sha1), bytes.NewReader(data))
+}
+
+func (b *swiftBackend) ReadVariant(sha1, name string) ([]byte, error) {
+	return b.readFile(getSwiftVariantName(name, sha1))
+}
+
+func (b *swiftBackend) DeleteVariant(sha1, name string) error {
+	log.Printf("[swift] deleting <%s>", VariantPath(name, sha1))
+	return b.deleteFile(getSwiftVariantName(name, sha1))
+}
+
 func makeSwiftBackend(conf Config) (b fileBackend, err error) {
 	c := swift.Connection{
 		UserName: conf.Username,
@@ -4,6 +4,7 @@ go 1.14
 
 require (
 	github.com/BurntSushi/toml v0.3.1
+	github.com/chai2010/webp v1.1.0
 	github.com/cutechan/blackfriday v0.0.0-20171016195349-a1d253e12717
 	github.com/cutechan/thumbnailer v0.0.0-20180501124647-1dbd31815d4d
 	github.com/dchest/captcha v0.0.0-20170622155422-6a29415a8364
@@ -16,8 +17,13 @@ require (
 	github.com/lib/pq v1.5.2
 	github.com/mailru/easyjson v0.7.1
 	github.com/microcosm-cc/bluemonday v1.0.2
+	github.com/minio/minio-go/v6 v6.0.57
 	github.com/ncw/swift v1.0.50
 	github.com/pkg/sftp v1.11.0
+	github.com/prometheus/client_golang v1.7.1
+	github.com/saracen/go7z v0.0.0-20191010121135-9c09b6bd7fda
+	github.com/saracen/solidblock v0.0.0-20190426153529-45df20abab6f // indirect
+	github.com/ulikunitz/xz v0.5.17
 	github.com/valyala/quicktemplate v1.5.0
 	golang.org/x/crypto v0.0.0-20200429183012-4b2356b1ed79
 )
@@ -3,14 +3,18 @@
 package ipc
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"syscall"
+	"time"
 )
 
 const (
@@ -24,6 +28,7 @@ var (
 	ErrThumbUnsupported = errors.New("unsupported file format")
 	ErrThumbDimensions  = errors.New("unsupported file dimensions")
 	ErrThumbTracks      = errors.New("unsupported track set")
+	ErrThumbTimeout     = errors.New("thumbnailing timed out")
 )
 
 type Thumb struct {
@@ -37,13 +42,37 @@ type Thumb struct {
 	Height    uint16
 	Duration  uint32
 	Title     string
+	Artist    string
 	Data      []byte `json:"-"`
+	Variants  []ThumbVariant
+	Contents  []ArchiveEntry
+	// Perceptual hash of the thumbnail, zero for files without it.
+	PHash uint64
+}
+
+// ArchiveEntry is a single file stored in the uploaded archive.
+type ArchiveEntry struct {
+	Name string
+	Size int64
+}
+
+// ThumbVariant is an additional WebP thumbnail of different size.
+type ThumbVariant struct {
+	Name   string
+	Width  uint16
+	Height uint16
+	// Length of data, used to split thumbnailer output.
+	Size int
+	Data []byte `json:"-"`
 }
 
 // Use LOB-alike encoding:
-// [ VARUINT JSON LENGTH ] [ JSON ] [ DATA ]
+// [ VARUINT JSON LENGTH ] [ JSON ] [ DATA ] [ VARIANT DATA ]...
 // See: https://github.com/telehash/telehash.github.io/blob/master/v3/lob.md
 func (t *Thumb) Marshal() (data []byte, err error) {
+	for i := range t.Variants {
+		t.Variants[i].Size = len(t.Variants[i].Data)
+	}
 	objData, err := json.Marshal(t)
 	if err != nil {
 		err = fmt.Errorf("thumbnailer marshal error: %v", err)
@@ -56,6 +85,9 @@ func (t *Thumb) Marshal() (data []byte, err error) {
 	data = data[:n]
 	data = append(data, objData...)
 	data = append(data, t.Data...)
+	for _, v := range t.Variants {
+		data = append(data, v.Data...)
+	}
 	return
 }
 
@@ -73,7 +105,23 @@ func unmarshalThumb(data []byte) (thumb *Thumb, err error) {
 		err = fmt.Errorf("thumbnailer unmarshal error: %v", err)
 		return
 	}
-	thumb.Data = data[objLen+n:]
+	data = data[objLen+n:]
+
+	// Variants are stored after the main thumbnail.
+	end := len(data)
+	for _, v := range thumb.Variants {
+		end -= v.Size
+	}
+	if end < 0 {
+		err = errors.New("thumbnailer unmarshal error: bad variant size")
+		return
+	}
+	thumb.Data = data[:end]
+	for i := range thumb.Variants {
+		v := &thumb.Variants[i]
+		v.Data = data[end : end+v.Size]
+		end += v.Size
+	}
 	return
 }
 
@@ -84,6 +132,7 @@ func decodeThumbError(s string) error {
 		ErrThumbUnsupported,
 		ErrThumbDimensions,
 		ErrThumbTracks,
+		ErrThumbTimeout,
 	} {
 		if s == e.Error() {
 			return e
@@ -102,44 +151,110 @@ func getExitCode(err error) int {
 	return -1
 }
 
-func getCmdLine(user string) (name string, args []string) {
-	if user == "" {
+// Thumbnailer is killed by signal when exceeding its resource limits.
+func isSignaled(err error) bool {
+	if exiterr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+			return status.Signaled()
+		}
+	}
+	return false
+}
+
+// ThumbOptions controls thumbnailer process execution.
+type ThumbOptions struct {
+	// Spawn thumbnailer as separate user, if set.
+	User string
+	// Wall-clock time limit of a single job.
+	Timeout time.Duration
+	// Resource limits of the thumbnailer process. Zero means no limit.
+	MaxCPU      time.Duration
+	MaxMemory   uint64
+	MaxFileSize uint64
+}
+
+// Encode limits to pass them to the thumbnailer process.
+func (o ThumbOptions) args() []string {
+	return []string{
+		"-timeout", o.Timeout.String(),
+		"-cpu", o.MaxCPU.String(),
+		"-mem", strconv.FormatUint(o.MaxMemory, 10),
+		"-fsize", strconv.FormatUint(o.MaxFileSize, 10),
+	}
+}
+
+// ParseThumbArgs decodes limits passed to the thumbnailer process.
+func ParseThumbArgs(args []string) (o ThumbOptions, err error) {
+	fs := flag.NewFlagSet(THUMB_CMD, flag.ContinueOnError)
+	fs.DurationVar(&o.Timeout, "timeout", 0, "wall-clock time limit")
+	fs.DurationVar(&o.MaxCPU, "cpu", 0, "CPU time limit")
+	fs.Uint64Var(&o.MaxMemory, "mem", 0, "address space limit in bytes")
+	fs.Uint64Var(&o.MaxFileSize, "fsize", 0, "written file size limit in bytes")
+	err = fs.Parse(args)
+	return
+}
+
+func getCmdLine(opts ThumbOptions) (name string, args []string) {
+	if opts.User == "" {
 		name = THUMB_CMD
 	} else {
 		name = "sudo"
-		args = append(args, "-u", user, THUMB_CMD)
+		args = append(args, "-u", opts.User, THUMB_CMD)
 	}
+	args = append(args, opts.args()...)
 	return
 }
 
-// Abstract thumbnailer IPC.
-func GetThumbnail(user string, srcData []byte) (thumb *Thumb, err error) {
+// Abstract thumbnailer IPC. Source file descriptor is passed to the
+// thumbnailer as its stdin, so the file is never loaded into memory of
+// the calling process.
+func GetThumbnail(opts ThumbOptions, src *os.File) (thumb *Thumb, err error) {
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		err = fmt.Errorf("thumbnailer OS error: %v", err)
+		return
+	}
+
 	// Start process.
-	name, args := getCmdLine(user)
+	name, args := getCmdLine(opts)
 	cmd := exec.Command(name, args...)
+	cmd.Stdin = src
+	var out bytes.Buffer
+	cmd.Stdout = &out
 	cmd.Stderr = os.Stderr
-	in, err := cmd.StdinPipe()
-	if err != nil {
+	// Allows to kill the thumbnailer along with the sudo wrapper.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err = cmd.Start(); err != nil {
 		err = fmt.Errorf("thumbnailer OS error: %v", err)
 		return
 	}
-	out, err := cmd.StdoutPipe()
-	if err != nil {
-		err = fmt.Errorf("thumbnailer OS error: %v", err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		t := time.NewTimer(opts.Timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case err = <-done:
+	case <-timeout:
+		// Killing might fail with separate user, but thumbnailer also
+		// terminates itself after the same timeout. Don't wait for it.
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		err = ErrThumbTimeout
 		return
 	}
-	cmd.Start()
 
-	// Pass input and get output.
-	in.Write(srcData)
-	in.Close()
-	// Don't need to process error here because it will be handled later.
-	data, _ := ioutil.ReadAll(out)
-
-	// Wait for exit and decode error.
-	if err = cmd.Wait(); err != nil {
+	// Decode error.
+	data := out.Bytes()
+	if err != nil {
 		if getExitCode(err) == THUMB_ERROR_EXIT_CODE {
 			err = decodeThumbError(string(data))
+		} else if isSignaled(err) {
+			err = ErrThumbProcess
 		} else {
 			err = fmt.Errorf("thumbnailer OS error: %v", err)
 		}
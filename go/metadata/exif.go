@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"encoding/binary"
+
+	"github.com/cutechan/cutechan/go/common"
+)
+
+// TIFF tags we are interested in, see EXIF 2.32 spec.
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+
+	typeASCII = 2
+	typeShort = 3
+	typeLong  = 4
+
+	lenIFDEntry = 12
+)
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// Collect summary fields from TIFF structure of EXIF block and return
+// image orientation. Malformed parts are silently skipped, the block is
+// removed anyway.
+func parseExif(data []byte, sum *common.MetadataSummary) (orientation uint16) {
+	if len(data) < 8 {
+		return
+	}
+	t := tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return
+	}
+
+	var exifIFD uint32
+	t.readIFD(t.order.Uint32(data[4:]), func(tag, typ uint16, count uint32, val []byte) {
+		switch tag {
+		case tagMake:
+			sum.Make = t.readString(typ, count, val)
+		case tagModel:
+			sum.Model = t.readString(typ, count, val)
+		case tagSoftware:
+			sum.Software = t.readString(typ, count, val)
+		case tagDateTime:
+			sum.DateTime = t.readString(typ, count, val)
+		case tagOrientation:
+			if typ == typeShort {
+				orientation = t.order.Uint16(val)
+			}
+		case tagExifIFD:
+			if typ == typeLong {
+				exifIFD = t.order.Uint32(val)
+			}
+		case tagGPSIFD:
+			sum.GPS = true
+		}
+	})
+	if exifIFD != 0 {
+		t.readIFD(exifIFD, func(tag, typ uint16, count uint32, val []byte) {
+			if tag == tagDateTimeOriginal {
+				// Time of capture is more relevant than time of editing.
+				if s := t.readString(typ, count, val); s != "" {
+					sum.DateTime = s
+				}
+			}
+		})
+	}
+	return
+}
+
+// Call fn for each entry of the IFD at offset. val holds the 4-byte value
+// or offset field of the entry.
+func (t tiffReader) readIFD(
+	off uint32,
+	fn func(tag, typ uint16, count uint32, val []byte),
+) {
+	if off < 8 || uint64(off)+2 > uint64(len(t.data)) {
+		return
+	}
+	n := int(t.order.Uint16(t.data[off:]))
+	entries := t.data[off+2:]
+	for i := 0; i < n && (i+1)*lenIFDEntry <= len(entries); i++ {
+		e := entries[i*lenIFDEntry:]
+		fn(t.order.Uint16(e), t.order.Uint16(e[2:]), t.order.Uint32(e[4:]), e[8:12])
+	}
+}
+
+func (t tiffReader) readString(typ uint16, count uint32, val []byte) string {
+	if typ != typeASCII {
+		return ""
+	}
+	if count <= 4 {
+		return cleanString(string(val[:count]))
+	}
+	off := uint64(t.order.Uint32(val))
+	if off+uint64(count) > uint64(len(t.data)) {
+		return ""
+	}
+	return cleanString(string(t.data[off : off+uint64(count)]))
+}
+
+// Build minimal EXIF block containing only orientation, so rotated photos
+// are still displayed correctly.
+func orientationExif(orientation uint16) []byte {
+	buf := make([]byte, 0, len(exifHeader)+26)
+	buf = append(buf, exifHeader...)
+	// Big-endian TIFF header with IFD0 right after it.
+	buf = append(buf, 'M', 'M', 0, 42, 0, 0, 0, 8)
+	// Single entry.
+	buf = append(buf, 0, 1)
+	buf = append(buf, tagOrientation>>8, tagOrientation&0xFF, 0, typeShort)
+	buf = append(buf, 0, 0, 0, 1)
+	buf = append(buf, byte(orientation>>8), byte(orientation), 0, 0)
+	// No next IFD.
+	buf = append(buf, 0, 0, 0, 0)
+	return buf
+}
@@ -0,0 +1,152 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/cutechan/cutechan/go/common"
+)
+
+// JPEG markers, see ITU T.81 Table B.1.
+const (
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerAPP0 = 0xE0
+	markerAPP1 = 0xE1
+	markerAPP2 = 0xE2
+	markerAPPD = 0xED
+	markerAPPE = 0xEE
+	markerAPPF = 0xEF
+	markerCOM  = 0xFE
+)
+
+var (
+	exifHeader  = []byte("Exif\x00\x00")
+	xmpHeader   = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	iccHeader   = []byte("ICC_PROFILE\x00")
+	adobeHeader = []byte("Adobe")
+)
+
+// Copy JPEG segments except those carrying metadata. ICC profile and
+// Adobe color transform are kept because they affect rendering, as is
+// EXIF orientation. Only the header segments up to the first scan are
+// parsed. Everything from there on, including truncated scans and data
+// appended after the image, is copied through unchanged.
+func stripJPEG(r *bufio.Reader, w *bufio.Writer, sum *common.MetadataSummary) (err error) {
+	if _, err = readFull(r, 2); err != nil {
+		return
+	}
+	if _, err = w.Write([]byte{0xFF, markerSOI}); err != nil {
+		return
+	}
+
+	for {
+		var marker byte
+		if marker, err = readMarker(r); err != nil {
+			if err == io.EOF {
+				err = ErrMalformed
+			}
+			return
+		}
+		if marker == markerEOI {
+			// No image data at all.
+			return ErrMalformed
+		}
+
+		var data []byte
+		if data, err = readSegment(r); err != nil {
+			return
+		}
+		data = filterSegment(marker, data, sum)
+		if data != nil {
+			if err = writeSegment(w, marker, data); err != nil {
+				return
+			}
+		}
+
+		if marker == markerSOS {
+			_, err = io.Copy(w, r)
+			return
+		}
+	}
+}
+
+// Return segment data to write or nil, if segment should be dropped.
+func filterSegment(marker byte, data []byte, sum *common.MetadataSummary) []byte {
+	switch {
+	case marker == markerAPP0:
+		return data
+	case marker == markerAPP1 && bytes.HasPrefix(data, exifHeader):
+		addRemoved(sum, "EXIF")
+		orientation := parseExif(data[len(exifHeader):], sum)
+		if orientation > 1 && orientation <= 8 {
+			return orientationExif(orientation)
+		}
+		return nil
+	case marker == markerAPP1 && bytes.HasPrefix(data, xmpHeader):
+		addRemoved(sum, "XMP")
+		return nil
+	case marker == markerAPP2 && bytes.HasPrefix(data, iccHeader):
+		return data
+	case marker == markerAPPD:
+		addRemoved(sum, "IPTC")
+		return nil
+	case marker == markerAPPE && bytes.HasPrefix(data, adobeHeader):
+		return data
+	case marker >= markerAPP1 && marker <= markerAPPF:
+		addRemoved(sum, fmt.Sprintf("APP%d", marker-markerAPP0))
+		return nil
+	case marker == markerCOM:
+		addRemoved(sum, "comment")
+		return nil
+	default:
+		return data
+	}
+}
+
+// Read marker, skipping fill bytes.
+func readMarker(r *bufio.Reader) (marker byte, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	if b != 0xFF {
+		err = ErrMalformed
+		return
+	}
+	for {
+		if marker, err = r.ReadByte(); err != nil {
+			return
+		}
+		if marker != 0xFF {
+			return
+		}
+	}
+}
+
+func readSegment(r *bufio.Reader) (data []byte, err error) {
+	buf, err := readFull(r, 2)
+	if err != nil {
+		return
+	}
+	size := int(binary.BigEndian.Uint16(buf))
+	if size < 2 {
+		err = ErrMalformed
+		return
+	}
+	return readFull(r, size-2)
+}
+
+func writeSegment(w *bufio.Writer, marker byte, data []byte) (err error) {
+	head := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(head[2:], uint16(len(data)+2))
+	if _, err = w.Write(head); err != nil {
+		return
+	}
+	_, err = w.Write(data)
+	return
+}
@@ -0,0 +1,84 @@
+// Package metadata strips EXIF and similar metadata from uploaded images.
+// Works on the container level, so pixel data is copied as is.
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cutechan/cutechan/go/common"
+)
+
+const (
+	// Summary is stored in DB, so keep strings short.
+	maxLenSummaryField = 100
+)
+
+var (
+	ErrMalformed = errors.New("malformed image")
+
+	jpegSignature = []byte{0xFF, 0xD8}
+	pngSignature  = []byte("\x89PNG\r\n\x1A\n")
+)
+
+// Strip copies image from r to w omitting all metadata. Returns nil
+// summary if image format is not supported or there was nothing to
+// remove, in which case data written to w should be discarded and the
+// original file used instead.
+func Strip(r io.Reader, w io.Writer) (sum *common.MetadataSummary, err error) {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	head, _ := br.Peek(len(pngSignature))
+
+	sum = &common.MetadataSummary{}
+	switch {
+	case bytes.HasPrefix(head, jpegSignature):
+		err = stripJPEG(br, bw, sum)
+	case bytes.HasPrefix(head, pngSignature):
+		err = stripPNG(br, bw, sum)
+	default:
+		return nil, nil
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil || len(sum.Removed) == 0 {
+		sum = nil
+	}
+	return
+}
+
+// Record removed kind of metadata, once per kind.
+func addRemoved(sum *common.MetadataSummary, kind string) {
+	for _, k := range sum.Removed {
+		if k == kind {
+			return
+		}
+	}
+	sum.Removed = append(sum.Removed, kind)
+}
+
+// Make metadata string safe to store and display.
+func cleanString(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	s = strings.TrimRight(s, "\x00 ")
+	if utf8.RuneCountInString(s) > maxLenSummaryField {
+		s = string([]rune(s)[:maxLenSummaryField])
+	}
+	return s
+}
+
+// Read exactly n bytes or report malformed file.
+func readFull(r io.Reader, n int) (buf []byte, err error) {
+	buf = make([]byte, n)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrMalformed
+		}
+	}
+	return
+}
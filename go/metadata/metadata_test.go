@@ -0,0 +1,197 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func sampleImage() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 16, 8))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	return img
+}
+
+// Big-endian TIFF structure with Make, Orientation and empty GPS IFD.
+func sampleExif(orientation uint16) []byte {
+	var b bytes.Buffer
+	w := func(v interface{}) { binary.Write(&b, binary.BigEndian, v) }
+	b.WriteString("MM")
+	w(uint16(42))
+	w(uint32(8))
+	// IFD0 with 3 entries, values follow it.
+	w(uint16(3))
+	w([]uint16{tagMake, typeASCII})
+	w(uint32(6))
+	w(uint32(8 + 2 + 3*lenIFDEntry + 4))
+	w([]uint16{tagOrientation, typeShort})
+	w(uint32(1))
+	w([]uint16{orientation, 0})
+	w([]uint16{tagGPSIFD, typeLong})
+	w(uint32(1))
+	w(uint32(8 + 2 + 3*lenIFDEntry + 4 + 6))
+	w(uint32(0))
+	b.WriteString("Canon\x00")
+	// Empty GPS IFD.
+	w(uint16(0))
+	w(uint32(0))
+	return b.Bytes()
+}
+
+func jpegSegment(marker byte, data []byte) []byte {
+	var b bytes.Buffer
+	head := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(head[2:], uint16(len(data)+2))
+	b.Write(head)
+	b.Write(data)
+	return b.Bytes()
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint32(len(data)))
+	b.WriteString(typ)
+	b.Write(data)
+	crc := crc32.ChecksumIEEE(append([]byte(typ), data...))
+	binary.Write(&b, binary.BigEndian, crc)
+	return b.Bytes()
+}
+
+func strip(t *testing.T, src []byte) ([]byte, *common.MetadataSummary) {
+	t.Helper()
+	var out bytes.Buffer
+	sum, err := Strip(bytes.NewReader(src), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes(), sum
+}
+
+func TestStripJPEG(t *testing.T) {
+	var enc bytes.Buffer
+	if err := jpeg.Encode(&enc, sampleImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	clean := enc.Bytes()
+
+	var src bytes.Buffer
+	src.Write(clean[:2])
+	src.Write(jpegSegment(markerAPP1, append(exifHeader, sampleExif(6)...)))
+	src.Write(jpegSegment(markerAPP1, append(xmpHeader, "<x:xmpmeta/>"...)))
+	src.Write(jpegSegment(markerCOM, []byte("secret comment")))
+	src.Write(clean[2:])
+	src.WriteString("PK\x03\x04trailing")
+
+	out, sum := strip(t, src.Bytes())
+	AssertDeepEquals(t, sum, &common.MetadataSummary{
+		Removed: []string{"EXIF", "XMP", "comment"},
+		Make:    "Canon",
+		GPS:     true,
+	})
+	for _, s := range []string{"Canon", "xmpmeta", "secret"} {
+		if bytes.Contains(out, []byte(s)) {
+			t.Fatalf("%q not stripped", s)
+		}
+	}
+
+	// Orientation is preserved.
+	var expected bytes.Buffer
+	expected.Write(clean[:2])
+	expected.Write(jpegSegment(markerAPP1, orientationExif(6)))
+	expected.Write(clean[2:])
+	expected.WriteString("PK\x03\x04trailing")
+	AssertBufferEquals(t, out, expected.Bytes())
+
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStripCleanJPEG(t *testing.T) {
+	var enc bytes.Buffer
+	if err := jpeg.Encode(&enc, sampleImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	_, sum := strip(t, enc.Bytes())
+	if sum != nil {
+		t.Fatalf("unexpected summary: %#v", sum)
+	}
+}
+
+func TestStripTruncatedJPEG(t *testing.T) {
+	var enc bytes.Buffer
+	if err := jpeg.Encode(&enc, sampleImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	clean := enc.Bytes()[:enc.Len()-8]
+
+	var src bytes.Buffer
+	src.Write(clean[:2])
+	src.Write(jpegSegment(markerCOM, []byte("secret comment")))
+	src.Write(clean[2:])
+
+	// Scan data is copied as is, even if cut short.
+	out, sum := strip(t, src.Bytes())
+	AssertDeepEquals(t, sum, &common.MetadataSummary{
+		Removed: []string{"comment"},
+	})
+	AssertBufferEquals(t, out, clean)
+}
+
+func TestStripMalformedJPEG(t *testing.T) {
+	var enc bytes.Buffer
+	if err := jpeg.Encode(&enc, sampleImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Cut inside the quantization table, before any scan.
+	var out bytes.Buffer
+	_, err := Strip(bytes.NewReader(enc.Bytes()[:10]), &out)
+	if err != ErrMalformed {
+		LogUnexpected(t, ErrMalformed, err)
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	var enc bytes.Buffer
+	if err := png.Encode(&enc, sampleImage()); err != nil {
+		t.Fatal(err)
+	}
+	clean := enc.Bytes()
+	// Signature and IHDR chunk.
+	split := len(pngSignature) + 8 + 13 + 4
+
+	var src bytes.Buffer
+	src.Write(clean[:split])
+	src.Write(pngChunk("tEXt", []byte("Author\x00Somebody")))
+	src.Write(pngChunk("eXIf", sampleExif(1)))
+	src.Write(pngChunk("tIME", make([]byte, 7)))
+	src.Write(clean[split:])
+
+	out, sum := strip(t, src.Bytes())
+	AssertDeepEquals(t, sum, &common.MetadataSummary{
+		Removed: []string{"text: Author", "EXIF", "modification time"},
+		Make:    "Canon",
+		GPS:     true,
+	})
+	AssertBufferEquals(t, out, clean)
+}
+
+func TestStripUnsupported(t *testing.T) {
+	var out bytes.Buffer
+	sum, err := Strip(bytes.NewReader([]byte("GIF89a")), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != nil || out.Len() != 0 {
+		t.Fatal("unsupported format processed")
+	}
+}
@@ -0,0 +1,119 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/cutechan/cutechan/go/common"
+)
+
+const (
+	// PNG spec limits chunk length to 2^31-1.
+	maxChunkSize = 1<<31 - 1
+	// Larger eXIf chunks are dropped without parsing.
+	maxExifSize = 1 << 16
+	// Keywords are 1-79 bytes followed by null separator.
+	maxLenKeyword = 80
+)
+
+// Ancillary chunks containing metadata. Other chunks are copied, since
+// they may affect rendering.
+var pngMetadataChunks = map[string]string{
+	"tEXt": "text",
+	"zTXt": "text",
+	"iTXt": "text",
+	"eXIf": "EXIF",
+	"tIME": "modification time",
+}
+
+func stripPNG(r *bufio.Reader, w *bufio.Writer, sum *common.MetadataSummary) (err error) {
+	sig, err := readFull(r, len(pngSignature))
+	if err != nil {
+		return
+	}
+	if _, err = w.Write(sig); err != nil {
+		return
+	}
+
+	for {
+		var head []byte
+		head, err = readFull(r, 8)
+		if err != nil {
+			return
+		}
+		size := int64(binary.BigEndian.Uint32(head))
+		if size > maxChunkSize {
+			return ErrMalformed
+		}
+		typ := string(head[4:])
+		// Data followed by CRC.
+		chunk := &io.LimitedReader{R: r, N: size + 4}
+
+		if kind, ok := pngMetadataChunks[typ]; ok {
+			if kind == "text" {
+				if k := textKeyword(r, size); k != "" {
+					kind += ": " + k
+				}
+			}
+			addRemoved(sum, kind)
+			if typ == "eXIf" && size <= maxExifSize {
+				var data []byte
+				data, err = readFull(chunk, int(size))
+				if err != nil {
+					return
+				}
+				parseExif(data, sum)
+			}
+			if err = skipChunk(chunk); err != nil {
+				return
+			}
+			continue
+		}
+
+		if _, err = w.Write(head); err != nil {
+			return
+		}
+		var n int64
+		n, err = io.Copy(w, chunk)
+		if err != nil {
+			return
+		}
+		if n != size+4 {
+			return ErrMalformed
+		}
+
+		if typ == "IEND" {
+			if _, peekErr := r.Peek(1); peekErr == nil {
+				addRemoved(sum, "trailing data")
+			}
+			return
+		}
+	}
+}
+
+// Discard rest of the chunk, making sure it's complete.
+func skipChunk(chunk *io.LimitedReader) error {
+	if _, err := io.Copy(ioutil.Discard, chunk); err != nil {
+		return err
+	}
+	if chunk.N != 0 {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// Keyword of textual chunk, which usually tells what kind of metadata it
+// holds, e.g. "Author" or "XML:com.adobe.xmp".
+func textKeyword(r *bufio.Reader, size int64) string {
+	if size > maxLenKeyword {
+		size = maxLenKeyword
+	}
+	data, _ := r.Peek(int(size))
+	if i := bytes.IndexByte(data, 0); i > 0 {
+		return cleanString(string(data[:i]))
+	}
+	return ""
+}
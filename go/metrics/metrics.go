@@ -0,0 +1,130 @@
+// Package metrics defines Prometheus collectors for the server internals and
+// exposes them over HTTP.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cutechan"
+
+var (
+	// HTTPRequests counts served HTTP requests by route pattern, method and
+	// response status code.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of served HTTP requests.",
+	}, []string{"route", "method", "code"})
+	// HTTPDuration tracks HTTP handler latencies by route pattern and method.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP handler latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// WebsocketClients is the number of connected websocket clients.
+	WebsocketClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "clients",
+		Help:      "Number of connected websocket clients.",
+	})
+	// Feeds is the number of running thread update feeds.
+	Feeds = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "feeds",
+		Name:      "active",
+		Help:      "Number of running thread update feeds.",
+	})
+	// FeedFlushSize tracks the size of buffered feed messages sent to
+	// clients on each flush.
+	FeedFlushSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "feeds",
+		Name:      "flush_bytes",
+		Help:      "Size of buffered messages sent on feed flush.",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+	})
+	// FeedFlushClients tracks the number of clients a feed flush is sent to.
+	FeedFlushClients = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "feeds",
+		Name:      "flush_clients",
+		Help:      "Number of clients receiving a feed flush.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	// ThumbnailDuration tracks thumbnailer job latency.
+	ThumbnailDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "thumbnailer",
+		Name:      "job_duration_seconds",
+		Help:      "Thumbnailer job latency.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+	// ThumbnailQueue is the number of uploads waiting for a thumbnailer.
+	ThumbnailQueue = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "thumbnailer",
+		Name:      "queue_depth",
+		Help:      "Number of uploads waiting for a thumbnailer worker.",
+	})
+
+	// CacheHits counts cache lookups, that were served from fresh data.
+	CacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Number of cache hits.",
+	})
+	// CacheMisses counts cache lookups, that required regenerating data.
+	CacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Number of cache misses.",
+	})
+	// CacheEvictions counts entries evicted over the cache size limit.
+	CacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "Number of evicted cache entries.",
+	})
+	// CacheUsed is the estimated memory used by the cache.
+	CacheUsed = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "used_bytes",
+		Help:      "Estimated memory used by the cache.",
+	})
+
+	// DBStatementDuration tracks prepared statement latencies by statement
+	// name.
+	DBStatementDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "statement_duration_seconds",
+		Help:      "Prepared statement latency.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"statement"})
+)
+
+// Handler serves collected metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Since returns seconds elapsed since start for observing latencies.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -14,6 +14,11 @@ import (
 	b "github.com/cutechan/blackfriday"
 )
 
+// Export without circular dependency
+func init() {
+	common.ParseBody = ParseBody
+}
+
 // Check thread subject string.
 func ParseSubject(s string) (string, error) {
 	if s == "" {
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cutechan/cutechan/go/auth"
@@ -228,6 +229,21 @@ func deletePost(w http.ResponseWriter, r *http.Request) {
 	moderatePosts(w, r, auth.Moderator, db.DeletePost)
 }
 
+// Restore one or multiple deleted posts
+func undeletePost(w http.ResponseWriter, r *http.Request) {
+	moderatePosts(w, r, auth.Moderator, db.UndeletePost)
+}
+
+// Delete files of one or multiple posts, keeping their text
+func deleteImage(w http.ResponseWriter, r *http.Request) {
+	moderatePosts(w, r, auth.Janitor, db.DeletePostImages)
+}
+
+// Hide thumbnails of one or multiple posts behind a spoiler
+func spoilerImage(w http.ResponseWriter, r *http.Request) {
+	moderatePosts(w, r, auth.Janitor, db.SpoilerPostImages)
+}
+
 // Perform a moderation action an a single post. If ok == false, the caller
 // should return.
 func moderatePost(
@@ -247,7 +263,7 @@ func moderatePost(
 	switch err := fn(userID); err {
 	case nil:
 		return true
-	case sql.ErrNoRows:
+	case sql.ErrNoRows, db.ErrNoImage:
 		text400(w, err)
 		return
 	default:
@@ -268,7 +284,7 @@ func moderatePosts(
 		return
 	}
 	for _, id := range ids {
-		ok := moderatePost(w, r, id, auth.Moderator, func(userID string) error {
+		ok := moderatePost(w, r, id, level, func(userID string) error {
 			return fn(id, userID)
 		})
 		if !ok {
@@ -281,10 +297,12 @@ func moderatePosts(
 // Ban a specific IP from a specific board
 func ban(w http.ResponseWriter, r *http.Request) {
 	var msg struct {
-		Global   bool
-		Duration uint64
-		Reason   string
-		IDs      []uint64
+		Global     bool
+		Duration   uint64
+		Reason     string
+		IDs        []uint64
+		IPv4Prefix int
+		IPv6Prefix int
 	}
 
 	// Decode and validate
@@ -305,6 +323,11 @@ func ban(w http.ResponseWriter, r *http.Request) {
 		text400(w, errNoDuration)
 		return
 	}
+	prefixes := auth.BanPrefixes{IPv4: msg.IPv4Prefix, IPv6: msg.IPv6Prefix}
+	if !prefixes.Valid() {
+		text400(w, auth.ErrInvalidBanRange)
+		return
+	}
 
 	// Group posts by board
 	byBoard := make(map[string][]uint64, 2)
@@ -337,7 +360,7 @@ func ban(w http.ResponseWriter, r *http.Request) {
 	// Apply bans
 	expires := time.Now().Add(time.Duration(msg.Duration) * time.Minute)
 	for board, ids := range byBoard {
-		ips, err := db.Ban(board, msg.Reason, ss.UserID, expires, ids...)
+		ips, err := db.Ban(board, msg.Reason, ss.UserID, expires, prefixes, ids...)
 		if err != nil {
 			text500(w, r, err)
 			return
@@ -345,7 +368,7 @@ func ban(w http.ResponseWriter, r *http.Request) {
 
 		// Redirect all banned connected clients to the /all/ board
 		for ip := range ips {
-			for _, cl := range common.GetByIPAndBoard(ip, board) {
+			for _, cl := range common.GetByNetAndBoard(ip, board) {
 				cl.Redirect("all")
 			}
 		}
@@ -404,15 +427,16 @@ func sendNotification(w http.ResponseWriter, r *http.Request) {
 	if !decodeJSON(w, r, &msg) || !isAdmin(w, r) {
 		return
 	}
+	if strings.TrimSpace(msg) == "" || len(msg) > common.MaxLenNotification {
+		serveErrorJSON(w, r, aerrBadNotification)
+		return
+	}
 
-	data, err := common.EncodeMessage(common.MessageNotification, msg)
-	if err != nil {
+	if err := feeds.SendNotification(msg); err != nil {
 		text500(w, r, err)
 		return
 	}
-	for _, cl := range feeds.All() {
-		cl.Send(data)
-	}
+	serveEmptyJSON(w, r)
 }
 
 // Retrieve posts with the same IP on the target board
@@ -435,6 +459,25 @@ func getSameIPPosts(w http.ResponseWriter, r *http.Request) {
 	serveJSON(w, r, posts)
 }
 
+// Serve summaries of metadata stripped from post files
+func servePostMetadata(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(getParam(r, "post"), 10, 64)
+	if err != nil {
+		text400(w, err)
+		return
+	}
+	if _, _, ok := canModeratePost(w, r, id, auth.Moderator); !ok {
+		return
+	}
+
+	metas, err := db.GetPostFilesMetadata(id)
+	if err != nil {
+		text500(w, r, err)
+		return
+	}
+	serveJSON(w, r, metas)
+}
+
 // Set the sticky flag of a thread
 func setThreadSticky(w http.ResponseWriter, r *http.Request) {
 	var msg struct {
@@ -444,12 +487,70 @@ func setThreadSticky(w http.ResponseWriter, r *http.Request) {
 	if !decodeJSON(w, r, &msg) {
 		return
 	}
-	if _, _, ok := canModeratePost(w, r, msg.ID, auth.Moderator); !ok {
+	_, userID, ok := canModeratePost(w, r, msg.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+
+	switch err := db.SetThreadSticky(msg.ID, msg.Sticky, userID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		text400(w, err)
+	default:
+		text500(w, r, err)
+	}
+}
+
+// Set the locked flag of a thread
+func setThreadLocked(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID     uint64
+		Locked bool
+	}
+	if !decodeJSON(w, r, &msg) {
+		return
+	}
+	_, userID, ok := canModeratePost(w, r, msg.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+
+	switch err := db.SetThreadLocked(msg.ID, msg.Locked, userID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		text400(w, err)
+	default:
+		text500(w, r, err)
+	}
+}
+
+// Move thread to another board. Client must be able to moderate both
+// boards.
+func moveThread(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID    uint64
+		Board string
+	}
+	if !decodeJSON(w, r, &msg) {
+		return
+	}
+	from, userID, ok := canModeratePost(w, r, msg.ID, auth.Moderator)
+	if !ok {
+		return
+	}
+	if msg.Board == from || msg.Board == "all" {
+		text400(w, errInvalidBoard)
+		return
+	}
+	if _, ok := assertCanPerform(w, r, msg.Board, auth.Moderator); !ok {
 		return
 	}
 
-	switch err := db.SetThreadSticky(msg.ID, msg.Sticky); err {
+	switch err := db.MoveThread(msg.ID, from, msg.Board, userID); err {
 	case nil:
+		serveEmptyJSON(w, r)
 	case sql.ErrNoRows:
 		text400(w, err)
 	default:
@@ -471,6 +572,19 @@ func serveAdmin(
 		return
 	}
 
+	// Report queue is available to all staff
+	staffBoards, err := db.GetStaffBoards(ss.UserID)
+	if err != nil {
+		text500(w, r, err)
+		return
+	}
+
+	reports, err := db.GetReports(staffBoards)
+	if err != nil {
+		text500(w, r, err)
+		return
+	}
+
 	staff, err := db.GetStaff(nil, boards)
 	if err != nil {
 		text500(w, r, err)
@@ -489,9 +603,18 @@ func serveAdmin(
 		return
 	}
 
+	appeals, err := db.GetAppeals(boards)
+	if err != nil {
+		text500(w, r, err)
+		return
+	}
+
 	l := lang.FromReq(r)
-	cs := config.GetBoardConfigsByID(boards)
-	html := templates.Admin(templates.Params{r, ss, l}, cs, staff, bans, log)
+	cs := config.GetBoardConfigsByID(staffBoards)
+	html := templates.Admin(
+		templates.Params{r, ss, l},
+		cs, boards, staff, bans, log, reports, appeals,
+	)
 	serveHTML(w, r, html)
 }
 
@@ -509,6 +632,9 @@ func checkBoardState(board string, state db.BoardState) (err error) {
 		err = aerrTitleTooLong
 		return
 	}
+	if err = checkBoardLimits(state.Settings); err != nil {
+		return
+	}
 	if len(state.Staff) > common.MaxLenStaffList {
 		err = aerrTooManyStaff
 		return
@@ -549,6 +675,39 @@ func checkBoardState(board string, state db.BoardState) (err error) {
 	return
 }
 
+// Validate board limits. Boards can only tighten server-wide file limits.
+func checkBoardLimits(c config.BoardConfig) error {
+	conf := config.Get()
+	switch {
+	case c.MaxThreads < 0 || c.MaxThreads > common.MaxThreadsLimit:
+		return aerrBadThreadLimit
+	case c.BumpLimit < 0 || c.BumpLimit > common.MaxBumpLimit:
+		return aerrBadBumpLimit
+	case c.MaxFiles < 0 || c.MaxFiles > conf.MaxFiles:
+		return aerrBadFileLimit
+	case c.MaxSize < 0 || c.MaxSize > conf.MaxSize:
+		return aerrBadFileLimit
+	case c.ThreadCooldown < 0 || c.ThreadCooldown > common.MaxCooldown,
+		c.PostCooldown < 0 || c.PostCooldown > common.MaxCooldown:
+		return aerrBadCooldown
+	case len(c.AllowedTypes) > len(common.Extensions):
+		return aerrBadFileType
+	}
+	for _, t := range c.AllowedTypes {
+		known := false
+		for _, ext := range common.Extensions {
+			if t == ext {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return aerrBadFileType
+		}
+	}
+	return nil
+}
+
 func equalStates(oldState, newState db.BoardState) bool {
 	return reflect.DeepEqual(oldState, newState)
 }
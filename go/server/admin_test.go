@@ -2,7 +2,10 @@ package server
 
 import (
 	"bytes"
-	"fmt"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
@@ -11,10 +14,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
-var adminLoginCreds = auth.SessionCreds{
+var adminLoginCreds = sessionCreds{
 	UserID:  "admin",
 	Session: genSession(),
 }
@@ -35,162 +41,76 @@ func TestNotBoardOwner(t *testing.T) {
 	writeSampleBoard(t)
 	writeSampleUser(t)
 
-	paths := [...]string{
-		"/api/configure-board/a",
-		"/html/configure-board/a",
-	}
-	for _, p := range paths {
-		t.Run(p, func(t *testing.T) {
-			t.Parallel()
-
-			rec, req := newJSONPair(t, p, map[string]string{})
-			router.ServeHTTP(rec, req)
-			assertError(t, rec, 403, errAccessDenied)
-		})
-	}
-}
-
-func TestServePrivateBoardConfigs(t *testing.T) {
-	assertTableClear(t, "boards", "accounts")
-
-	config.ClearBoards()
-	conf := db.BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
-			ID: "a",
-		},
-	}
-	_, err := config.SetBoardConfigs(conf.BoardConfigs)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if err := db.WriteBoard(nil, conf); err != nil {
-		t.Fatal(err)
-	}
-	writeSampleUser(t)
-	writeSampleBoardOwner(t)
-
-	rec, req := newJSONPair(t, "/api/board-config/a", nil)
-	setLoginCookies(req, sampleLoginCreds)
-	router.ServeHTTP(rec, req)
-	assertCode(t, rec, 200)
-	assertBody(t, rec, string(marshalJSON(t, conf.BoardConfigs)))
-}
-
-func TestBoardConfiguration(t *testing.T) {
-	assertTableClear(t, "accounts", "boards")
-
-	const board = "a"
-	conf := config.BoardConfigs{
-		ID: board,
-		BoardPublic: config.BoardPublic{
-			Title: "123",
-		},
-	}
-	init := db.BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
-			ID: board,
-		},
-	}
-	if err := db.WriteBoard(nil, init); err != nil {
-		t.Fatal(err)
-	}
-
-	writeSampleUser(t)
-	writeSampleBoardOwner(t)
-
-	data := boardConfigSettingRequest{
-		BoardConfigs: conf,
-	}
-	rec, req := newJSONPair(t, "/api/configure-board/a", data)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(
+		"PUT",
+		"/api/boards/a",
+		encodeBody(t, map[string]string{}),
+	)
 	setLoginCookies(req, sampleLoginCreds)
-	router.ServeHTTP(rec, req)
-	assertCode(t, rec, 200)
-
-	res, err := db.GetBoardConfigs(board)
-	if err != nil {
-		t.Fatal(err)
-	}
-	AssertDeepEquals(t, res, conf)
-}
-
-func TestValidateBoardConfigs(t *testing.T) {
-	t.Parallel()
-
-	cases := [...]struct {
-		name string
-		config.BoardConfigs
-		err error
-	}{
-		{
-			"all is well",
-			config.BoardConfigs{},
-			nil,
-		},
-		{
-			"title too long",
-			config.BoardConfigs{
-				BoardPublic: config.BoardPublic{
-					Title: GenString(common.MaxLenBoardTitle + 1),
-				},
-			},
-			errTitleTooLong,
-		},
-	}
-
-	for i := range cases {
-		c := cases[i]
-		t.Run(c.name, func(t *testing.T) {
-			t.Parallel()
-
-			rec := httptest.NewRecorder()
-			if b := validateBoardConfigs(rec, c.BoardConfigs); b != (c.err == nil) {
-				t.Fatal("unexpected result")
-			}
-			if c.err != nil {
-				assertCode(t, rec, 400)
-				assertBody(t, rec, fmt.Sprintf("400 %s\n", c.err))
-			}
-		})
-	}
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+	assertBody(t, rec, string(marshalJSON(t, aerrBoardOwnersOnly)))
 }
 
 func TestValidateBoardCreation(t *testing.T) {
 	assertTableClear(t, "boards", "accounts")
 	writeSampleBoard(t)
 	writeSampleUser(t)
+	writeAdminAccount(t)
 
 	cases := [...]struct {
 		name, id, title string
+		creds           sessionCreds
 		err             error
 	}{
+		{
+			name:  "not admin",
+			id:    "b",
+			title: "foo",
+			creds: sampleLoginCreds,
+			err:   errAccessDenied,
+		},
 		{
 			name:  "board name too long",
 			id:    GenString(common.MaxLenBoardID + 1),
 			title: "foo",
+			creds: adminLoginCreds,
 			err:   errInvalidBoardName,
 		},
 		{
 			name:  "empty board name",
 			id:    "",
 			title: "foo",
+			creds: adminLoginCreds,
 			err:   errInvalidBoardName,
 		},
 		{
 			name:  "invalid chars in board name",
 			id:    ":^)",
 			title: "foo",
+			creds: adminLoginCreds,
+			err:   errInvalidBoardName,
+		},
+		{
+			name:  "reserved board name",
+			id:    "all",
+			title: "foo",
+			creds: adminLoginCreds,
 			err:   errInvalidBoardName,
 		},
 		{
 			name:  "title too long",
 			id:    "b",
 			title: GenString(101),
-			err:   errTitleTooLong,
+			creds: adminLoginCreds,
+			err:   aerrTitleTooLong,
 		},
 		{
 			name:  "board name taken",
 			id:    "a",
 			title: "foo",
+			creds: adminLoginCreds,
 			err:   errBoardNameTaken,
 		},
 	}
@@ -205,50 +125,176 @@ func TestValidateBoardCreation(t *testing.T) {
 				Title: c.title,
 			}
 			rec, req := newJSONPair(t, "/api/create-board", msg)
-			setLoginCookies(req, sampleLoginCreds)
-			router.ServeHTTP(rec, req)
+			setLoginCookies(req, c.creds)
+			testRouter.ServeHTTP(rec, req)
+
+			assertError(t, rec, 400, c.err)
+		})
+	}
+}
+
+func TestCheckBoardLimits(t *testing.T) {
+	conf := config.Get()
+	types := make([]string, len(common.Extensions)+1)
+	for i := range types {
+		types[i] = "png"
+	}
+
+	cases := [...]struct {
+		name string
+		conf config.BoardConfig
+		err  error
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "valid limits",
+			conf: config.BoardConfig{
+				MaxThreads: common.MaxThreadsLimit,
+				BoardPublic: config.BoardPublic{
+					BumpLimit:      common.MaxBumpLimit,
+					MaxFiles:       conf.MaxFiles,
+					MaxSize:        conf.MaxSize,
+					ThreadCooldown: common.MaxCooldown,
+					PostCooldown:   common.MaxCooldown,
+					AllowedTypes:   []string{"png", "tar.gz"},
+				},
+			},
+		},
+		{
+			name: "negative thread limit",
+			conf: config.BoardConfig{MaxThreads: -1},
+			err:  aerrBadThreadLimit,
+		},
+		{
+			name: "thread limit too large",
+			conf: config.BoardConfig{MaxThreads: common.MaxThreadsLimit + 1},
+			err:  aerrBadThreadLimit,
+		},
+		{
+			name: "negative bump limit",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{BumpLimit: -1},
+			},
+			err: aerrBadBumpLimit,
+		},
+		{
+			name: "bump limit too large",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{BumpLimit: common.MaxBumpLimit + 1},
+			},
+			err: aerrBadBumpLimit,
+		},
+		{
+			name: "negative max files",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{MaxFiles: -1},
+			},
+			err: aerrBadFileLimit,
+		},
+		{
+			name: "max files over server limit",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{MaxFiles: conf.MaxFiles + 1},
+			},
+			err: aerrBadFileLimit,
+		},
+		{
+			name: "negative max size",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{MaxSize: -1},
+			},
+			err: aerrBadFileLimit,
+		},
+		{
+			name: "max size over server limit",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{MaxSize: conf.MaxSize + 1},
+			},
+			err: aerrBadFileLimit,
+		},
+		{
+			name: "negative thread cooldown",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{ThreadCooldown: -1},
+			},
+			err: aerrBadCooldown,
+		},
+		{
+			name: "post cooldown too large",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{PostCooldown: common.MaxCooldown + 1},
+			},
+			err: aerrBadCooldown,
+		},
+		{
+			name: "unknown file type",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{AllowedTypes: []string{"png", "exe"}},
+			},
+			err: aerrBadFileType,
+		},
+		{
+			name: "too many file types",
+			conf: config.BoardConfig{
+				BoardPublic: config.BoardPublic{AllowedTypes: types},
+			},
+			err: aerrBadFileType,
+		},
+	}
 
-			assertCode(t, rec, 400)
-			assertBody(t, rec, fmt.Sprintf("400 %s\n", c.err))
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			if err := checkBoardLimits(c.conf); err != c.err {
+				LogUnexpected(t, c.err, err)
+			}
 		})
 	}
 }
 
 func writeSampleBoard(t testing.TB) {
-	b := db.BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
-			ID: "a",
+	writeBoard(t, "a")
+}
+
+// Write a board to the database and the board config cache
+func writeBoard(t testing.TB, id string) {
+	conf := config.BoardConfig{
+		BoardPublic: config.BoardPublic{
+			ID: id,
 		},
 	}
-	if err := db.WriteBoard(nil, b); err != nil {
+	if err := db.WriteBoard(nil, conf); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := config.SetBoardConfigs(b.BoardConfigs); err != nil {
+	if err := config.SetBoardConfig(conf); err != nil {
 		t.Fatal(err)
 	}
 }
 
 func writeSampleBoardOwner(t *testing.T) {
-	tx, err := db.StartTransaction()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.RollbackOnError(tx, &err)
+	writeBoardOwner(t, "a", sampleLoginCreds.UserID)
+}
 
-	err = db.WriteStaff(tx, "a", map[string][]string{
-		"owners": {"user1"},
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	if err := tx.Commit(); err != nil {
-		t.Fatal(err)
-	}
+func writeBoardOwner(t *testing.T, board, userID string) {
+	writeStaff(t, board, userID, auth.BoardOwner)
+}
+
+// Clear accounts and boards, then write board "a" with thread 1, owned by the
+// sample user. Shared setup of the moderation endpoint tests.
+func writeModerationFixtures(t *testing.T) {
+	assertTableClear(t, "accounts", "boards")
+	writeSampleBoard(t)
+	writeSampleThread(t)
+	writeSampleUser(t)
+	writeSampleBoardOwner(t)
 }
 
 func TestBoardCreation(t *testing.T) {
 	assertTableClear(t, "boards", "accounts")
-	writeSampleUser(t)
+	writeAdminAccount(t)
 
 	const (
 		id    = "a"
@@ -260,192 +306,59 @@ func TestBoardCreation(t *testing.T) {
 		Title: title,
 	}
 	rec, req := newJSONPair(t, "/api/create-board", msg)
-	setLoginCookies(req, sampleLoginCreds)
-	router.ServeHTTP(rec, req)
+	setLoginCookies(req, adminLoginCreds)
+	testRouter.ServeHTTP(rec, req)
 
 	assertCode(t, rec, 200)
 
-	board, err := db.GetBoardConfigs(id)
+	board, err := db.GetBoardConfig(nil, id)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	std := config.BoardConfigs{
-		ID: id,
-		BoardPublic: config.BoardPublic{
-			Title: title,
-		},
-	}
-	AssertDeepEquals(t, board, std)
-}
-
-func TestServePrivateServerConfigs(t *testing.T) {
-	assertTableClear(t, "accounts")
-	writeSampleUser(t)
-	writeAdminAccount(t)
-	if err := config.Set(config.Defaults); err != nil {
-		t.Fatal(err)
-	}
-
-	cases := [...]struct {
-		name string
-		auth.SessionCreds
-		code int
-		err  error
-	}{
-		{
-			name:         "not admin",
-			SessionCreds: sampleLoginCreds,
-			code:         403,
-			err:          errAccessDenied,
-		},
-		{
-			name:         "admin",
-			SessionCreds: adminLoginCreds,
-			code:         200,
-		},
-	}
-
-	for i := range cases {
-		c := cases[i]
-		t.Run(c.name, func(t *testing.T) {
-			t.Parallel()
-
-			rec, req := newJSONPair(t, "/api/config", nil)
-			setLoginCookies(req, c.SessionCreds)
-			router.ServeHTTP(rec, req)
-
-			assertCode(t, rec, c.code)
-			if c.err != nil {
-				assertBody(t, rec, fmt.Sprintf("%d %s\n", c.code, c.err))
-			}
-		})
-	}
+	AssertDeepEquals(t, board.ID, id)
+	AssertDeepEquals(t, board.Title, title)
 }
 
 func writeAdminAccount(t *testing.T) {
-	err := db.CreateAdminAccount()
-	if err != nil {
-		t.Fatal(err)
-	}
-	err = db.WriteLoginSession("admin", adminLoginCreds.Session)
+	hash, err := auth.BcryptHash(samplePassword, 3)
 	if err != nil {
 		t.Fatal(err)
 	}
-}
-
-func TestServerConfigSetting(t *testing.T) {
-	assertTableClear(t, "accounts")
-	if err := db.WriteConfigs(config.Defaults); err != nil {
+	if err := db.RegisterAccount("admin", hash); err != nil {
 		t.Fatal(err)
 	}
-	writeAdminAccount(t)
-
-	msg := config.Defaults
-	msg.DefaultCSS = "ashita"
-	rec, req := newJSONPair(t, "/api/configure-server", msg)
-	setLoginCookies(req, adminLoginCreds)
-	router.ServeHTTP(rec, req)
-
-	assertCode(t, rec, 200)
-
-	conf, err := db.GetConfigs()
+	err = db.WriteLoginSession("admin", adminLoginCreds.Session)
 	if err != nil {
 		t.Fatal(err)
 	}
-	std := config.Defaults
-	std.DefaultCSS = "ashita"
-	AssertDeepEquals(t, conf, std)
-}
-
-func TestDeleteBoard(t *testing.T) {
-	assertTableClear(t, "accounts", "boards")
-	writeSampleUser(t)
-	writeSampleBoard(t)
-	writeSampleBoardOwner(t)
-
-	rec, req := newJSONPair(t, "/api/delete-board", boardActionRequest{
-		Board: "a",
-	})
-	setLoginCookies(req, sampleLoginCreds)
-	router.ServeHTTP(rec, req)
-
-	assertCode(t, rec, 200)
 }
 
 func TestDeletePost(t *testing.T) {
-	assertTableClear(t, "accounts", "boards")
-	writeSampleBoard(t)
-	writeSampleThread(t)
-	writeSampleUser(t)
-	writeSampleBoardOwner(t)
-
-	cConfigs := db.BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
-			ID: "c",
-		},
-	}
-	err := db.WriteBoard(nil, cConfigs)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if _, err := config.SetBoardConfigs(cConfigs.BoardConfigs); err != nil {
-		t.Fatal(err)
-	}
-
-	thread := db.Thread{
-		ID:    3,
-		Board: "c",
-	}
-	op := db.Post{
+	writeModerationFixtures(t)
+	writeBoard(t, "c")
+	writeThread(t, db.Post{
 		StandalonePost: common.StandalonePost{
 			Board: "c",
 			Post: common.Post{
-				ID: 3,
+				ID:   3,
+				Time: 345351,
 			},
 			OP: 3,
 		},
-	}
-	if err := db.WriteThread(nil, thread, op); err != nil {
-		t.Fatal(err)
-	}
-
-	posts := [...]db.Post{
-		{
-			StandalonePost: common.StandalonePost{
-				Board: "a",
-				Post: common.Post{
-					ID: 2,
-				},
-				OP: 1,
-			},
-		},
-		{
-			StandalonePost: common.StandalonePost{
-				Board: "a",
-				Post: common.Post{
-					ID: 4,
-				},
-				OP: 1,
-			},
-		},
-	}
-	for _, p := range posts {
-		if err := db.WritePost(nil, p); err != nil {
-			t.Fatal(err)
-		}
-	}
+	})
+	writeSampleReply(t, 2, "::1")
+	writeSampleReply(t, 4, "::1")
 
 	data := []uint64{2, 4}
 	const url = "/api/delete-post"
 	rec, req := newJSONPair(t, url, data)
 	setLoginCookies(req, sampleLoginCreds)
-	router.ServeHTTP(rec, req)
+	testRouter.ServeHTTP(rec, req)
 	assertCode(t, rec, 200)
 
 	data = []uint64{3}
 	rec, req = newJSONPair(t, url, data)
-	router.ServeHTTP(rec, req)
+	testRouter.ServeHTTP(rec, req)
 	assertCode(t, rec, 403)
 
 	cases := [...]struct {
@@ -474,14 +387,250 @@ func TestDeletePost(t *testing.T) {
 	}
 }
 
-func writeSampleThread(t *testing.T) {
-	thread := db.Thread{
-		ID:        1,
-		Board:     "a",
-		ReplyTime: 11,
+func TestDeleteThread(t *testing.T) {
+	writeModerationFixtures(t)
+	writeSampleReply(t, 2, "::1")
+
+	rec, req := newJSONPair(t, "/api/delete-post", []uint64{1})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	// Hidden from the public, but kept for moderators
+	if _, err := db.GetThread(1, 0); err != sql.ErrNoRows {
+		UnexpectedError(t, err)
 	}
-	op := db.Post{
-		StandalonePost: common.StandalonePost{
+	valid, err := db.ValidateOP(1, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("deleted thread accepts posts")
+	}
+	thread, err := db.GetThreadWithDeleted(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !thread.Deleted || len(thread.Posts) != 1 {
+		t.Fatal("deleted thread not visible to moderators")
+	}
+
+	// Restoring the OP restores the thread
+	rec, req = newJSONPair(t, "/api/undelete-post", []uint64{1})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	thread, err = db.GetThread(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thread.Deleted || len(thread.Posts) != 1 {
+		t.Fatal("thread not restored")
+	}
+}
+
+func TestUndeletePost(t *testing.T) {
+	writeModerationFixtures(t)
+	writeSampleReply(t, 2, "::1")
+	if err := db.DeletePost(2, sampleLoginCreds.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	thread, err := db.GetThread(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(thread.Posts), 0)
+	thread, err = db.GetThreadWithDeleted(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread.Posts) != 1 || !thread.Posts[0].Deleted {
+		t.Fatal("deleted post not visible to moderators")
+	}
+
+	const url = "/api/undelete-post"
+	rec, req := newJSONPair(t, url, []uint64{2})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	rec, req = newJSONPair(t, url, []uint64{2})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	p, err := db.GetPost(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Deleted {
+		t.Fatal("post not restored")
+	}
+
+	// Not deleted anymore
+	rec, req = newJSONPair(t, url, []uint64{2})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 400)
+}
+
+// Sample image record. Empty lists as they are read back from the DB.
+var sampleImage = common.ImageCommon{
+	SHA1:      "012a2f912c9ee93ceb0ccb8684a29ec571990a94",
+	MD5:       "YOQQklgfezKbBXuEAsqopw",
+	Size:      300792,
+	FileType:  common.JPEG,
+	ThumbType: common.JPEG,
+	Dims:      [4]uint16{1, 1, 1, 1},
+	Variants:  []common.ThumbVariant{},
+	Contents:  []common.ArchiveEntry{},
+}
+
+// Write thread 1 on board "a" with replies 2 and 3, of which only reply 2
+// has a file. The sample user is a janitor on board "c".
+func writeImageModerationFixtures(t *testing.T) {
+	assertTableClear(t, "accounts", "boards", "images")
+	writeSampleBoard(t)
+	writeBoard(t, "c")
+	writeSampleThread(t)
+	writeSampleUser(t)
+	writeStaff(t, "c", sampleLoginCreds.UserID, auth.Janitor)
+	if err := db.WriteImage(nil, sampleImage); err != nil {
+		t.Fatal(err)
+	}
+	writePost(t, db.Post{
+		StandalonePost: common.StandalonePost{
+			Board: "a",
+			Post: common.Post{
+				ID:    2,
+				Time:  345352,
+				Files: common.Files{{ImageCommon: sampleImage}},
+			},
+			OP: 1,
+		},
+		IP: "::1",
+	})
+	writeSampleReply(t, 3, "::1")
+}
+
+// Assert anonymous users and staff of other boards can't moderate images of
+// post 2, then make the sample user a janitor on board "a"
+func assertJanitorOnly(t *testing.T, url string) {
+	rec, req := newJSONPair(t, url, []uint64{2})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	rec, req = newJSONPair(t, url, []uint64{2})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	writeStaff(t, "a", sampleLoginCreds.UserID, auth.Janitor)
+}
+
+func writeStaff(t *testing.T, board, userID string, pos auth.ModerationLevel) {
+	inTx(t, func(tx *sql.Tx) error {
+		return db.WriteStaff(tx, board, auth.Staff{
+			{
+				Board:    board,
+				UserID:   userID,
+				Position: pos,
+			},
+		})
+	})
+}
+
+func assertImageCtr(t *testing.T, std uint32) {
+	thread, err := db.GetThread(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, thread.ImageCtr, std)
+}
+
+func assertModLog(t *testing.T, types ...auth.ModerationAction) {
+	log, err := db.GetModLog([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != len(types) {
+		t.Fatalf("unexpected mod log length: %d", len(log))
+	}
+	for i, typ := range types {
+		AssertDeepEquals(t, log[i].Type, typ)
+		AssertDeepEquals(t, log[i].By, sampleLoginCreds.UserID)
+		AssertDeepEquals(t, log[i].ID, uint64(2))
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	writeImageModerationFixtures(t)
+	assertImageCtr(t, 1)
+
+	const url = "/api/delete-image"
+	assertJanitorOnly(t, url)
+
+	rec, req := newJSONPair(t, url, []uint64{2})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	post, err := db.GetPost(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, len(post.Files), 0)
+	assertImageCtr(t, 0)
+	assertModLog(t, auth.DeleteImage)
+
+	// No files left and none in the first place
+	for _, id := range [...]uint64{2, 3} {
+		rec, req = newJSONPair(t, url, []uint64{id})
+		setLoginCookies(req, sampleLoginCreds)
+		testRouter.ServeHTTP(rec, req)
+		assertCode(t, rec, 400)
+		assertBody(t, rec, "400 "+db.ErrNoImage.Error()+"\n")
+	}
+	assertImageCtr(t, 0)
+	assertModLog(t, auth.DeleteImage)
+}
+
+func TestSpoilerImage(t *testing.T) {
+	writeImageModerationFixtures(t)
+
+	const url = "/api/spoiler-image"
+	assertJanitorOnly(t, url)
+
+	rec, req := newJSONPair(t, url, []uint64{2})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	post, err := db.GetPost(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(post.Files) != 1 || !post.Files[0].Spoiler {
+		t.Fatal("image not spoilered")
+	}
+	assertImageCtr(t, 1)
+	assertModLog(t, auth.SpoilerImage)
+
+	// Already spoilered and no files
+	for _, id := range [...]uint64{2, 3} {
+		rec, req = newJSONPair(t, url, []uint64{id})
+		setLoginCookies(req, sampleLoginCreds)
+		testRouter.ServeHTTP(rec, req)
+		assertCode(t, rec, 400)
+		assertBody(t, rec, "400 "+db.ErrNoImage.Error()+"\n")
+	}
+	assertModLog(t, auth.SpoilerImage)
+}
+
+func writeSampleThread(t *testing.T) {
+	writeThread(t, db.Post{
+		StandalonePost: common.StandalonePost{
 			Post: common.Post{
 				ID:   1,
 				Time: 345351,
@@ -489,8 +638,415 @@ func writeSampleThread(t *testing.T) {
 			OP:    1,
 			Board: "a",
 		},
+	})
+}
+
+// Write a thread with the passed OP
+func writeThread(t *testing.T, op db.Post) {
+	inTx(t, func(tx *sql.Tx) error {
+		return db.InsertThread(tx, op, "")
+	})
+}
+
+// Write a reply into an existing thread
+func writePost(t *testing.T, p db.Post) {
+	inTx(t, func(tx *sql.Tx) error {
+		return db.InsertPost(tx, p)
+	})
+}
+
+// Write a closed reply with the passed ID and IP into thread 1 on board "a"
+func writeSampleReply(t *testing.T, id uint64, ip string) {
+	writePost(t, db.Post{
+		StandalonePost: common.StandalonePost{
+			Board: "a",
+			Post: common.Post{
+				ID:   id,
+				Time: 345352,
+			},
+			OP: 1,
+		},
+		IP: ip,
+	})
+}
+
+func inTx(t *testing.T, fn func(tx *sql.Tx) error) {
+	tx, err := db.BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fn(tx)
+	db.EndTx(tx, &err)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetThreadSticky(t *testing.T) {
+	writeModerationFixtures(t)
+	writeSampleReply(t, 2, "::1")
+
+	type request struct {
+		ID     uint64
+		Sticky bool
+	}
+	const url = "/api/sticky"
+
+	rec, req := newJSONPair(t, url, request{1, true})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	rec, req = newJSONPair(t, url, request{2, true})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 400)
+
+	rec, req = newJSONPair(t, url, request{1, true})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	thread, err := db.GetThread(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !thread.Sticky {
+		t.Fatal("thread not sticky")
+	}
+
+	log, err := db.GetModLog([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 {
+		t.Fatalf("unexpected mod log length: %d", len(log))
+	}
+	AssertDeepEquals(t, log[0].Type, auth.StickyThread)
+	AssertDeepEquals(t, log[0].By, sampleLoginCreds.UserID)
+}
+
+func TestSetThreadLocked(t *testing.T) {
+	writeModerationFixtures(t)
+
+	type request struct {
+		ID     uint64
+		Locked bool
+	}
+	const url = "/api/lock"
+
+	rec, req := newJSONPair(t, url, request{1, true})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	rec, req = newJSONPair(t, url, request{1, true})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	thread, err := db.GetThread(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !thread.Locked {
+		t.Fatal("thread not locked")
+	}
+
+	log, err := db.GetModLog([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 {
+		t.Fatalf("unexpected mod log length: %d", len(log))
+	}
+	AssertDeepEquals(t, log[0].Type, auth.LockThread)
+	AssertDeepEquals(t, log[0].By, sampleLoginCreds.UserID)
+}
+
+func TestMoveThread(t *testing.T) {
+	writeModerationFixtures(t)
+	writeBoard(t, "c")
+
+	type request struct {
+		ID    uint64
+		Board string
+	}
+	const url = "/api/move"
+
+	// Not staff of the target board
+	rec, req := newJSONPair(t, url, request{1, "c"})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	rec, req = newJSONPair(t, url, request{1, "a"})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 400)
+
+	writeBoardOwner(t, "c", sampleLoginCreds.UserID)
+
+	rec, req = newJSONPair(t, url, request{1, "c"})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	thread, err := db.GetThread(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, thread.Board, "c")
+	board, err := db.GetPostBoard(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, board, "c")
+
+	log, err := db.GetModLog([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 {
+		t.Fatalf("unexpected mod log length: %d", len(log))
+	}
+	AssertDeepEquals(t, log[0].Type, auth.MoveThread)
+}
+
+func TestReports(t *testing.T) {
+	writeModerationFixtures(t)
+
+	type request struct {
+		ID     uint64
+		Reason string
+	}
+
+	rec, req := newJSONPair(t, "/api/report", request{1, ""})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 400)
+
+	rec, req = newJSONPair(t, "/api/report", request{2, "spam"})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 404)
+
+	rec, req = newJSONPair(t, "/api/report", request{1, "spam"})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	reports, err := db.GetReports([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("unexpected report count: %d", len(reports))
+	}
+	AssertDeepEquals(t, reports[0].Post, uint64(1))
+	AssertDeepEquals(t, reports[0].Reason, "spam")
+
+	type resolveRequest struct {
+		ID uint64
+	}
+	rec, req = newJSONPair(t, "/api/dismiss-report", resolveRequest{reports[0].ID})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	rec, req = newJSONPair(t, "/api/dismiss-report", resolveRequest{reports[0].ID})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	reports, err = db.GetReports([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 0 {
+		t.Fatalf("unexpected report count: %d", len(reports))
+	}
+
+	log, err := db.GetModLog([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 {
+		t.Fatalf("unexpected mod log length: %d", len(log))
+	}
+	AssertDeepEquals(t, log[0].Type, auth.DismissReport)
+}
+
+func TestHashIP(t *testing.T) {
+	t.Parallel()
+
+	const ip = "192.0.2.1"
+	hash := hashIP(ip)
+	if len(hash) != 64 {
+		t.Fatalf("unexpected hash length: %d", len(hash))
+	}
+	AssertDeepEquals(t, hashIP(ip), hash)
+
+	// Must not be a plain hash, that can be reversed by brute force
+	sum := sha256.Sum256([]byte(ip))
+	if hash == hex.EncodeToString(sum[:]) {
+		t.Fatal("IP hash not keyed")
+	}
+}
+
+func TestAppeals(t *testing.T) {
+	writeModerationFixtures(t)
+	writeSampleReply(t, 2, "192.0.2.1")
+
+	type request struct {
+		Board string
+		Body  string
+	}
+
+	// Nothing to appeal yet
+	rec, req := newJSONPair(t, "/api/appeal", request{"a", "sorry"})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 400)
+
+	_, err := db.Ban(
+		"a", "spam", sampleLoginCreds.UserID,
+		time.Now().Add(time.Hour), auth.BanPrefixes{}, 2,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, req = newJSONPair(t, "/api/appeal", request{"a", ""})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 400)
+
+	rec, req = newJSONPair(t, "/api/appeal", request{"a", "sorry"})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	// Only one appeal per ban
+	rec, req = newJSONPair(t, "/api/appeal", request{"a", "sorry"})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 400)
+
+	appeals, err := db.GetAppeals([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(appeals) != 1 {
+		t.Fatalf("unexpected appeal count: %d", len(appeals))
+	}
+	AssertDeepEquals(t, appeals[0].Post, uint64(2))
+	AssertDeepEquals(t, appeals[0].Reason, "spam")
+	AssertDeepEquals(t, appeals[0].Body, "sorry")
+
+	type resolveRequest struct {
+		ID uint64
+	}
+	rec, req = newJSONPair(t, "/api/accept-appeal", resolveRequest{appeals[0].ID})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	rec, req = newJSONPair(t, "/api/accept-appeal", resolveRequest{appeals[0].ID})
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	if _, err := db.GetBanInfo("192.0.2.1", "a"); err != sql.ErrNoRows {
+		t.Fatalf("ban not lifted: %v", err)
+	}
+	appeals, err = db.GetAppeals([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(appeals) != 0 {
+		t.Fatalf("unexpected appeal count: %d", len(appeals))
+	}
+
+	log, err := db.GetModLog([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
 	}
-	if err := db.WriteThread(nil, thread, op); err != nil {
+	// Ban, appeal acceptance and unban
+	if len(log) != 3 {
+		t.Fatalf("unexpected mod log length: %d", len(log))
+	}
+	types := make(map[auth.ModerationAction]bool, len(log))
+	for _, l := range log {
+		types[l.Type] = true
+	}
+	if !types[auth.AcceptAppeal] || !types[auth.UnbanPost] {
+		t.Fatalf("unexpected mod log: %v", log)
+	}
+}
+
+func TestGetSameIPPosts(t *testing.T) {
+	writeModerationFixtures(t)
+	writeSampleReply(t, 2, "::1")
+	writeSampleReply(t, 3, "::1")
+	writeSampleReply(t, 4, "::2")
+
+	rec, req := newPair("/api/same-ip/2")
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+
+	rec, req = newPair("/api/same-ip/2")
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 200)
+
+	var res []common.StandalonePost
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
 		t.Fatal(err)
 	}
+	ids := make([]int, len(res))
+	for i, p := range res {
+		ids[i] = int(p.ID)
+	}
+	sort.Ints(ids)
+	AssertDeepEquals(t, ids, []int{2, 3})
+}
+
+func TestSendNotification(t *testing.T) {
+	assertTableClear(t, "accounts")
+	writeSampleUser(t)
+	writeAdminAccount(t)
+
+	cases := [...]struct {
+		name  string
+		creds sessionCreds
+		text  string
+		code  int
+	}{
+		{
+			name:  "not admin",
+			creds: sampleLoginCreds,
+			text:  "foo",
+			code:  403,
+		},
+		{
+			name:  "admin",
+			creds: adminLoginCreds,
+			text:  "foo",
+			code:  200,
+		},
+		{
+			name:  "empty",
+			creds: adminLoginCreds,
+			text:  " \n",
+			code:  400,
+		},
+		{
+			name:  "too long",
+			creds: adminLoginCreds,
+			text:  strings.Repeat("a", common.MaxLenNotification+1),
+			code:  400,
+		},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec, req := newJSONPair(t, "/api/notification", c.text)
+			setLoginCookies(req, c.creds)
+			testRouter.ServeHTTP(rec, req)
+			assertCode(t, rec, c.code)
+		})
+	}
 }
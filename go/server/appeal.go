@@ -0,0 +1,149 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/lang"
+	"github.com/cutechan/cutechan/go/templates"
+)
+
+// Find ban of the IP on the board, global bans included.
+func getBanInfo(ip, board string) (ban auth.BanRecord, err error) {
+	ban, err = db.GetBanInfo(ip, board)
+	if err == sql.ErrNoRows && board != "all" {
+		ban, err = db.GetBanInfo(ip, "all")
+	}
+	return
+}
+
+// Render ban details and appeal form for the client's IP
+func bannedHTML(w http.ResponseWriter, r *http.Request) {
+	b := getParam(r, "board")
+	if !assertBoard(w, r, b) {
+		return
+	}
+	ip, err := auth.GetIP(r)
+	if err != nil {
+		text400(w, err)
+		return
+	}
+
+	var banned, appealed, rejected bool
+	ban, err := getBanInfo(ip, b)
+	switch err {
+	case nil:
+		banned = true
+		appealed, rejected, err = db.GetAppealState(ban.IP, ban.Board)
+		if err != nil {
+			text500(w, r, err)
+			return
+		}
+	case sql.ErrNoRows:
+	default:
+		text500(w, r, err)
+		return
+	}
+
+	ss, _ := getSession(r, b)
+	l := lang.FromReq(r)
+	html := templates.Banned(
+		templates.Params{r, ss, l},
+		b, ban, banned, appealed, rejected,
+	)
+	serveHTML(w, r, html)
+}
+
+// Appeal a ban. Only one appeal per ban is allowed.
+func createAppeal(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		Board string
+		Body  string
+	}
+	if !decodeJSON(w, r, &msg) {
+		return
+	}
+	if !assertBoardAPI(w, msg.Board) {
+		return
+	}
+	msg.Body = strings.TrimSpace(msg.Body)
+	if msg.Body == "" || len(msg.Body) > common.MaxLenAppeal {
+		serveErrorJSON(w, r, aerrInvalidAppeal)
+		return
+	}
+	ip, err := auth.GetIP(r)
+	if err != nil {
+		text400(w, err)
+		return
+	}
+
+	ban, err := getBanInfo(ip, msg.Board)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNotBanned)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+
+	switch err := db.WriteAppeal(ip, ban.Board, msg.Body); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrAppealed)
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	}
+}
+
+// Lift the appealed ban
+func acceptAppeal(w http.ResponseWriter, r *http.Request) {
+	resolveAppeal(w, r, db.AcceptAppeal)
+}
+
+// Keep the appealed ban
+func rejectAppeal(w http.ResponseWriter, r *http.Request) {
+	resolveAppeal(w, r, db.RejectAppeal)
+}
+
+func resolveAppeal(
+	w http.ResponseWriter,
+	r *http.Request,
+	fn func(id uint64, by string) error,
+) {
+	var msg struct {
+		ID uint64
+	}
+	if !decodeJSON(w, r, &msg) {
+		return
+	}
+	board, _, err := db.GetAppeal(msg.ID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		text400(w, err)
+		return
+	default:
+		text500(w, r, err)
+		return
+	}
+	ss, ok := assertCanPerform(w, r, board, auth.BoardOwner)
+	if !ok {
+		return
+	}
+
+	switch err := fn(msg.ID, ss.UserID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		text400(w, err)
+	default:
+		text500(w, r, err)
+	}
+}
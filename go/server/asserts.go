@@ -17,7 +17,8 @@ func assertNotBannedAPI(w http.ResponseWriter, r *http.Request, board string) (i
 		return
 	}
 	if auth.IsBanned(board, ip) {
-		text403(w, errBanned)
+		// Point to the page with ban details and appeal form
+		text403(w, fmt.Errorf("%s, see /%s/banned", errBanned, board))
 		return
 	}
 	ok = true
@@ -69,6 +70,11 @@ func checkModOnly(board string, ss *auth.Session) bool {
 	return ss.Positions.CurBoard >= auth.Moderator
 }
 
+// Only mods and above can see deleted posts.
+func canSeeDeleted(ss *auth.Session) bool {
+	return ss != nil && ss.Positions.CurBoard >= auth.Moderator
+}
+
 // Eunsure only mods and above can view mod-only boards.
 func assertNotModOnly(w http.ResponseWriter, r *http.Request, board string, ss *auth.Session) bool {
 	if !checkModOnly(board, ss) {
@@ -139,6 +145,18 @@ func assertBoardOwner(h AdminBoardHandler) http.HandlerFunc {
 	}
 }
 
+// Admin page is also available to other staff for the report queue.
+func assertPowerUser(h AdminBoardHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ss, _ := getSession(r, "")
+		if !checkPowerUser(ss) {
+			text403(w, aerrPowerUserOnly)
+			return
+		}
+		h(w, r, ss, "")
+	}
+}
+
 type AdminBoardAPIHandler func(r *http.Request, ss *auth.Session, board string) error
 
 func assertBoardOwnerAPI(h AdminBoardAPIHandler) http.HandlerFunc {
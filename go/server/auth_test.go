@@ -14,7 +14,12 @@ import (
 
 const samplePassword = "123456"
 
-var sampleLoginCreds = auth.SessionCreds{
+// Account ID and login session token of a test user
+type sessionCreds struct {
+	UserID, Session string
+}
+
+var sampleLoginCreds = sessionCreds{
 	UserID:  "user1",
 	Session: genSession(),
 }
@@ -41,7 +46,7 @@ func genSession() string {
 	return GenString(common.LenSession)
 }
 
-func TestIsLoggedIn(t *testing.T) {
+func TestGetSession(t *testing.T) {
 	assertTableClear(t, "accounts")
 
 	hash, err := auth.BcryptHash(samplePassword, 3)
@@ -74,37 +79,31 @@ func TestIsLoggedIn(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
 
-			rec, req := newPair("/")
-			setLoginCookies(req, auth.SessionCreds{
+			req := newRequest("/")
+			setLoginCookies(req, sessionCreds{
 				UserID:  c.user,
 				Session: c.session,
 			})
-			if _, isValid := isLoggedIn(rec, req); isValid != c.isValid {
-				LogUnexpected(t, c.isValid, isValid)
-			}
-			if !c.isValid {
-				assertError(t, rec, 403, errAccessDenied)
+			ss, err := getSession(req, "")
+			switch {
+			case c.isValid && err != nil:
+				t.Fatal(err)
+			case c.isValid:
+				AssertDeepEquals(t, ss.UserID, c.user)
+			case err != common.ErrInvalidCreds:
+				UnexpectedError(t, err)
 			}
 		})
 	}
 }
 
-func setLoginCookies(r *http.Request, creds auth.SessionCreds) {
-	expires := time.Now().Add(time.Hour)
-	loginCookie := http.Cookie{
-		Name:    "loginID",
-		Value:   creds.UserID,
-		Path:    "/",
-		Expires: expires,
-	}
-	sessionCookie := http.Cookie{
+func setLoginCookies(r *http.Request, creds sessionCreds) {
+	r.AddCookie(&http.Cookie{
 		Name:    "session",
 		Value:   creds.Session,
 		Path:    "/",
-		Expires: expires,
-	}
-	r.AddCookie(&loginCookie)
-	r.AddCookie(&sessionCookie)
+		Expires: time.Now().Add(time.Hour),
+	})
 }
 
 func assertError(
@@ -120,20 +119,21 @@ func assertError(
 }
 
 func TestNotLoggedIn(t *testing.T) {
-	assertTableClear(t, "accounts", "boards")
-	writeSampleBoard(t)
+	assertTableClear(t, "accounts")
 
-	fns := [...]http.HandlerFunc{servePrivateServerConfigs, changePassword}
-	for i := range fns {
-		fn := fns[i]
-		t.Run("", func(t *testing.T) {
+	urls := [...]string{
+		"/api/change-password",
+		"/api/logout",
+		"/api/logout/all",
+	}
+	for i := range urls {
+		url := urls[i]
+		t.Run(url, func(t *testing.T) {
 			t.Parallel()
 
-			rec, req := newJSONPair(t, "/", boardActionRequest{
-				Board: "a",
-			})
-			fn(rec, req)
-			assertError(t, rec, 403, errAccessDenied)
+			rec, req := newJSONPair(t, url, passwordChangeRequest{})
+			testRouter.ServeHTTP(rec, req)
+			assertError(t, rec, 403, common.ErrInvalidCreds)
 		})
 	}
 }
@@ -188,7 +188,7 @@ func TestChangePassword(t *testing.T) {
 			rec, req := newJSONPair(t, "/api/change-password", msg)
 			setLoginCookies(req, sampleLoginCreds)
 
-			router.ServeHTTP(rec, req)
+			testRouter.ServeHTTP(rec, req)
 
 			assertError(t, rec, c.code, c.err)
 		})
@@ -217,14 +217,14 @@ func TestRegistrationValidations(t *testing.T) {
 			id:       "",
 			password: "123456",
 			code:     400,
-			err:      errInvalidUserID,
+			err:      aerrInvalidUserID,
 		},
 		{
 			name:     "id too long",
 			id:       GenString(common.MaxLenUserID + 1),
 			password: "123456",
 			code:     400,
-			err:      errInvalidUserID,
+			err:      aerrInvalidUserID,
 		},
 		{
 			name:     "no password",
@@ -262,7 +262,7 @@ func TestRegistrationValidations(t *testing.T) {
 				ID:       c.id,
 				Password: c.password,
 			})
-			router.ServeHTTP(rec, req)
+			testRouter.ServeHTTP(rec, req)
 
 			assertError(t, rec, c.code, c.err)
 			if c.err == nil {
@@ -272,31 +272,30 @@ func TestRegistrationValidations(t *testing.T) {
 	}
 }
 
+// Assert the session cookie set in the response is a valid login session
 func assertLogin(t *testing.T, rec *httptest.ResponseRecorder, loggedIn bool) {
-	// Extract cookies from recorder
-	req := http.Request{
-		Header: http.Header{
-			"Cookie": rec.HeaderMap["Set-Cookie"],
-		},
-	}
-	var userID, session string
-	if c, err := req.Cookie("loginID"); err == nil {
-		userID = c.Value
-	}
-	if c, err := req.Cookie("session"); err == nil {
-		session = c.Value
+	var session string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "session" {
+			session = c.Value
+		}
 	}
-
-	assertLoginNoCookie(t, userID, session, loggedIn)
+	assertLoggedIn(t, session, loggedIn)
 }
 
-func assertLoginNoCookie(t *testing.T, userID, session string, loggedIn bool) {
-	is, err := db.IsLoggedIn(userID, session)
+func assertLoggedIn(t *testing.T, session string, loggedIn bool) {
+	_, err := db.GetSession("", session)
 	switch {
-	case err != nil:
+	case err == nil:
+		if !loggedIn {
+			t.Fatal("session not logged out")
+		}
+	case err == common.ErrInvalidCreds:
+		if loggedIn {
+			t.Fatal("session not logged in")
+		}
+	default:
 		t.Fatal(err)
-	case is != loggedIn:
-		t.Fatalf("unexpected session status: %t", is)
 	}
 }
 
@@ -351,7 +350,7 @@ func TestLogin(t *testing.T) {
 				ID:       c.id,
 				Password: c.password,
 			})
-			router.ServeHTTP(rec, req)
+			testRouter.ServeHTTP(rec, req)
 
 			assertError(t, rec, c.code, c.err)
 			if c.err == nil {
@@ -374,7 +373,7 @@ func TestLogout(t *testing.T) {
 			name:  "not logged in",
 			token: genSession(),
 			code:  403,
-			err:   errAccessDenied,
+			err:   common.ErrInvalidCreds,
 		},
 		{
 			name:  "valid",
@@ -389,17 +388,17 @@ func TestLogout(t *testing.T) {
 			t.Parallel()
 
 			rec, req := newJSONPair(t, "/api/logout", nil)
-			setLoginCookies(req, auth.SessionCreds{
+			setLoginCookies(req, sessionCreds{
 				UserID:  id,
 				Session: c.token,
 			})
-			router.ServeHTTP(rec, req)
+			testRouter.ServeHTTP(rec, req)
 
 			assertError(t, rec, c.code, c.err)
 
 			if c.err == nil {
-				assertLoginNoCookie(t, id, tokens[0], false)
-				assertLoginNoCookie(t, id, tokens[1], true)
+				assertLoggedIn(t, tokens[0], false)
+				assertLoggedIn(t, tokens[1], true)
 			}
 		})
 	}
@@ -429,15 +428,15 @@ func TestLogoutAll(t *testing.T) {
 	assertTableClear(t, "accounts")
 	id, tokens := writeSampleSessions(t)
 
-	rec, req := newJSONPair(t, "/api/logout-all", nil)
-	setLoginCookies(req, auth.SessionCreds{
+	rec, req := newJSONPair(t, "/api/logout/all", nil)
+	setLoginCookies(req, sessionCreds{
 		UserID:  id,
 		Session: tokens[0],
 	})
-	router.ServeHTTP(rec, req)
+	testRouter.ServeHTTP(rec, req)
 
 	assertCode(t, rec, 200)
 	for _, tok := range tokens {
-		assertLoginNoCookie(t, id, tok, false)
+		assertLoggedIn(t, tok, false)
 	}
 }
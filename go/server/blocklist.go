@@ -0,0 +1,100 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
+	"github.com/cutechan/cutechan/go/db"
+)
+
+// Uploads differing from a blocked image in less bits of perceptual hash
+// are rejected.
+func phashThreshold() int {
+	if n := config.Get().PHashThreshold; n > 0 {
+		return n
+	}
+	return common.DefaultPHashThreshold
+}
+
+// Assert user is a moderator of at least one board.
+func assertAnyModerator(w http.ResponseWriter, r *http.Request) bool {
+	ss, _ := getSession(r, "")
+	if ss == nil || (ss.UserID != "admin" && ss.Positions.AnyBoard < auth.Moderator) {
+		serveErrorJSON(w, r, aerrModsOnly)
+		return false
+	}
+	return true
+}
+
+// Add files of a post to the perceptual hash blocklist, so reposts of the
+// same images are rejected. Allowed to the admin and moderators of /all/.
+func blockPostFiles(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID uint64
+	}
+	if !decodeJSON(w, r, &msg) {
+		return
+	}
+	// Blocklist affects all boards, so only global staff can add to it.
+	ss, _ := getSession(r, "all")
+	if ss == nil || ss.Positions.CurBoard < auth.Moderator {
+		serveErrorJSON(w, r, aerrGlobalModsOnly)
+		return
+	}
+
+	post, err := db.GetPost(msg.ID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoPost)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	n, err := db.BlockImages(post.Board, msg.ID, ss.UserID, post.Files)
+	switch {
+	case err != nil:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+	case n == 0:
+		serveErrorJSON(w, r, aerrNothingToBlock)
+	default:
+		serveEmptyJSON(w, r)
+	}
+}
+
+// Serve all blocklist entries.
+func serveBlocklist(w http.ResponseWriter, r *http.Request) {
+	if !assertAnyModerator(w, r) {
+		return
+	}
+	entries, err := db.GetBlocklist()
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, entries)
+}
+
+// Remove hash from the blocklist. Affects all boards, so only allowed to
+// the admin.
+func unblockImage(w http.ResponseWriter, r *http.Request) {
+	ss, _ := getSession(r, "")
+	if ss == nil || ss.UserID != "admin" {
+		serveErrorJSON(w, r, aerrAdminOnly)
+		return
+	}
+	phash, err := db.ParsePHash(getParam(r, "phash"))
+	if err != nil {
+		serveErrorJSON(w, r, aerrBadPHash)
+		return
+	}
+	if err := db.UnblockImage(phash); err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveEmptyJSON(w, r)
+}
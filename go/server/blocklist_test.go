@@ -0,0 +1,26 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestBlockPostFilesPermissions(t *testing.T) {
+	writeModerationFixtures(t)
+	writeAdminAccount(t)
+
+	msg := map[string]uint64{"id": 1}
+
+	// Board staff can't affect other boards
+	rec, req := newJSONPair(t, "/api/blocklist", msg)
+	setLoginCookies(req, sampleLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 403)
+	assertBody(t, rec, string(marshalJSON(t, aerrGlobalModsOnly)))
+
+	// Allowed, but the sample thread has no images
+	rec, req = newJSONPair(t, "/api/blocklist", msg)
+	setLoginCookies(req, adminLoginCreds)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 400)
+	assertBody(t, rec, string(marshalJSON(t, aerrNothingToBlock)))
+}
@@ -17,6 +17,9 @@ var threadCache = cache.FrontEnd{
 	},
 
 	GetFresh: func(k cache.Key) (interface{}, error) {
+		if k.Deleted {
+			return db.GetThreadWithDeleted(k.ID, k.LastN)
+		}
 		return db.GetThread(k.ID, k.LastN)
 	},
 
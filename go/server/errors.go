@@ -77,6 +77,7 @@ var (
 	aerrNoIdol          = aerrorNew(404, "no such idol")
 	aerrTooLarge        = aerrorNew(400, "file too large")
 	aerrTooManyFiles    = aerrorNew(400, "too many files")
+	aerrForbiddenType   = aerrorNew(400, "file type not allowed")
 	aerrUploadRead      = aerrorNew(400, "error reading upload")
 	aerrCorrupted       = aerrorNew(400, "corrupted file")
 	aerrNameTaken       = aerrorNew(400, "name already taken")
@@ -86,14 +87,46 @@ var (
 	aerrInvalidState    = aerrorNew(400, "wrong board state")
 	aerrUnsyncState     = aerrorNew(400, "unsync board state")
 	aerrTitleTooLong    = aerrorNew(400, "board title too long")
+	aerrBadThreadLimit  = aerrorNew(400, "invalid thread limit")
+	aerrBadBumpLimit    = aerrorNew(400, "invalid bump limit")
+	aerrBadFileLimit    = aerrorNew(400, "invalid file limit")
+	aerrBadCooldown     = aerrorNew(400, "invalid cooldown")
+	aerrBadFileType     = aerrorNew(400, "invalid file type")
 	aerrInvalidReason   = aerrorNew(400, "invalid ban reason")
 	aerrInvalidPosition = aerrorNew(400, "invalid position")
 	aerrTooManyStaff    = aerrorNew(400, "too many staff")
 	aerrTooManyBans     = aerrorNew(400, "too many bans")
 	aerrNoEmbedPreview  = aerrorNew(404, "can't find embed preview")
+	aerrNotLoggedIn     = aerrorNew(403, "not logged in")
+	aerrNoThread        = aerrorNew(404, "no such thread")
+	aerrQueryTooLong    = aerrorNew(400, "search query too long")
+	aerrPageOverflow    = aerrorNew(404, "page not found")
+	aerrUploadsBusy     = aerrorNew(503, "too many uploads, try again later")
+	aerrBlockedImage    = aerrorNew(400, "image is blocked")
+	aerrNothingToBlock  = aerrorNew(400, "post has no images to block")
+	aerrBadPHash        = aerrorNew(400, "malformed image hash")
+	aerrModsOnly        = aerrorNew(403, "only for moderators")
+	aerrAdminOnly       = aerrorNew(403, "only for admin")
+	aerrGlobalModsOnly  = aerrorNew(403, "only for global moderators")
+	aerrBadFileHash     = aerrorNew(400, "malformed file hash")
+	aerrTusVersion      = aerrorNew(412, "unsupported tus version")
+	aerrNoUpload        = aerrorNew(404, "no such upload")
+	aerrUploadBusy      = aerrorNew(409, "upload is in progress")
+	aerrBadUploadType   = aerrorNew(415, "invalid upload content type")
+	aerrBadUploadOffset = aerrorNew(409, "upload offset mismatch")
+	aerrBadUploadLength = aerrorNew(400, "invalid upload length")
+	aerrTooManyUploads  = aerrorNew(429, "too many unfinished uploads")
+	aerrNoPost          = aerrorNew(404, "no such post")
+	aerrInvalidReport   = aerrorNew(400, "invalid report reason")
+	aerrTooManyReports  = aerrorNew(429, "too many reports, try again later")
+	aerrNotBanned       = aerrorNew(400, "you are not banned")
+	aerrInvalidAppeal   = aerrorNew(400, "invalid appeal")
+	aerrAppealed        = aerrorNew(400, "ban already appealed")
+	aerrBadNotification = aerrorNew(400, "invalid notification")
 	aerrUnsupported     = aerrorFrom(400, ipc.ErrThumbUnsupported)
 	aerrBadDimensions   = aerrorFrom(400, ipc.ErrThumbDimensions)
 	aerrNoTracks        = aerrorFrom(400, ipc.ErrThumbTracks)
+	aerrThumbTimeout    = aerrorFrom(400, ipc.ErrThumbTimeout)
 )
 
 // Legacy errors.
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/cutechan/cutechan/go/config"
+	"github.com/cutechan/cutechan/go/db"
+)
+
+const (
+	// Popular files like stickers might be used in a lot of posts.
+	maxFilePosts = 200
+)
+
+// Parse SHA1 in hex or MD5 in either hex or base64, as used by other
+// imageboards. URL-safe base64 is also accepted, since the standard
+// alphabet contains slash.
+func parseFileHash(s string) (SHA1, MD5 string, ok bool) {
+	switch len(s) {
+	case 40:
+		if _, err := hex.DecodeString(s); err == nil {
+			return strings.ToLower(s), "", true
+		}
+	case 32:
+		if buf, err := hex.DecodeString(s); err == nil {
+			return "", base64.RawStdEncoding.EncodeToString(buf), true
+		}
+	default:
+		s = strings.TrimRight(s, "=")
+		s = strings.NewReplacer("-", "+", "_", "/").Replace(s)
+		buf, err := base64.RawStdEncoding.DecodeString(s)
+		if err == nil && len(buf) == 16 {
+			return "", s, true
+		}
+	}
+	return
+}
+
+// Serve posts using the file with given hash, for reverse lookups.
+func serveFilePosts(w http.ResponseWriter, r *http.Request) {
+	SHA1, MD5, ok := parseFileHash(getParam(r, "hash"))
+	if !ok {
+		serveErrorJSON(w, r, aerrBadFileHash)
+		return
+	}
+	refs, err := db.GetFilePosts(SHA1, MD5, hiddenBoards(r), maxFilePosts)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, refs)
+}
+
+// Mod-only boards the requesting user is not allowed to see.
+func hiddenBoards(r *http.Request) (boards []string) {
+	for _, b := range config.GetAllBoardIDs() {
+		if !config.IsModOnlyBoard(b) {
+			continue
+		}
+		ss, _ := getSession(r, b)
+		if !checkModOnly(b, ss) {
+			boards = append(boards, b)
+		}
+	}
+	return
+}
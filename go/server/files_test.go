@@ -0,0 +1,62 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestParseFileHash(t *testing.T) {
+	t.Parallel()
+
+	const sha1 = "da39a3ee5e6b4b0d3255bfef95601890afd80709"
+	cases := [...]struct {
+		name, in  string
+		SHA1, MD5 string
+		ok        bool
+	}{
+		{"hex SHA1", sha1, sha1, "", true},
+		{
+			"upper case hex SHA1",
+			"DA39A3EE5E6B4B0D3255BFEF95601890AFD80709",
+			sha1, "", true,
+		},
+		{
+			"hex MD5",
+			"92eb5ffee6ae2fec3ad71c777531578f",
+			"", "kutf/uauL+w61xx3dTFXjw", true,
+		},
+		{
+			"base64 MD5",
+			"kutf/uauL+w61xx3dTFXjw==",
+			"", "kutf/uauL+w61xx3dTFXjw", true,
+		},
+		{
+			"unpadded base64 MD5",
+			"1B2M2Y8AsgTpgAmY7PhCfg",
+			"", "1B2M2Y8AsgTpgAmY7PhCfg", true,
+		},
+		{
+			"url-safe base64 MD5",
+			"kutf_uauL-w61xx3dTFXjw",
+			"", "kutf/uauL+w61xx3dTFXjw", true,
+		},
+		{"empty", "", "", "", false},
+		{"garbage", "not a hash", "", "", false},
+		{"bad hex SHA1", "zz39a3ee5e6b4b0d3255bfef95601890afd80709", "", "", false},
+		{"bad hex MD5", "zzeb5ffee6ae2fec3ad71c777531578f", "", "", false},
+		{"base64 of wrong length", "kutf/uauL+w61xx3dTFX", "", "", false},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			SHA1, MD5, ok := parseFileHash(c.in)
+			AssertDeepEquals(t, ok, c.ok)
+			AssertDeepEquals(t, SHA1, c.SHA1)
+			AssertDeepEquals(t, MD5, c.MD5)
+		})
+	}
+}
@@ -16,6 +16,10 @@ import (
 	"github.com/cutechan/cutechan/go/templates"
 )
 
+// Don't let clients make the database scan too far, also keeps page
+// offset from overflowing.
+const maxArchivePage = 1000
+
 // Apply headers and write HTML to client
 func serveHTML(w http.ResponseWriter, r *http.Request, buf []byte) {
 	head := w.Header()
@@ -80,6 +84,37 @@ func boardHTML(w http.ResponseWriter, r *http.Request, b string, catalog bool) {
 	serveHTML(w, r, html)
 }
 
+// Render a page of the board's thread archive
+func archiveHTML(w http.ResponseWriter, r *http.Request) {
+	b := getParam(r, "board")
+	if !assertBoard(w, r, b) {
+		return
+	}
+	ss, _ := getSession(r, b)
+	if !assertNotModOnly(w, r, b, ss) {
+		return
+	}
+
+	// Out of range values parse as the maximum and are rejected too.
+	p, _ := strconv.ParseUint(r.URL.Query().Get("page"), 10, 64)
+	if p > maxArchivePage {
+		serve404(w, r)
+		return
+	}
+	page := int(p)
+	threads, err := db.GetArchive(b, page)
+	if err != nil {
+		text500(w, r, err)
+		return
+	}
+
+	l := lang.FromReq(r)
+	title := config.GetBoardConfig(b).Title + " - " + lang.Get(l, "archive")
+	more := len(threads) == db.ArchivePageSize && page < maxArchivePage
+	html := templates.Archive(templates.Params{r, ss, l}, b, title, page, threads, more)
+	serveHTML(w, r, html)
+}
+
 // Asserts a thread exists on the specific board and renders the index template
 func threadHTML(w http.ResponseWriter, r *http.Request) {
 	ss, id, ok := validateThread(w, r)
@@ -90,6 +125,7 @@ func threadHTML(w http.ResponseWriter, r *http.Request) {
 	l := lang.FromReq(r)
 	lastN := detectLastN(r)
 	k := cache.ThreadKey(l, id, lastN)
+	k.Deleted = canSeeDeleted(ss)
 	html, data, _, err := cache.GetHTML(k, threadCache)
 	if err != nil {
 		respondToJSONError(w, r, err)
@@ -97,8 +133,8 @@ func threadHTML(w http.ResponseWriter, r *http.Request) {
 	}
 
 	b := getParam(r, "board")
-	title := data.(common.Thread).Subject
-	html = templates.Thread(templates.Params{r, ss, l}, id, b, title, lastN != 0, html)
+	t := data.(common.Thread)
+	html = templates.Thread(templates.Params{r, ss, l}, id, b, t.Subject, lastN != 0, t.Archived, t.Locked, html)
 	serveHTML(w, r, html)
 }
 
@@ -187,7 +223,11 @@ func validateThread(w http.ResponseWriter, r *http.Request) (
 		return
 	}
 
-	valid, err := db.ValidateOP(id, b)
+	validate := db.ValidateOP
+	if canSeeDeleted(ss) {
+		validate = db.ValidateOPWithDeleted
+	}
+	valid, err := validate(id, b)
 	if err != nil {
 		text500(w, r, err)
 		return
@@ -1,11 +1,10 @@
 package server
 
 import (
+	"strconv"
 	"testing"
 
 	"github.com/cutechan/cutechan/go/cache"
-	"github.com/cutechan/cutechan/go/config"
-	"github.com/cutechan/cutechan/go/db"
 )
 
 func TestThreadHTML(t *testing.T) {
@@ -13,8 +12,6 @@ func TestThreadHTML(t *testing.T) {
 	assertTableClear(t, "boards")
 	writeSampleBoard(t)
 	writeSampleThread(t)
-	setBoards(t, "a")
-	(*config.Get()).DefaultLang = "en_GB"
 
 	cases := [...]struct {
 		name, url string
@@ -31,7 +28,7 @@ func TestThreadHTML(t *testing.T) {
 			t.Parallel()
 
 			rec, req := newPair(c.url)
-			router.ServeHTTP(rec, req)
+			testRouter.ServeHTTP(rec, req)
 			assertCode(t, rec, c.code)
 		})
 	}
@@ -39,9 +36,10 @@ func TestThreadHTML(t *testing.T) {
 
 func TestBoardHTML(t *testing.T) {
 	cache.Clear()
-	setupPosts(t)
-	setBoards(t, "a")
-	(*config.Get()).DefaultLang = "en_GB"
+	assertTableClear(t, "boards")
+	setBoards(t)
+	writeSampleBoard(t)
+	writeSampleThread(t)
 
 	cases := [...]struct {
 		name, url string
@@ -49,7 +47,7 @@ func TestBoardHTML(t *testing.T) {
 	}{
 		{"/all/ board", "/all/", 200},
 		{"regular board", "/a/", 200},
-		{"without index template", "/a/?minimal=true", 200},
+		{"catalog", "/a/catalog", 200},
 		{"non-existent board", "/b/", 404},
 	}
 
@@ -59,72 +57,18 @@ func TestBoardHTML(t *testing.T) {
 			t.Parallel()
 
 			rec, req := newPair(c.url)
-			router.ServeHTTP(rec, req)
+			testRouter.ServeHTTP(rec, req)
 			assertCode(t, rec, c.code)
 		})
 	}
 }
 
-func TestOwnedBoardSelection(t *testing.T) {
-	assertTableClear(t, "boards", "accounts")
-	config.ClearBoards()
-	(*config.Get()).DefaultLang = "en_GB"
-	writeAdminAccount(t)
-	writeSampleUser(t)
-
-	for _, b := range [...]string{"a", "c"} {
-		err := db.WriteBoard(nil, db.BoardConfigs{
-			BoardConfigs: config.BoardConfigs{
-				ID: b,
-			},
-		})
-		if err != nil {
-			t.Fatal(err)
-		}
-		conf := config.BoardConfigs{
-			ID: b,
-		}
-		if _, err := config.SetBoardConfigs(conf); err != nil {
-			t.Fatal(err)
-		}
-	}
-
-	staff := [...]struct {
-		id     string
-		owners []string
-	}{
-		{
-			"a",
-			[]string{"user1", "admin"},
-		},
-		{
-			"c",
-			[]string{"admin"},
-		},
-	}
-	tx, err := db.StartTransaction()
-	if err != nil {
-		t.Fatal(err)
-	}
-	for _, s := range staff {
-		err := db.WriteStaff(tx, s.id, map[string][]string{
-			"owners": s.owners,
-		})
-		if err != nil {
-			tx.Rollback()
-			t.Fatal(err)
-		}
-	}
-	if err := tx.Commit(); err != nil {
-		t.Fatal(err)
-	}
-
+func TestStaticTemplates(t *testing.T) {
 	cases := [...]struct {
-		name, id string
+		name, url string
 	}{
-		{"no owned boards", "bar"},
-		{"one owned board", "user1"},
-		{"multiple owned boards", "admin"},
+		{"create board", "/html/create-board"},
+		{"change password", "/html/change-password"},
 	}
 
 	for i := range cases {
@@ -132,51 +76,42 @@ func TestOwnedBoardSelection(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
 
-			rec, req := newPair("/html/owned-boards/" + c.id)
-			router.ServeHTTP(rec, req)
+			rec, req := newPair(c.url)
+			testRouter.ServeHTTP(rec, req)
 			assertCode(t, rec, 200)
 		})
 	}
 }
 
-func TestBoardConfigurationForm(t *testing.T) {
-	config.ClearBoards()
-	(*config.Get()).DefaultLang = "en_GB"
-	assertTableClear(t, "accounts", "boards")
-	writeSampleBoard(t)
-	writeSampleUser(t)
-
-	tx, err := db.StartTransaction()
-	if err != nil {
-		t.Fatal(err)
-	}
-	err = db.WriteStaff(tx, "a", map[string][]string{
-		"owners": {"user1"},
-	})
-	if err != nil {
-		tx.Rollback()
-		t.Fatal(err)
-	}
-	if err := tx.Commit(); err != nil {
-		t.Fatal(err)
-	}
+func TestServerConfigurationForm(t *testing.T) {
+	assertTableClear(t, "accounts")
+	writeAdminAccount(t)
 
-	rec, req := newJSONPair(t, "/html/configure-board/a", nil)
-	setLoginCookies(req, sampleLoginCreds)
-	router.ServeHTTP(rec, req)
+	rec, req := newJSONPair(t, "/html/configure-server", nil)
+	setLoginCookies(req, adminLoginCreds)
+	testRouter.ServeHTTP(rec, req)
 	assertCode(t, rec, 200)
 }
 
-func TestStaticTemplates(t *testing.T) {
-	(*config.Get()).DefaultLang = "en_GB"
+func TestArchiveHTML(t *testing.T) {
+	assertTableClear(t, "boards")
+	setBoards(t)
+	writeSampleBoard(t)
 
 	cases := [...]struct {
 		name, url string
+		code      int
 	}{
-		{"create board", "/html/create-board"},
-		{"board navigation panel", "/html/board-navigation"},
-		{"change password", "/html/change-password"},
-		{"captcha confirmation", "/html/captcha"},
+		{"first page", "/a/archive", 200},
+		{"malformed page", "/a/archive?page=-1", 200},
+		{"last page", "/a/archive?page=" + strconv.Itoa(maxArchivePage), 200},
+		{
+			"page overflow",
+			"/a/archive?page=" + strconv.Itoa(maxArchivePage+1),
+			404,
+		},
+		{"int overflow", "/a/archive?page=18446744073709551615", 404},
+		{"non-existent board", "/b/archive", 404},
 	}
 
 	for i := range cases {
@@ -185,19 +120,8 @@ func TestStaticTemplates(t *testing.T) {
 			t.Parallel()
 
 			rec, req := newPair(c.url)
-			router.ServeHTTP(rec, req)
-			assertCode(t, rec, 200)
+			testRouter.ServeHTTP(rec, req)
+			assertCode(t, rec, c.code)
 		})
 	}
 }
-
-func TestServerConfigurationForm(t *testing.T) {
-	assertTableClear(t, "accounts")
-	writeAdminAccount(t)
-	(*config.Get()).DefaultLang = "en_GB"
-
-	rec, req := newJSONPair(t, "/html/configure-server", nil)
-	setLoginCookies(req, adminLoginCreds)
-	router.ServeHTTP(rec, req)
-	assertCode(t, rec, 200)
-}
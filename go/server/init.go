@@ -4,10 +4,10 @@ import (
 	"mime"
 	"net/http"
 	"net/http/pprof"
-	"runtime/debug"
-	"time"
 
 	"github.com/cutechan/cutechan/go/file"
+	"github.com/cutechan/cutechan/go/ipc"
+	"github.com/cutechan/cutechan/go/metrics"
 	"github.com/cutechan/cutechan/go/websockets"
 
 	"github.com/dimfeld/httptreemux"
@@ -17,8 +17,11 @@ type Config struct {
 	DebugRoutes  bool
 	Address      string
 	SecureCookie bool
-	ThumbUser    string
 	SiteDir      string
+	// Thumbnailer pool size, queue depth and per-job limits.
+	ThumbWorkers int
+	ThumbQueue   int
+	Thumb        ipc.ThumbOptions
 }
 
 var (
@@ -29,38 +32,26 @@ func Start(conf Config) (err error) {
 	// TODO(Kagami): Use config structs instead of globals.
 	secureCookie = conf.SecureCookie
 
-	startThumbWorkers(conf.ThumbUser)
+	if err = startThumbWorkers(conf); err != nil {
+		return
+	}
+	if err = startTusUploads(conf.Address); err != nil {
+		return
+	}
 	router := createRouter(conf)
-	go runForceFreeTask()
 	return http.ListenAndServe(conf.Address, router)
 }
 
-// If user uploads large file (40MB max by default), Go consumes quite a
-// lot of memory for temporal allocations (~200MB), releasing it
-// (obviously) a bit later. Unfortunately it doesn't hurry to return it
-// back to the OS, see:
-// https://github.com/golang/go/blob/go1.10/src/runtime/proc.go#L4191-L4193
-// See also detailed description: https://stackoverflow.com/a/14586361
-//
-// Here we force it to free memory much quicker in order to make it
-// available to other applications on the same machine (e.g. database
-// cache, file cache). This is especially useful in case of low memory
-// VPS servers.
-func runForceFreeTask() {
-	for {
-		time.Sleep(time.Minute * 5)
-		debug.FreeOSMemory()
-	}
-}
-
 func createRouter(conf Config) http.Handler {
-	r := httptreemux.NewContextMux()
-	r.NotFoundHandler = serve404
-	r.PanicHandler = text500
+	mux := httptreemux.New()
+	mux.NotFoundHandler = instrument("404", serve404)
+	mux.PanicHandler = text500
+	r := newRouter(mux)
 
 	// Make sure to control access in production.
 	if conf.DebugRoutes {
 		r.Handle("GET", "/debug/pprof/*", pprof.Index)
+		r.Handle("GET", "/metrics", metrics.Handler().ServeHTTP)
 	}
 
 	// Pages.
@@ -71,6 +62,11 @@ func createRouter(conf Config) http.Handler {
 		boardHTML(w, r, getParam(r, "board"), false)
 	})
 	r.GET("/:board/:thread", threadHTML)
+	r.GET("/:board/search", func(w http.ResponseWriter, r *http.Request) {
+		searchHTML(w, r, getParam(r, "board"))
+	})
+	r.GET("/:board/archive", archiveHTML)
+	r.GET("/:board/banned", bannedHTML)
 	r.GET("/:board/catalog", func(w http.ResponseWriter, r *http.Request) {
 		boardHTML(w, r, getParam(r, "board"), true)
 	})
@@ -78,9 +74,12 @@ func createRouter(conf Config) http.Handler {
 	r.GET("/all/catalog", func(w http.ResponseWriter, r *http.Request) {
 		boardHTML(w, r, "all", true)
 	})
-	r.GET("/admin/", assertBoardOwner(serveAdmin))
+	r.GET("/all/search", func(w http.ResponseWriter, r *http.Request) {
+		searchHTML(w, r, "all")
+	})
+	r.GET("/admin/", assertPowerUser(serveAdmin))
 	// Exactly same route, will handle board ID on JS side.
-	r.GET("/admin/:board", assertBoardOwner(serveAdmin))
+	r.GET("/admin/:board", assertPowerUser(serveAdmin))
 
 	// Assets.
 	r.GET("/static/*path", func(w http.ResponseWriter, r *http.Request) {
@@ -102,9 +101,18 @@ func createRouter(conf Config) http.Handler {
 	api.POST("/idols/:id/preview", serveSetIdolPreview)
 	// Posts.
 	api.GET("/post/:post", servePost)
+	api.GET("/search", serveSearch)
+	api.GET("/files/:hash", serveFilePosts)
+	api.Handle("OPTIONS", "/uploads", serveTusOptions)
+	api.POST("/uploads", createTusUpload)
+	api.Handle("HEAD", "/uploads/:id", serveTusUpload)
+	api.Handle("PATCH", "/uploads/:id", patchTusUpload)
+	api.DELETE("/uploads/:id", deleteTusUpload)
 	api.POST("/post/token", createPostToken)
 	api.POST("/post", createPost)
 	api.POST("/thread", createThread)
+	api.POST("/report", createReport)
+	api.POST("/appeal", createAppeal)
 	// Account.
 	api.POST("/register", register)
 	api.POST("/login", login)
@@ -112,16 +120,35 @@ func createRouter(conf Config) http.Handler {
 	api.POST("/account/settings", serverSetAccountSettings)
 	api.POST("/logout", logout)
 	api.POST("/logout/all", logoutAll)
+	api.GET("/watch", serveWatchedThreads)
+	api.POST("/watch/:thread", watchThread)
+	api.DELETE("/watch/:thread", unwatchThread)
 	// Mod.
 	api.POST("/ban", ban)
 	api.POST("/unban/:board", unban)
 	api.POST("/delete-post", deletePost)
+	api.POST("/undelete-post", undeletePost)
+	api.POST("/delete-image", deleteImage)
+	api.POST("/spoiler-image", spoilerImage)
+	api.POST("/sticky", setThreadSticky)
+	api.POST("/lock", setThreadLocked)
+	api.POST("/move", moveThread)
+	api.GET("/same-ip/:id", getSameIPPosts)
+	api.POST("/dismiss-report", dismissReport)
+	api.POST("/accept-report", acceptReport)
+	api.POST("/accept-appeal", acceptAppeal)
+	api.POST("/reject-appeal", rejectAppeal)
+	api.GET("/post/:post/metadata", servePostMetadata)
+	api.GET("/blocklist", serveBlocklist)
+	api.POST("/blocklist", blockPostFiles)
+	api.DELETE("/blocklist/:phash", unblockImage)
 	api.PUT("/boards/:board", assertBoardOwnerAPI(configureBoard))
 	// Admin.
 	api.POST("/create-board", createBoard)
 	// Too dangerous.
 	// api.POST("/delete-board", deleteBoard)
 	api.POST("/configure-server", configureServer)
+	api.POST("/notification", sendNotification)
 
 	// Partials.
 	// TODO(Kagami): Rewrite client to JSON API.
@@ -130,6 +157,6 @@ func createRouter(conf Config) http.Handler {
 	html.GET("/create-board", boardCreationForm)
 	html.POST("/configure-server", serverConfigurationForm)
 
-	h := http.Handler(r)
+	h := http.Handler(mux)
 	return h
 }
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cutechan/cutechan/go/metrics"
+
+	"github.com/dimfeld/httptreemux"
+)
+
+// Route group, that records request metrics labeled by the registered
+// route pattern. Mimics httptreemux.ContextGroup.
+type router struct {
+	group  *httptreemux.Group
+	prefix string
+}
+
+func newRouter(mux *httptreemux.TreeMux) *router {
+	return &router{group: &mux.Group}
+}
+
+func (rt *router) NewGroup(path string) *router {
+	return &router{
+		group:  rt.group.NewGroup(path),
+		prefix: rt.prefix + path,
+	}
+}
+
+func (rt *router) Handle(method, path string, h http.HandlerFunc) {
+	h = instrument(rt.prefix+path, h)
+	rt.group.Handle(method, path, func(
+		w http.ResponseWriter,
+		r *http.Request,
+		params map[string]string,
+	) {
+		if params != nil {
+			r = r.WithContext(httptreemux.AddParamsToContext(r.Context(), params))
+		}
+		h(w, r)
+	})
+}
+
+func (rt *router) GET(path string, h http.HandlerFunc) {
+	rt.Handle("GET", path, h)
+}
+
+func (rt *router) POST(path string, h http.HandlerFunc) {
+	rt.Handle("POST", path, h)
+}
+
+func (rt *router) PUT(path string, h http.HandlerFunc) {
+	rt.Handle("PUT", path, h)
+}
+
+func (rt *router) DELETE(path string, h http.HandlerFunc) {
+	rt.Handle("DELETE", path, h)
+}
+
+// Wrap handler to record its latency and response codes.
+func instrument(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			code := sw.code
+			if code == 0 {
+				code = http.StatusOK
+			}
+			metrics.HTTPRequests.
+				WithLabelValues(route, r.Method, strconv.Itoa(code)).
+				Inc()
+			metrics.HTTPDuration.
+				WithLabelValues(route, r.Method).
+				Observe(metrics.Since(start))
+		}()
+		h(sw, r)
+	}
+}
+
+// Records the response status code. Supports hijacking for websocket
+// upgrades.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(buf []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(buf)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	if w.code == 0 {
+		w.code = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
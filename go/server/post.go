@@ -30,6 +30,10 @@ func servePost(w http.ResponseWriter, r *http.Request) {
 		if !assertNotModOnly(w, r, post.Board, ss) {
 			return
 		}
+		if post.Deleted && !canSeeDeleted(ss) {
+			serve404(w, r)
+			return
+		}
 		serveJSON(w, r, post)
 	case sql.ErrNoRows:
 		serve404(w, r)
@@ -153,19 +157,32 @@ func parsePostCreationForm(w http.ResponseWriter, r *http.Request) (
 		return
 	}
 
+	// Files uploaded beforehand with resumable uploads are passed as
+	// image tokens.
+	uploaded := f["tokens[]"]
 	fhs := m.File["files[]"]
-	if len(fhs) > config.Get().MaxFiles {
+	if len(uploaded)+len(fhs) > config.GetMaxFiles(board) {
 		serveErrorJSON(w, r, aerrTooManyFiles)
 		return
 	}
-	tokens := make([]string, len(fhs))
-	for i, fh := range fhs {
+	maxSize := config.GetMaxSize(board) * 1024 * 1024
+	tokens := make([]string, len(uploaded), len(uploaded)+len(fhs))
+	copy(tokens, uploaded)
+	for _, fh := range fhs {
+		if fh.Size > maxSize {
+			serveErrorJSON(w, r, aerrTooLarge)
+			return
+		}
 		res, err := uploadFile(fh)
 		if err != nil {
 			serveErrorJSON(w, r, err)
 			return
 		}
-		tokens[i] = res.token
+		if !config.IsAllowedFileType(board, res.file.FileType) {
+			serveErrorJSON(w, r, aerrForbiddenType)
+			return
+		}
+		tokens = append(tokens, res.token)
 	}
 
 	// NOTE(Kagami): Browsers use CRLF newlines in form-data requests,
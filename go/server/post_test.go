@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
+)
+
+func TestPostCreationBoardLimits(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+	err := config.SetBoardConfig(config.BoardConfig{
+		BoardPublic: config.BoardPublic{
+			ID:           "a",
+			MaxFiles:     1,
+			MaxSize:      1,
+			AllowedTypes: []string{"png"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stubThumbnailer(common.JPEG)()
+
+	cases := [...]struct {
+		name   string
+		tokens []string
+		files  [][]byte
+		err    error
+	}{
+		{
+			name:   "too many files",
+			tokens: []string{"foo"},
+			files:  [][]byte{[]byte("bar")},
+			err:    aerrTooManyFiles,
+		},
+		{
+			name:  "too large",
+			files: [][]byte{make([]byte, 1024*1024+1)},
+			err:   aerrTooLarge,
+		},
+		{
+			name:  "forbidden type",
+			files: [][]byte{[]byte("\xff\xd8\xff")},
+			err:   aerrForbiddenType,
+		},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			var body bytes.Buffer
+			w := multipart.NewWriter(&body)
+			w.WriteField("board", "a")
+			for _, tok := range c.tokens {
+				w.WriteField("tokens[]", tok)
+			}
+			for _, data := range c.files {
+				fw, err := w.CreateFormFile("files[]", "file")
+				if err != nil {
+					t.Fatal(err)
+				}
+				fw.Write(data)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/thread", &body)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			testRouter.ServeHTTP(rec, req)
+			assertCode(t, rec, 400)
+			assertBody(t, rec, string(marshalJSON(t, c.err)))
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+)
+
+const (
+	// Reports sent from a single IP during reportLimitPeriod.
+	maxReportsPerIP   = 10
+	reportLimitPeriod = time.Hour
+)
+
+// Reporter IPs are not needed for anything but rate limiting, so only
+// store their hashes. The IPv4 space is small enough to brute force plain
+// hashes, so key them with the server secret.
+func hashIP(ip string) string {
+	mac := hmac.New(sha256.New, db.ServerSecret())
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Report a rule-breaking post to the board staff
+func createReport(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID     uint64
+		Reason string
+		auth.Captcha
+	}
+	if !decodeJSON(w, r, &msg) {
+		return
+	}
+	msg.Reason = strings.TrimSpace(msg.Reason)
+	switch {
+	case msg.Reason == "", len(msg.Reason) > common.MaxLenReportReason:
+		serveErrorJSON(w, r, aerrInvalidReport)
+		return
+	case !auth.AuthenticateCaptcha(msg.Captcha):
+		text403(w, errInvalidCaptcha)
+		return
+	}
+
+	board, err := db.GetPostBoard(msg.ID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		serveErrorJSON(w, r, aerrNoPost)
+		return
+	default:
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	ip, ok := assertNotBannedAPI(w, r, board)
+	if !ok {
+		return
+	}
+
+	ipHash := hashIP(ip)
+	n, err := db.CountReportsByIP(ipHash, reportLimitPeriod)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	if n >= maxReportsPerIP {
+		serveErrorJSON(w, r, aerrTooManyReports)
+		return
+	}
+
+	if err := db.WriteReport(msg.ID, msg.Reason, ipHash); err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveEmptyJSON(w, r)
+}
+
+// Close a report, that doesn't need any action
+func dismissReport(w http.ResponseWriter, r *http.Request) {
+	resolveReport(w, r, db.DismissReport)
+}
+
+// Close all reports of a post, that has been dealt with
+func acceptReport(w http.ResponseWriter, r *http.Request) {
+	resolveReport(w, r, db.AcceptReport)
+}
+
+func resolveReport(
+	w http.ResponseWriter,
+	r *http.Request,
+	fn func(id uint64, by string) error,
+) {
+	var msg struct {
+		ID uint64
+	}
+	if !decodeJSON(w, r, &msg) {
+		return
+	}
+	rep, err := db.GetReport(msg.ID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		text400(w, err)
+		return
+	default:
+		text500(w, r, err)
+		return
+	}
+	ss, ok := assertCanPerform(w, r, rep.Board, auth.Janitor)
+	if !ok {
+		return
+	}
+
+	switch err := fn(msg.ID, ss.UserID); err {
+	case nil:
+		serveEmptyJSON(w, r)
+	case sql.ErrNoRows:
+		text400(w, err)
+	default:
+		text500(w, r, err)
+	}
+}
@@ -0,0 +1,104 @@
+// Full-text post search
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/lang"
+	"github.com/cutechan/cutechan/go/templates"
+)
+
+const (
+	maxLenSearchQuery = 100
+	// Don't let clients make the database scan too far.
+	maxSearchPage = 50
+)
+
+// Parse search query and page from request URL.
+func getSearchParams(r *http.Request) (query string, page int, err error) {
+	q := r.URL.Query()
+	query = strings.TrimSpace(q.Get("q"))
+	if utf8.RuneCountInString(query) > maxLenSearchQuery {
+		err = aerrQueryTooLong
+		return
+	}
+	// Out of range values parse as the maximum and are rejected too.
+	p, _ := strconv.ParseUint(q.Get("page"), 10, 64)
+	if p > maxSearchPage {
+		err = aerrPageOverflow
+		return
+	}
+	page = int(p)
+	return
+}
+
+// Search posts, returning results and whether there are more pages.
+func searchPosts(board, query string, page int) (
+	res []common.SearchResult, more bool, err error,
+) {
+	res, err = db.SearchPosts(board, query, page)
+	if err != nil {
+		return
+	}
+	more = len(res) == db.SearchPageSize && page < maxSearchPage
+	return
+}
+
+// Serve search results as JSON.
+func serveSearch(w http.ResponseWriter, r *http.Request) {
+	board := r.URL.Query().Get("board")
+	if board == "" {
+		board = "all"
+	}
+	if !assertBoardAPI(w, board) {
+		return
+	}
+	ss, _ := getSession(r, board)
+	if !assertNotModOnlyAPI(w, board, ss) {
+		return
+	}
+	query, page, err := getSearchParams(r)
+	if err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	res, more, err := searchPosts(board, query, page)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, map[string]interface{}{
+		"posts": res,
+		"more":  more,
+	})
+}
+
+// Render search results page of the board.
+func searchHTML(w http.ResponseWriter, r *http.Request, board string) {
+	if !assertBoard(w, r, board) {
+		return
+	}
+	ss, _ := getSession(r, board)
+	if !assertNotModOnly(w, r, board, ss) {
+		return
+	}
+	query, page, err := getSearchParams(r)
+	if err != nil {
+		serve404(w, r)
+		return
+	}
+	res, more, err := searchPosts(board, query, page)
+	if err != nil {
+		text500(w, r, err)
+		return
+	}
+	l := lang.FromReq(r)
+	html := templates.Search(templates.Params{r, ss, l}, board, query, page, res, more)
+	serveHTML(w, r, html)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestGetSearchParams(t *testing.T) {
+	t.Parallel()
+
+	cases := [...]struct {
+		name, url string
+		page      int
+		err       error
+	}{
+		{"no page", "/api/search?q=foo", 0, nil},
+		{"page", "/api/search?q=foo&page=3", 3, nil},
+		{"malformed page", "/api/search?q=foo&page=-1", 0, nil},
+		{"last page", "/api/search?q=foo&page=50", 50, nil},
+		{"page overflow", "/api/search?q=foo&page=51", 0, aerrPageOverflow},
+		{
+			"int overflow",
+			"/api/search?q=foo&page=18446744073709551615",
+			0,
+			aerrPageOverflow,
+		},
+		{
+			"query too long",
+			"/api/search?q=" + strings.Repeat("a", maxLenSearchQuery+1),
+			0,
+			aerrQueryTooLong,
+		},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, page, err := getSearchParams(newRequest(c.url))
+			if err != c.err {
+				LogUnexpected(t, c.err, err)
+			}
+			if err == nil {
+				AssertDeepEquals(t, page, c.page)
+			}
+		})
+	}
+}
@@ -0,0 +1,418 @@
+// Resumable uploads implementing core, creation and termination parts of
+// the tus protocol, see <https://tus.io/protocols/resumable-upload.html>.
+// Finished file goes through the regular upload processing and resulting
+// image token is returned in the Image-Token header, to be passed to
+// post creation.
+//
+// Upload state is kept in memory of the instance that created the upload.
+// When running several instances behind a load balancer, requests to
+// /api/uploads must be routed to the same instance for the whole upload,
+// e.g. by client IP hash.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cutechan/cutechan/go/config"
+	"github.com/cutechan/cutechan/go/db"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination"
+	// Unfinished uploads are discarded after this period of inactivity.
+	// Also applies to image tokens of finished uploads.
+	tusUploadTimeout   = time.Hour
+	maxTusUploads      = 256
+	maxTusUploadsPerIP = 4
+)
+
+var (
+	tusUploads   = make(map[string]*tusUpload)
+	tusUploadsMu sync.Mutex
+	// Temporary directory of this instance
+	tusDir string
+)
+
+// Offset, token and expiry are only changed with tusUploadsMu held, so they
+// can be reported while a request is writing to the upload.
+type tusUpload struct {
+	ip      string
+	board   string
+	path    string
+	length  int64
+	offset  int64
+	token   string
+	expires time.Time
+	// Request is writing to the upload.
+	busy bool
+}
+
+// Remove leftovers of the previous run, since upload state is kept in
+// memory. Every instance uses its own directory named after the listen
+// address, so instances sharing a temporary directory don't remove each
+// other's uploads.
+func startTusUploads(address string) error {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			return r
+		default:
+			return '_'
+		}
+	}, address)
+	tusDir = filepath.Join(os.TempDir(), "cutechan-tus-"+name)
+	if err := os.RemoveAll(tusDir); err != nil {
+		return err
+	}
+	return os.MkdirAll(tusDir, 0700)
+}
+
+// Must be called with tusUploadsMu held.
+func pruneTusUploads() {
+	now := time.Now()
+	for id, u := range tusUploads {
+		if !u.busy && u.expires.Before(now) {
+			removeTusUpload(id, u)
+		}
+	}
+}
+
+// Must be called with tusUploadsMu held.
+func removeTusUpload(id string, u *tusUpload) {
+	delete(tusUploads, id)
+	if u.token != "" {
+		db.DeleteImageToken(u.token)
+	}
+	os.Remove(u.path)
+}
+
+func newTusID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Decode Upload-Metadata header, which consists of comma-separated
+// key-value pairs with base64-encoded values.
+func parseTusMetadata(s string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.Fields(pair)
+		switch len(kv) {
+		case 1:
+			meta[kv[0]] = ""
+		case 2:
+			val, err := base64.StdEncoding.DecodeString(kv[1])
+			if err == nil {
+				meta[kv[0]] = string(val)
+			}
+		}
+	}
+	return meta
+}
+
+// Set common headers and check protocol version of the client.
+func assertTusVersion(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		serveErrorJSON(w, r, aerrTusVersion)
+		return false
+	}
+	return true
+}
+
+// Get upload from URL parameter and mark it as busy, so concurrent
+// requests don't corrupt it.
+func acquireTusUpload(w http.ResponseWriter, r *http.Request) (
+	id string, u *tusUpload, ok bool,
+) {
+	id = getParam(r, "id")
+	tusUploadsMu.Lock()
+	defer tusUploadsMu.Unlock()
+	u = tusUploads[id]
+	switch {
+	case u == nil || u.expires.Before(time.Now()):
+		serveErrorJSON(w, r, aerrNoUpload)
+	case u.busy:
+		serveErrorJSON(w, r, aerrUploadBusy)
+	default:
+		u.busy = true
+		ok = true
+	}
+	return
+}
+
+func releaseTusUpload(u *tusUpload) {
+	tusUploadsMu.Lock()
+	defer tusUploadsMu.Unlock()
+	u.busy = false
+	u.expires = time.Now().Add(tusUploadTimeout)
+}
+
+// Describe server capabilities. Size limit depends on the board, which
+// can be passed in the query string. Otherwise the largest limit of all
+// boards is reported and the exact one is checked on creation.
+func serveTusOptions(w http.ResponseWriter, r *http.Request) {
+	var max int64
+	if board := r.URL.Query().Get("board"); config.IsBoard(board) && board != "all" {
+		max = config.GetMaxSize(board)
+	} else {
+		max = config.Get().MaxSize
+		for _, b := range config.GetAllBoardIDs() {
+			if n := config.GetMaxSize(b); n > max {
+				max = n
+			}
+		}
+	}
+	head := w.Header()
+	head.Set("Tus-Resumable", tusVersion)
+	head.Set("Tus-Version", tusVersion)
+	head.Set("Tus-Extension", tusExtensions)
+	head.Set("Tus-Max-Size", strconv.FormatInt(max*1024*1024, 10))
+	w.WriteHeader(204)
+}
+
+// Create new upload. Board is passed in the metadata, so size and
+// posting permissions are checked early.
+func createTusUpload(w http.ResponseWriter, r *http.Request) {
+	if !assertTusVersion(w, r) {
+		return
+	}
+
+	board := parseTusMetadata(r.Header.Get("Upload-Metadata"))["board"]
+	if !assertBoardAPI(w, board) {
+		return
+	}
+	if board == "all" {
+		text400(w, errInvalidBoard)
+		return
+	}
+	ss, _ := getSession(r, board)
+	if !assertNotModOnlyAPI(w, board, ss) {
+		return
+	}
+	if !assertNotReadOnlyAPI(w, board, ss) {
+		return
+	}
+	ip, ok := assertNotBannedAPI(w, r, board)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	switch {
+	case err != nil || length <= 0:
+		serveErrorJSON(w, r, aerrBadUploadLength)
+		return
+	case length > config.GetMaxSize(board)*1024*1024:
+		serveErrorJSON(w, r, aerrTooLarge)
+		return
+	}
+
+	id, err := newTusID()
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	path := filepath.Join(tusDir, id)
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	fd.Close()
+
+	tusUploadsMu.Lock()
+	pruneTusUploads()
+	// Finished uploads only hold an image token and no longer take space.
+	n := 0
+	for _, u := range tusUploads {
+		if u.ip == ip && u.token == "" {
+			n++
+		}
+	}
+	switch {
+	case len(tusUploads) >= maxTusUploads:
+		err = aerrUploadsBusy
+	case n >= maxTusUploadsPerIP:
+		err = aerrTooManyUploads
+	default:
+		tusUploads[id] = &tusUpload{
+			ip:      ip,
+			board:   board,
+			path:    path,
+			length:  length,
+			expires: time.Now().Add(tusUploadTimeout),
+		}
+	}
+	tusUploadsMu.Unlock()
+	if err != nil {
+		os.Remove(path)
+		serveErrorJSON(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/uploads/"+id)
+	w.WriteHeader(201)
+}
+
+// Report upload offset, so client knows where to resume from.
+func serveTusUpload(w http.ResponseWriter, r *http.Request) {
+	if !assertTusVersion(w, r) {
+		return
+	}
+	tusUploadsMu.Lock()
+	u := tusUploads[getParam(r, "id")]
+	var head http.Header
+	if u != nil && !u.expires.Before(time.Now()) {
+		head = w.Header()
+		head.Set("Upload-Offset", strconv.FormatInt(u.offset, 10))
+		head.Set("Upload-Length", strconv.FormatInt(u.length, 10))
+		if u.token != "" {
+			head.Set("Image-Token", u.token)
+		}
+	}
+	tusUploadsMu.Unlock()
+
+	if head == nil {
+		w.WriteHeader(404)
+		return
+	}
+	head.Set("Cache-Control", "no-store")
+	w.WriteHeader(200)
+}
+
+// Append chunk to the upload. Once all data is received, the file is
+// processed and the image token is returned.
+func patchTusUpload(w http.ResponseWriter, r *http.Request) {
+	if !assertTusVersion(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		serveErrorJSON(w, r, aerrBadUploadType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		serveErrorJSON(w, r, aerrBadUploadOffset)
+		return
+	}
+
+	id, u, ok := acquireTusUpload(w, r)
+	if !ok {
+		return
+	}
+	defer releaseTusUpload(u)
+	if offset != u.offset || u.token != "" {
+		serveErrorJSON(w, r, aerrBadUploadOffset)
+		return
+	}
+
+	fd, err := os.OpenFile(u.path, os.O_WRONLY, 0600)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	if _, err = fd.Seek(u.offset, io.SeekStart); err != nil {
+		fd.Close()
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	// Keep whatever was received, even if the connection was interrupted,
+	// so the client can resume from there.
+	n, err := io.Copy(fd, io.LimitReader(r.Body, u.length-u.offset))
+	tusUploadsMu.Lock()
+	u.offset += n
+	tusUploadsMu.Unlock()
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		serveErrorJSON(w, r, aerrUploadRead.Hide(err))
+		return
+	}
+
+	if u.offset == u.length {
+		token, err := finishTusUpload(u)
+		tusUploadsMu.Lock()
+		switch {
+		case err == nil:
+			u.token = token
+			os.Remove(u.path)
+		case !isRetryableUploadError(err):
+			removeTusUpload(id, u)
+		}
+		tusUploadsMu.Unlock()
+		if err != nil {
+			serveErrorJSON(w, r, err)
+			return
+		}
+		w.Header().Set("Image-Token", token)
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.offset, 10))
+	w.WriteHeader(204)
+}
+
+// Server side failures, e.g. full thumbnailer queue, don't depend on the
+// file, so the assembled upload is kept and the client may retry with an
+// empty PATCH at the final offset.
+func isRetryableUploadError(err error) bool {
+	aerr, ok := err.(ApiError)
+	return !ok || aerr.Code() >= 500
+}
+
+// Run assembled file through the regular upload processing.
+func finishTusUpload(u *tusUpload) (token string, err error) {
+	fd, err := os.Open(u.path)
+	if err != nil {
+		err = aerrInternal.Hide(err)
+		return
+	}
+	defer fd.Close()
+
+	res, err := processUpload(fd)
+	if err != nil {
+		return
+	}
+	if !config.IsAllowedFileType(u.board, res.file.FileType) {
+		db.DeleteImageToken(res.token)
+		err = aerrForbiddenType
+		return
+	}
+	if err = db.ExtendImageToken(res.token, tusUploadTimeout); err != nil {
+		err = aerrInternal.Hide(err)
+		return
+	}
+	token = res.token
+	return
+}
+
+// Abort upload and free its resources.
+func deleteTusUpload(w http.ResponseWriter, r *http.Request) {
+	if !assertTusVersion(w, r) {
+		return
+	}
+	id, u, ok := acquireTusUpload(w, r)
+	if !ok {
+		return
+	}
+	tusUploadsMu.Lock()
+	removeTusUpload(id, u)
+	tusUploadsMu.Unlock()
+	w.WriteHeader(204)
+}
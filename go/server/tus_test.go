@@ -0,0 +1,303 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+// Start with no uploads in a fresh temporary directory.
+func setupTusUploads(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "cutechan-test-tus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tusDir = dir
+	tusUploadsMu.Lock()
+	tusUploads = make(map[string]*tusUpload)
+	tusUploadsMu.Unlock()
+	return func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Replace thumbnailer workers with a stub, which reports every upload as
+// a file of the given type.
+func stubThumbnailer(typ uint8) func() {
+	prev := jobs
+	ch := make(chan jobRequest)
+	done := make(chan struct{})
+	jobs = ch
+	go func() {
+		for {
+			select {
+			case jreq := <-ch:
+				io.Copy(ioutil.Discard, jreq.fd)
+				jreq.jresults <- jobResult{res: uploadResult{
+					file:  &common.ImageCommon{FileType: typ},
+					token: GenString(common.LenImageToken),
+				}}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		jobs = prev
+	}
+}
+
+func newTusPair(method, url string, body []byte, head map[string]string) (
+	*httptest.ResponseRecorder, *http.Request,
+) {
+	req := httptest.NewRequest(method, url, bytes.NewReader(body))
+	req.Header.Set("Tus-Resumable", tusVersion)
+	for k, v := range head {
+		req.Header.Set(k, v)
+	}
+	return httptest.NewRecorder(), req
+}
+
+// Create upload of the given length on board "a" and return its URL.
+func createTestTusUpload(t *testing.T, length int) string {
+	rec, req := newTusPair("POST", "/api/uploads", nil, map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": "board " + base64.StdEncoding.EncodeToString([]byte("a")),
+	})
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 201)
+	url := rec.Header().Get("Location")
+	if url == "" {
+		t.Fatal("no upload location")
+	}
+	return url
+}
+
+func patchTestTusUpload(url string, offset int, data []byte) *httptest.ResponseRecorder {
+	rec, req := newTusPair("PATCH", url, data, map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.Itoa(offset),
+	})
+	testRouter.ServeHTTP(rec, req)
+	return rec
+}
+
+func headTestTusUpload(url string) *httptest.ResponseRecorder {
+	rec, req := newTusPair("HEAD", url, nil, nil)
+	testRouter.ServeHTTP(rec, req)
+	return rec
+}
+
+func assertTusFile(t *testing.T, url string, exists bool) {
+	_, err := os.Stat(filepath.Join(tusDir, filepath.Base(url)))
+	if exists && err != nil {
+		t.Errorf("upload file missing: %s", err)
+	} else if !exists && !os.IsNotExist(err) {
+		t.Errorf("upload file not removed: %v", err)
+	}
+}
+
+func TestTusOptions(t *testing.T) {
+	assertTableClear(t, "boards")
+	setBoards(t)
+	writeSampleBoard(t)
+	writeBoard(t, "b")
+	err := config.SetBoardConfig(config.BoardConfig{
+		BoardPublic: config.BoardPublic{
+			ID:      "b",
+			MaxSize: config.Get().MaxSize + 10,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := [...]struct {
+		name, query string
+		max         int64
+	}{
+		{"default board", "?board=a", config.GetMaxSize("a")},
+		{"board with own limit", "?board=b", config.GetMaxSize("b")},
+		{"no board", "", config.GetMaxSize("b")},
+		{"unknown board", "?board=nope", config.GetMaxSize("b")},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("OPTIONS", "/api/uploads"+c.query, nil)
+			testRouter.ServeHTTP(rec, req)
+			assertCode(t, rec, 204)
+			assertHeaders(t, rec, map[string]string{
+				"Tus-Version":  tusVersion,
+				"Tus-Max-Size": strconv.FormatInt(c.max*1024*1024, 10),
+			})
+		})
+	}
+}
+
+func TestTusCreate(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+	defer setupTusUploads(t)()
+
+	meta := "board " + base64.StdEncoding.EncodeToString([]byte("a"))
+	tooLarge := strconv.FormatInt(config.GetMaxSize("a")*1024*1024+1, 10)
+	cases := [...]struct {
+		name string
+		head map[string]string
+		code int
+	}{
+		{
+			"wrong version",
+			map[string]string{"Tus-Resumable": "0.2.2"},
+			412,
+		},
+		{
+			"no length",
+			map[string]string{"Upload-Metadata": meta},
+			400,
+		},
+		{
+			"too large",
+			map[string]string{"Upload-Metadata": meta, "Upload-Length": tooLarge},
+			400,
+		},
+		{
+			"no board",
+			map[string]string{"Upload-Length": "10"},
+			400,
+		},
+		{
+			"valid",
+			map[string]string{"Upload-Metadata": meta, "Upload-Length": "10"},
+			201,
+		},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			rec, req := newTusPair("POST", "/api/uploads", nil, c.head)
+			testRouter.ServeHTTP(rec, req)
+			assertCode(t, rec, c.code)
+		})
+	}
+}
+
+func TestTusUpload(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+	defer setupTusUploads(t)()
+
+	data := []byte("0123456789")
+	url := createTestTusUpload(t, len(data))
+
+	rec := headTestTusUpload(url)
+	assertCode(t, rec, 200)
+	assertHeaders(t, rec, map[string]string{
+		"Upload-Offset": "0",
+		"Upload-Length": "10",
+	})
+
+	// Offset mismatch
+	rec = patchTestTusUpload(url, 3, data[3:])
+	assertCode(t, rec, 409)
+
+	rec = patchTestTusUpload(url, 0, data[:4])
+	assertCode(t, rec, 204)
+	assertHeaders(t, rec, map[string]string{"Upload-Offset": "4"})
+
+	// Resending already received chunk
+	rec = patchTestTusUpload(url, 0, data[:4])
+	assertCode(t, rec, 409)
+
+	rec = headTestTusUpload(url)
+	assertHeaders(t, rec, map[string]string{"Upload-Offset": "4"})
+
+	// No thumbnailer workers, so the queue is always full. Assembled file
+	// must be kept for the retry.
+	prev := jobs
+	jobs = nil
+	rec = patchTestTusUpload(url, 4, data[4:])
+	jobs = prev
+	assertCode(t, rec, 503)
+	assertTusFile(t, url, true)
+	rec = headTestTusUpload(url)
+	assertCode(t, rec, 200)
+	assertHeaders(t, rec, map[string]string{
+		"Upload-Offset": "10",
+		"Image-Token":   "",
+	})
+
+	// Retry with an empty chunk at the final offset
+	defer stubThumbnailer(common.JPEG)()
+	rec = patchTestTusUpload(url, 10, nil)
+	assertCode(t, rec, 204)
+	token := rec.Header().Get("Image-Token")
+	if len(token) != common.LenImageToken {
+		t.Fatalf("invalid image token: %q", token)
+	}
+	assertHeaders(t, rec, map[string]string{"Upload-Offset": "10"})
+	assertTusFile(t, url, false)
+
+	// Token is reported, if the response was lost
+	rec = headTestTusUpload(url)
+	assertCode(t, rec, 200)
+	assertHeaders(t, rec, map[string]string{"Image-Token": token})
+
+	// Finished uploads don't accept data
+	rec = patchTestTusUpload(url, 10, nil)
+	assertCode(t, rec, 409)
+}
+
+func TestTusUploadForbiddenType(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+	defer setupTusUploads(t)()
+	defer stubThumbnailer(common.ZIP)()
+
+	data := []byte("PK\x03\x04")
+	url := createTestTusUpload(t, len(data))
+	rec := patchTestTusUpload(url, 0, data)
+	assertCode(t, rec, 400)
+	assertBody(t, rec, string(marshalJSON(t, aerrForbiddenType)))
+
+	// Permanent errors discard the upload
+	assertCode(t, headTestTusUpload(url), 404)
+	assertTusFile(t, url, false)
+}
+
+func TestTusDelete(t *testing.T) {
+	assertTableClear(t, "boards")
+	writeSampleBoard(t)
+	defer setupTusUploads(t)()
+
+	url := createTestTusUpload(t, 10)
+	assertCode(t, patchTestTusUpload(url, 0, []byte("0123")), 204)
+
+	rec, req := newTusPair("DELETE", url, nil, nil)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 204)
+	assertCode(t, headTestTusUpload(url), 404)
+	assertTusFile(t, url, false)
+
+	rec, req = newTusPair("DELETE", url, nil, nil)
+	testRouter.ServeHTTP(rec, req)
+	assertCode(t, rec, 404)
+}
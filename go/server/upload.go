@@ -1,25 +1,30 @@
 package server
 
 import (
+	"crypto/md5"
 	"crypto/sha1"
 	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"os"
+	"time"
 
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
 	"github.com/cutechan/cutechan/go/ipc"
-)
-
-const (
-	// Maximum number of thumbnailer processes executing at the same time.
-	thumbProcesses = 1
+	"github.com/cutechan/cutechan/go/metadata"
+	"github.com/cutechan/cutechan/go/metrics"
 )
 
 var (
-	jobs = make(chan jobRequest)
+	// Uploads waiting for a thumbnailer worker. Bounded, so uploads are
+	// rejected instead of piling up under load.
+	jobs chan jobRequest
 
 	// Map of MIME types to the constants used internally.
 	mimeTypes = map[string]uint8{
@@ -31,11 +36,16 @@ var (
 		"application/ogg": common.OGG,
 		"video/mp4":       common.MP4,
 		"audio/mpeg":      common.MP3,
+
+		"application/zip":             common.ZIP,
+		"application/x-7z-compressed": common.SevenZip,
+		"application/gzip":            common.TGZ,
+		"application/x-xz":            common.TXZ,
 	}
 )
 
 type jobRequest struct {
-	fd       multipart.File
+	fd       io.Reader
 	jresults chan<- jobResult
 }
 
@@ -61,51 +71,138 @@ func uploadFile(fh *multipart.FileHeader) (res uploadResult, err error) {
 		return
 	}
 	defer fd.Close()
+	return processUpload(fd)
+}
 
+// Queue upload for thumbnailing and wait for the result.
+func processUpload(fd io.Reader) (res uploadResult, err error) {
 	jresults := make(chan jobResult)
 	jreq := jobRequest{fd, jresults}
-	jobs <- jreq
+	select {
+	case jobs <- jreq:
+		metrics.ThumbnailQueue.Inc()
+	default:
+		err = aerrUploadsBusy
+		return
+	}
 	jres := <-jresults
 	return jres.res, jres.err
 }
 
-func worker(user string) {
+func worker(opts ipc.ThumbOptions) {
 	for {
 		jreq := <-jobs
-		res, err := work(user, jreq)
+		metrics.ThumbnailQueue.Dec()
+		res, err := work(opts, jreq)
 		jreq.jresults <- jobResult{res, err}
 	}
 }
 
-func work(user string, jreq jobRequest) (res uploadResult, err error) {
-	data, err := ioutil.ReadAll(jreq.fd)
+func work(opts ipc.ThumbOptions, jreq jobRequest) (res uploadResult, err error) {
+	tmp, file, err := bufferUpload(jreq.fd)
 	if err != nil {
 		err = aerrUploadRead.Hide(err)
 		return
 	}
-	hash := getSha1(data)
-	file, err := db.GetImage(hash)
+	defer removeTemp(tmp)
+
+	// Uploads are looked up by the original hash, so the same file is
+	// deduplicated even if stored with metadata stripped.
+	existing, meta, err := db.GetImageByOriginal(file.SHA1)
 	switch err {
 	case nil:
+		// Files stored before metadata stripping was introduced are only
+		// matched by their own hash and might still carry metadata.
+		if existing.SHA1 == file.SHA1 &&
+			(existing.FileType == common.JPEG || existing.FileType == common.PNG) {
+			return restripFile(opts, tmp, &existing, meta)
+		}
 		// Already have thumbnail.
-		return newFileToken(&file)
+		return newFileToken(&existing, meta)
 	case sql.ErrNoRows:
-		file.SHA1 = hash
-		return saveFile(user, data, &file)
+		return saveFile(opts, tmp, &file)
 	default:
 		err = aerrInternal.Hide(err)
 		return
 	}
 }
 
-func getSha1(data []byte) string {
-	hash := sha1.Sum(data)
-	return hex.EncodeToString(hash[:])
+// Copy upload to a temporary file, computing its hashes and size on the
+// way.
+func bufferUpload(r io.Reader) (tmp *os.File, file common.ImageCommon, err error) {
+	return bufferFile(func(w io.Writer) (err error) {
+		_, err = io.Copy(w, r)
+		return
+	})
+}
+
+// Copy upload without metadata to a new temporary file. Returns nil file,
+// if there was nothing to strip.
+func stripUpload(src *os.File) (
+	tmp *os.File,
+	file common.ImageCommon,
+	sum *common.MetadataSummary,
+	err error,
+) {
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	tmp, file, err = bufferFile(func(w io.Writer) (err error) {
+		sum, err = metadata.Strip(src, w)
+		return
+	})
+	if err == nil && sum == nil {
+		removeTemp(tmp)
+		tmp = nil
+	}
+	return
+}
+
+// Write file produced by write to a temporary file, computing its hashes
+// and size on the way. Keeps memory usage bounded regardless of the file
+// size.
+func bufferFile(write func(io.Writer) error) (tmp *os.File, file common.ImageCommon, err error) {
+	tmp, err = ioutil.TempFile("", "cutechan-upload-")
+	if err != nil {
+		return
+	}
+	sha1Hash := sha1.New()
+	md5Hash := md5.New()
+	err = write(io.MultiWriter(tmp, sha1Hash, md5Hash))
+	if err != nil {
+		removeTemp(tmp)
+		return
+	}
+	n, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		removeTemp(tmp)
+		return
+	}
+	file.SHA1 = hex.EncodeToString(sha1Hash.Sum(nil))
+	file.MD5 = base64.RawStdEncoding.EncodeToString(md5Hash.Sum(nil))
+	file.Size = int(n)
+	return
+}
+
+func removeTemp(tmp *os.File) {
+	tmp.Close()
+	os.Remove(tmp.Name())
 }
 
-func newFileToken(file *common.ImageCommon) (res uploadResult, err error) {
+func newFileToken(
+	file *common.ImageCommon,
+	meta *common.MetadataSummary,
+) (res uploadResult, err error) {
+	// Image might have been blocked after it was uploaded.
+	if db.IsBlockedImage(file.PHash, phashThreshold()) {
+		err = aerrBlockedImage
+		return
+	}
+	if !config.Get().MetadataSummary {
+		meta = nil
+	}
 	res.file = file
-	res.token, err = db.NewImageToken(file.SHA1)
+	res.token, err = db.NewImageToken(file.SHA1, meta)
 	if err != nil {
 		err = aerrInternal.Hide(err)
 		return
@@ -113,10 +210,88 @@ func newFileToken(file *common.ImageCommon) (res uploadResult, err error) {
 	return
 }
 
+// Strip metadata of a file and store it, if it isn't in the DB yet.
+func saveFile(opts ipc.ThumbOptions, src *os.File, file *common.ImageCommon) (res uploadResult, err error) {
+	stripped, strippedFile, meta, err := stripUpload(src)
+	switch err {
+	case nil:
+	case metadata.ErrMalformed:
+		err = aerrCorrupted
+		return
+	default:
+		err = aerrInternal.Hide(err)
+		return
+	}
+	if stripped == nil {
+		return thumbnailFile(opts, src, file, file, nil)
+	}
+	defer removeTemp(stripped)
+	return saveStripped(opts, stripped, &strippedFile, file, meta)
+}
+
+// Store stripped copy of an already stored file, so new posts no longer
+// use the original. Posts made before keep referencing it.
+func restripFile(
+	opts ipc.ThumbOptions,
+	src *os.File,
+	existing *common.ImageCommon,
+	meta *common.MetadataSummary,
+) (res uploadResult, err error) {
+	stripped, strippedFile, strippedMeta, err := stripUpload(src)
+	switch {
+	case err == metadata.ErrMalformed, err == nil && stripped == nil:
+		// Was accepted before, keep using it as is.
+		return newFileToken(existing, meta)
+	case err != nil:
+		err = aerrInternal.Hide(err)
+		return
+	}
+	defer removeTemp(stripped)
+	return saveStripped(opts, stripped, &strippedFile, existing, strippedMeta)
+}
+
+// Store file with metadata stripped and map hashes of the original upload
+// to it.
+func saveStripped(
+	opts ipc.ThumbOptions,
+	src *os.File,
+	file *common.ImageCommon,
+	original *common.ImageCommon,
+	meta *common.MetadataSummary,
+) (res uploadResult, err error) {
+	if !config.Get().MetadataSummary {
+		meta = nil
+	}
+
+	// Same image might have been uploaded with different metadata.
+	existing, err := db.GetImage(file.SHA1)
+	switch err {
+	case nil:
+		if err = db.WriteImageOriginal(original.SHA1, original.MD5, file.SHA1, meta); err != nil {
+			err = aerrInternal.Hide(err)
+			return
+		}
+		return newFileToken(&existing, meta)
+	case sql.ErrNoRows:
+		return thumbnailFile(opts, src, file, original, meta)
+	default:
+		err = aerrInternal.Hide(err)
+		return
+	}
+}
+
 // Create a new thumbnail, commit its resources to the DB and
 // filesystem, and return resulting token.
-func saveFile(user string, srcData []byte, file *common.ImageCommon) (res uploadResult, err error) {
-	thumb, err := ipc.GetThumbnail(user, srcData)
+func thumbnailFile(
+	opts ipc.ThumbOptions,
+	src *os.File,
+	file *common.ImageCommon,
+	original *common.ImageCommon,
+	meta *common.MetadataSummary,
+) (res uploadResult, err error) {
+	start := time.Now()
+	thumb, err := ipc.GetThumbnail(opts, src)
+	metrics.ThumbnailDuration.Observe(metrics.Since(start))
 	switch err {
 	case nil:
 		// Do nothing.
@@ -132,13 +307,15 @@ func saveFile(user string, srcData []byte, file *common.ImageCommon) (res upload
 	case ipc.ErrThumbProcess:
 		err = aerrCorrupted
 		return
+	case ipc.ErrThumbTimeout:
+		err = aerrThumbTimeout
+		return
 	default:
 		err = aerrInternal.Hide(err)
 		return
 	}
 
 	// Map fields.
-	file.Size = len(srcData)
 	file.Video = thumb.HasVideo
 	file.Audio = thumb.HasAudio
 	file.FileType = mimeTypes[thumb.Mime]
@@ -149,19 +326,62 @@ func saveFile(user string, srcData []byte, file *common.ImageCommon) (res upload
 	}
 	file.Length = thumb.Duration
 	file.Title = thumb.Title
+	file.Artist = thumb.Artist
 	file.Dims = [4]uint16{thumb.SrcWidth, thumb.SrcHeight, thumb.Width, thumb.Height}
+	file.PHash = thumb.PHash
+	for _, e := range thumb.Contents {
+		file.Contents = append(file.Contents, common.ArchiveEntry{
+			Name: e.Name,
+			Size: e.Size,
+		})
+	}
+	variants := make(map[string][]byte, len(thumb.Variants))
+	for _, v := range thumb.Variants {
+		file.Variants = append(file.Variants, common.ThumbVariant{
+			Name:   v.Name,
+			Width:  v.Width,
+			Height: v.Height,
+		})
+		variants[v.Name] = v.Data
+	}
 
-	if err = db.AllocateImage(srcData, thumb.Data, *file); err != nil {
+	if db.IsBlockedImage(file.PHash, phashThreshold()) {
+		err = aerrBlockedImage
+		return
+	}
+
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		err = aerrInternal.Hide(err)
+		return
+	}
+	if err = db.AllocateImage(src, thumb.Data, variants, *file); err != nil {
 		err = aerrInternal.Hide(err)
 		return
 	}
-	return newFileToken(file)
+	if original.SHA1 != file.SHA1 {
+		if err = db.WriteImageOriginal(original.SHA1, original.MD5, file.SHA1, meta); err != nil {
+			err = aerrInternal.Hide(err)
+			return
+		}
+	}
+	return newFileToken(file, meta)
 }
 
 // Start thumbnailer workers.
-func startThumbWorkers(user string) (err error) {
-	for i := 0; i < thumbProcesses; i++ {
-		go worker(user)
+func startThumbWorkers(conf Config) (err error) {
+	switch {
+	case conf.ThumbWorkers < 1:
+		return fmt.Errorf("invalid thumbnailer workers: %d", conf.ThumbWorkers)
+	case conf.ThumbQueue < 0:
+		return fmt.Errorf("invalid thumbnailer queue: %d", conf.ThumbQueue)
+	case conf.Thumb.Timeout <= 0:
+		return fmt.Errorf("invalid thumbnailer timeout: %s", conf.Thumb.Timeout)
+	case conf.Thumb.MaxCPU < 0:
+		return fmt.Errorf("invalid thumbnailer CPU limit: %s", conf.Thumb.MaxCPU)
+	}
+	jobs = make(chan jobRequest, conf.ThumbQueue)
+	for i := 0; i < conf.ThumbWorkers; i++ {
+		go worker(conf.Thumb)
 	}
 	return
 }
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cutechan/cutechan/go/ipc"
+)
+
+func TestStartThumbWorkersInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	thumb := ipc.ThumbOptions{Timeout: time.Minute}
+	cases := [...]struct {
+		name string
+		conf Config
+	}{
+		{"no workers", Config{ThumbWorkers: 0, Thumb: thumb}},
+		{"negative workers", Config{ThumbWorkers: -1, Thumb: thumb}},
+		{"negative queue", Config{ThumbWorkers: 1, ThumbQueue: -1, Thumb: thumb}},
+		{"no timeout", Config{ThumbWorkers: 1}},
+		{
+			"negative timeout",
+			Config{
+				ThumbWorkers: 1,
+				Thumb:        ipc.ThumbOptions{Timeout: -time.Second},
+			},
+		},
+		{
+			"negative CPU limit",
+			Config{
+				ThumbWorkers: 1,
+				Thumb: ipc.ThumbOptions{
+					Timeout: time.Minute,
+					MaxCPU:  -time.Second,
+				},
+			},
+		},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := startThumbWorkers(c.conf); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
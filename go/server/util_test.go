@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -11,8 +12,37 @@ import (
 
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/file"
+	"github.com/cutechan/cutechan/go/lang"
+	"github.com/cutechan/cutechan/go/templates"
 )
 
+// Router with all routes of the server
+var testRouter http.Handler
+
+func init() {
+	db.ConnArgs = db.TestConnArgs
+	db.IsTest = true
+	dir, err := ioutil.TempDir("", "cutechan-test-uploads")
+	if err != nil {
+		panic(err)
+	}
+	fns := [...]func() error{
+		db.StartDB,
+		lang.Load,
+		templates.CompileMustache,
+		func() error {
+			return file.StartBackend(file.Config{Backend: "fs", Dir: dir})
+		},
+	}
+	for _, fn := range fns {
+		if err := fn(); err != nil {
+			panic(err)
+		}
+	}
+	testRouter = createRouter(Config{})
+}
+
 func newRequest(url string) *http.Request {
 	return httptest.NewRequest("GET", url, nil)
 }
@@ -67,10 +97,14 @@ func marshalJSON(t *testing.T, msg interface{}) []byte {
 }
 
 func setBoards(t *testing.T, boards ...string) {
-	config.ClearBoards()
+	for _, b := range config.GetBoardIDs() {
+		config.RemoveBoard(b)
+	}
 	for _, b := range boards {
-		_, err := config.SetBoardConfigs(config.BoardConfigs{
-			ID: b,
+		err := config.SetBoardConfig(config.BoardConfig{
+			BoardPublic: config.BoardPublic{
+				ID: b,
+			},
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -82,9 +116,8 @@ func TestText404(t *testing.T) {
 	t.Parallel()
 
 	rec, req := newPair("/lalala/")
-	router.ServeHTTP(rec, req)
+	testRouter.ServeHTTP(rec, req)
 	assertCode(t, rec, 404)
-	assertBody(t, rec, "404 not found\n")
 }
 
 func TestText40X(t *testing.T) {
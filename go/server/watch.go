@@ -0,0 +1,77 @@
+// Thread watcher API
+
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/cutechan/cutechan/go/db"
+)
+
+// Serve threads watched by the logged in user.
+func serveWatchedThreads(w http.ResponseWriter, r *http.Request) {
+	ss, _ := getSession(r, "")
+	if ss == nil {
+		serveErrorJSON(w, r, aerrNotLoggedIn)
+		return
+	}
+	threads, err := db.GetWatchedThreads(ss.UserID)
+	if err != nil {
+		serveErrorJSON(w, r, aerrInternal.Hide(err))
+		return
+	}
+	serveJSON(w, r, threads)
+}
+
+// Add thread to the watch list of the logged in user.
+func watchThread(w http.ResponseWriter, r *http.Request) {
+	if err := handleWatch(r, db.WatchThread); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	serveEmptyJSON(w, r)
+}
+
+// Remove thread from the watch list of the logged in user.
+func unwatchThread(w http.ResponseWriter, r *http.Request) {
+	if err := handleWatch(r, db.UnwatchThread); err != nil {
+		serveErrorJSON(w, r, err)
+		return
+	}
+	serveEmptyJSON(w, r)
+}
+
+func handleWatch(r *http.Request, fn func(string, uint64) error) error {
+	id, err := strconv.ParseUint(getParam(r, "thread"), 10, 64)
+	if err != nil {
+		return aerrNoThread
+	}
+	board, err := db.GetPostBoard(id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return aerrNoThread
+	default:
+		return aerrInternal.Hide(err)
+	}
+	ss, _ := getSession(r, board)
+	if ss == nil {
+		return aerrNotLoggedIn
+	}
+	if !checkModOnly(board, ss) {
+		return aerrNoThread
+	}
+	valid, err := db.ValidateOP(id, board)
+	switch {
+	case err != nil:
+		return aerrInternal.Hide(err)
+	case !valid:
+		return aerrNoThread
+	}
+	if err := fn(ss.UserID, id); err != nil {
+		return aerrInternal.Hide(err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cutechan/cutechan/go/db"
+	. "github.com/cutechan/cutechan/go/test"
+)
+
+func TestWatchThread(t *testing.T) {
+	assertTableClear(t, "accounts", "boards")
+	writeSampleBoard(t)
+	writeSampleThread(t)
+	writeSampleReply(t, 2, "::1")
+	writeSampleUser(t)
+
+	cases := [...]struct {
+		name, method, url string
+		loggedIn          bool
+		code              int
+		err               error
+	}{
+		{"not logged in", "POST", "/api/watch/1", false, 403, aerrNotLoggedIn},
+		{"malformed ID", "POST", "/api/watch/www", true, 404, aerrNoThread},
+		{"nonexistent post", "POST", "/api/watch/99", true, 404, aerrNoThread},
+		{"not a thread", "POST", "/api/watch/2", true, 404, aerrNoThread},
+		{"unwatch not logged in", "DELETE", "/api/watch/1", false, 403, aerrNotLoggedIn},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(c.method, c.url, nil)
+			if c.loggedIn {
+				setLoginCookies(req, sampleLoginCreds)
+			}
+			testRouter.ServeHTTP(rec, req)
+			assertCode(t, rec, c.code)
+			assertBody(t, rec, string(marshalJSON(t, c.err)))
+		})
+	}
+}
+
+func TestWatchedThreads(t *testing.T) {
+	assertTableClear(t, "accounts", "boards")
+	writeSampleBoard(t)
+	writeSampleThread(t)
+	writeSampleUser(t)
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, nil)
+		setLoginCookies(req, sampleLoginCreds)
+		testRouter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, httptest.NewRequest("GET", "/api/watch", nil))
+	assertCode(t, rec, 403)
+
+	assertCode(t, serve("POST", "/api/watch/1"), 200)
+	// Watching twice is no error
+	assertCode(t, serve("POST", "/api/watch/1"), 200)
+
+	rec = serve("GET", "/api/watch")
+	assertCode(t, rec, 200)
+	var threads []db.WatchedThread
+	if err := json.Unmarshal(rec.Body.Bytes(), &threads); err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 {
+		t.Fatalf("expected 1 watched thread, got %d", len(threads))
+	}
+	AssertDeepEquals(t, threads[0].ID, uint64(1))
+	AssertDeepEquals(t, threads[0].Board, "a")
+	AssertDeepEquals(t, threads[0].PostCtr, uint32(1))
+
+	assertCode(t, serve("DELETE", "/api/watch/1"), 200)
+	rec = serve("GET", "/api/watch")
+	assertCode(t, rec, 200)
+	assertBody(t, rec, "[]")
+}
@@ -24,6 +24,9 @@ type PostContext struct {
 	TID       uint64
 	Index     bool
 	OP        bool
+	Sticky    bool
+	Locked    bool
+	Deleted   bool
 	HasBoard  bool
 	Board     string
 	Subject   string
@@ -46,6 +49,9 @@ type FileContext struct {
 	HasLength  bool
 	Length     string
 	Record     bool
+	Archive    bool
+	Document   bool
+	Listing    string
 	Size       string
 	TWidth     uint16
 	THeight    uint16
@@ -54,6 +60,9 @@ type FileContext struct {
 	DName      string
 	SourcePath string
 	ThumbPath  string
+	HasSrcset  bool
+	Srcset     string
+	Spoiler    bool
 }
 
 type PostLinkContext struct {
@@ -75,6 +84,9 @@ func MakePostContext(l string, t common.Thread, p *common.Post, bls common.Backl
 		TID:       t.ID,
 		Index:     index,
 		OP:        t.ID == p.ID,
+		Sticky:    t.ID == p.ID && t.Sticky,
+		Locked:    t.ID == p.ID && t.Locked,
+		Deleted:   p.Deleted,
 		HasBoard:  t.ID == p.ID && index && all,
 		Board:     t.Board,
 		Subject:   t.Subject,
@@ -97,6 +109,18 @@ func (ctx *PostContext) PostClass() string {
 	if ctx.OP {
 		classes = append(classes, "post_op")
 	}
+	if ctx.Sticky {
+		classes = append(classes, "post_sticky")
+	}
+	if ctx.Locked {
+		classes = append(classes, "post_locked")
+	}
+	if ctx.Deleted {
+		classes = append(classes, "post_deleted")
+	}
+	if ctx.post.Editing {
+		classes = append(classes, "post_editing")
+	}
 	if len(ctx.post.Files) > 0 {
 		classes = append(classes, "post_file")
 		if len(ctx.post.Files) > 1 {
@@ -194,6 +218,9 @@ func (ctx *PostContext) renderFile(img *common.Image, n int) string {
 		HasLength:  img.Video || img.Audio,
 		Length:     duration(img.Length),
 		Record:     img.Audio && !img.Video,
+		Archive:    common.IsArchive(img.FileType),
+		Document:   img.FileType == common.PDF || common.IsArchive(img.FileType),
+		Listing:    archiveListing(img.Contents),
 		Size:       fileSize(ctx.Lang, img.Size),
 		Width:      img.Dims[0],
 		Height:     img.Dims[1],
@@ -202,6 +229,9 @@ func (ctx *PostContext) renderFile(img *common.Image, n int) string {
 		DName:      ctx.getDownloadName(n, img),
 		SourcePath: file.SourcePath(img.FileType, img.SHA1),
 		ThumbPath:  file.ThumbPath(img.ThumbType, img.SHA1),
+		HasSrcset:  len(img.Variants) != 0,
+		Srcset:     variantSrcset(img.ImageCommon),
+		Spoiler:    img.Spoiler,
 	}
 	return renderMustache("post-file", &fileCtx)
 }
@@ -117,6 +117,20 @@ var specs = map[string][]inputSpec{
 			ID:   "kpopnetRootOverride",
 			Type: _string,
 		},
+		{
+			ID: "metadataSummary",
+		},
+		{
+			ID:   "deletedRetention",
+			Type: _number,
+			Min:  0,
+		},
+		{
+			ID:   "phashThreshold",
+			Type: _number,
+			Min:  1,
+			Max:  64,
+		},
 	},
 }
 
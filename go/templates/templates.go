@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/lang"
 
@@ -54,13 +55,37 @@ func Thread(
 	p Params,
 	id uint64,
 	board, title string,
-	abbrev bool,
+	abbrev, archived, locked bool,
 	postHTML []byte,
 ) []byte {
-	html := renderThread(postHTML, id, p.Lang, board, title)
+	loggedIn := p.Session != nil
+	html := renderThread(postHTML, id, p.Lang, board, title, archived, locked, loggedIn)
 	return Page(p, title, html, true)
 }
 
+func Archive(
+	p Params,
+	board, title string,
+	page int,
+	threads common.Board,
+	more bool,
+) []byte {
+	html := renderArchive(p.Lang, board, title, page, threads, more)
+	return Page(p, title, html, false)
+}
+
+func Search(
+	p Params,
+	board, query string,
+	page int,
+	results []common.SearchResult,
+	more bool,
+) []byte {
+	html := renderSearch(p.Lang, board, query, page, results, more)
+	title := lang.Get(p.Lang, "searchResults")
+	return Page(p, title, html, false)
+}
+
 func Landing(p Params) []byte {
 	title := lang.Get(p.Lang, "main")
 	html := renderLanding(p.Lang)
@@ -73,14 +98,28 @@ func Stickers(p Params, stickHTML []byte) []byte {
 	return Page(p, title, html, false)
 }
 
+func Banned(
+	p Params,
+	board string,
+	ban auth.BanRecord,
+	banned, appealed, rejected bool,
+) []byte {
+	html := renderBanned(p.Lang, board, ban, banned, appealed, rejected)
+	title := lang.Get(p.Lang, "banned")
+	return Page(p, title, html, false)
+}
+
 func Admin(
 	p Params,
 	cs config.BoardConfigs,
+	owned []string,
 	staff auth.Staff,
 	bans auth.BanRecords,
 	log auth.ModLogRecords,
+	reports auth.Reports,
+	appeals auth.Appeals,
 ) []byte {
-	html := renderAdmin(cs, staff, bans, log)
+	html := renderAdmin(cs, owned, staff, bans, log, reports, appeals)
 	title := lang.Get(p.Lang, "Admin")
 	return Page(p, title, html, false)
 }
@@ -1,12 +1,14 @@
 package templates
 
 import (
+	"fmt"
 	"html"
 	"strings"
 	"time"
 
 	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/file"
 )
 
 func posClasses(pos auth.Positions) string {
@@ -28,6 +30,35 @@ func posClasses(pos auth.Positions) string {
 	return strings.Join(classes, " ")
 }
 
+// Build srcset of WebP thumbnail variants. Returns empty string if
+// image doesn't have any.
+func variantSrcset(img common.ImageCommon) string {
+	srcs := make([]string, len(img.Variants))
+	for i, v := range img.Variants {
+		srcs[i] = fmt.Sprintf("%s %dw", file.VariantPath(v.Name, img.SHA1), v.Width)
+	}
+	return strings.Join(srcs, ", ")
+}
+
+// Number of archive files shown in the tooltip.
+const maxListingEntries = 20
+
+// Build short plain text listing of archive files.
+func archiveListing(contents []common.ArchiveEntry) string {
+	n := len(contents)
+	if n > maxListingEntries {
+		n = maxListingEntries
+	}
+	names := make([]string, n, n+1)
+	for i := 0; i < n; i++ {
+		names[i] = contents[i].Name
+	}
+	if len(contents) > n {
+		names = append(names, "…")
+	}
+	return strings.Join(names, "\n")
+}
+
 // https://example.com/path -> //example.com
 // https://example.com -> //example.com
 // //example.com/path -> //example.com
@@ -118,3 +149,16 @@ type sortableUInt64 []uint64
 func (a sortableUInt64) Len() int           { return len(a) }
 func (a sortableUInt64) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a sortableUInt64) Less(i, j int) bool { return a[i] < a[j] }
+
+// Search results are rendered as standalone index posts, linking to their
+// threads.
+func makeSearchResultContext(l string, r common.SearchResult, all bool) PostContext {
+	t := common.Thread{
+		Board:   r.Board,
+		Subject: r.Subject,
+		Post:    &common.Post{ID: r.OP},
+	}
+	ctx := MakePostContext(l, t, &r.Post, nil, true, all)
+	ctx.HasBoard = all
+	return ctx
+}
@@ -16,7 +16,9 @@ import (
 
 	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/feeds"
 	"github.com/cutechan/cutechan/go/parser"
 )
 
@@ -26,8 +28,16 @@ var (
 	errInvalidImageToken = errors.New("invalid image token")
 	errNoTextOrFiles     = errors.New("no text or files")
 	errTooManyLines      = errors.New("too many lines in post body")
+	errThreadArchived    = errors.New("thread archived")
+	errThreadLocked      = errors.New("thread locked")
+	errTooManyFiles      = errors.New("too many files")
+	errFileTypeForbidden = errors.New("file type not allowed on board")
 )
 
+// Verifies post signature. Replaced in tests, as valid signatures are only
+// produced by the client.
+var verifySign = checkSign
+
 // ThreadCreationRequest contains data for creating a new thread.
 type ThreadCreationRequest struct {
 	PostCreationRequest
@@ -46,6 +56,9 @@ type PostCreationRequest struct {
 	ShowBadge    bool
 	ShowName     bool
 	Session      *auth.Session
+	// Allocate an open post, editable in real time
+	Open     bool
+	Password string
 }
 
 type FilesRequest struct {
@@ -56,7 +69,7 @@ type FilesRequest struct {
 func CreateThread(req ThreadCreationRequest) (
 	post db.Post, err error,
 ) {
-	ok := db.CanCreateThread(req.Ip)
+	ok := db.CanCreateThread(req.Ip, config.GetThreadCooldown(req.Board))
 	if !ok {
 		err = errPostingTooFast
 		return
@@ -90,6 +103,10 @@ func CreateThread(req ThreadCreationRequest) (
 	}
 
 	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	go feeds.NotifyReplies(post.ID, post.OP, post.Board, post.Account, post.Links)
 	return
 }
 
@@ -97,7 +114,7 @@ func CreateThread(req ThreadCreationRequest) (
 func CreatePost(req PostCreationRequest, op uint64) (
 	post db.Post, msg []byte, err error,
 ) {
-	ok := db.CanCreatePost(req.Ip)
+	ok := db.CanCreatePost(req.Ip, config.GetPostCooldown(req.Board))
 	if !ok {
 		err = errPostingTooFast
 		return
@@ -109,6 +126,18 @@ func CreatePost(req PostCreationRequest, op uint64) (
 	}
 	defer db.RollbackOnError(tx, &err)
 
+	archived, locked, err := db.GetThreadState(tx, op)
+	switch {
+	case err != nil:
+		return
+	case archived:
+		err = errThreadArchived
+		return
+	case locked:
+		err = errThreadLocked
+		return
+	}
+
 	post, err = constructPost(tx, req)
 	if err != nil {
 		return
@@ -131,21 +160,33 @@ func CreatePost(req PostCreationRequest, op uint64) (
 	}
 
 	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	// Links of open posts are only final, once the post is closed
+	if !post.Editing {
+		go feeds.NotifyReplies(post.ID, post.OP, post.Board, post.Account, post.Links)
+	}
 	return
 }
 
 // Construct the common parts of the new post.
 func constructPost(tx *sql.Tx, req PostCreationRequest) (post db.Post, err error) {
-	if req.Body == "" && len(req.FilesRequest.Tokens) == 0 {
+	if !req.Open && req.Body == "" && len(req.FilesRequest.Tokens) == 0 {
 		err = errNoTextOrFiles
 		return
 	}
+	if len(req.FilesRequest.Tokens) > config.GetMaxFiles(req.Board) {
+		err = errTooManyFiles
+		return
+	}
 
 	post = db.Post{
 		StandalonePost: common.StandalonePost{
 			Post: common.Post{
-				Time: time.Now().Unix(),
-				Body: req.Body,
+				Editing: req.Open,
+				Time:    time.Now().Unix(),
+				Body:    req.Body,
 			},
 			Board: req.Board,
 		},
@@ -157,7 +198,7 @@ func constructPost(tx *sql.Tx, req PostCreationRequest) (post db.Post, err error
 	if err != nil {
 		return
 	}
-	if !checkSign(req.Token, req.Sign) {
+	if !verifySign(req.Token, req.Sign) {
 		err = errBadSignature
 		return
 	}
@@ -174,6 +215,7 @@ func constructPost(tx *sql.Tx, req PostCreationRequest) (post db.Post, err error
 
 	ss := req.Session
 	if ss != nil {
+		post.Account = ss.UserID
 		// Attach staff badge if requested after validation.
 		if req.ShowBadge {
 			if ss.Positions.CurBoard >= auth.Moderator {
@@ -187,12 +229,29 @@ func constructPost(tx *sql.Tx, req PostCreationRequest) (post db.Post, err error
 		}
 	}
 
+	// Open posts are reclaimable after disconnect with their password.
+	if req.Open {
+		post.Password, err = auth.BcryptHash(req.Password, 6)
+		if err != nil {
+			return
+		}
+	}
+
 	post.Links, post.Commands, err = parser.ParseBody([]byte(req.Body))
 	if err != nil {
 		return
 	}
 
 	err = setPostFiles(tx, &post, req.FilesRequest)
+	if err != nil {
+		return
+	}
+	for _, img := range post.Files {
+		if !config.IsAllowedFileType(req.Board, img.FileType) {
+			err = errFileTypeForbidden
+			return
+		}
+	}
 	return
 }
 
@@ -221,7 +280,7 @@ func setPostFiles(tx *sql.Tx, post *db.Post, freq FilesRequest) (err error) {
 }
 
 func getImage(tx *sql.Tx, token string) (img *common.Image, err error) {
-	imgCommon, err := db.UseImageToken(tx, token)
+	image, err := db.UseImageToken(tx, token)
 	switch err {
 	case nil:
 	case db.ErrInvalidToken:
@@ -230,6 +289,6 @@ func getImage(tx *sql.Tx, token string) (img *common.Image, err error) {
 	default:
 		return
 	}
-	img = &common.Image{ImageCommon: imgCommon}
+	img = &image
 	return
 }
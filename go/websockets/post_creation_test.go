@@ -1,6 +1,8 @@
 package websockets
 
 import (
+	"database/sql"
+	"fmt"
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
@@ -12,170 +14,80 @@ import (
 )
 
 var (
-	// JPEG sample image standard struct
+	// JPEG sample image standard struct. Empty lists as they are read back
+	// from the DB.
 	stdJPEG = common.ImageCommon{
-		SHA1:     "012a2f912c9ee93ceb0ccb8684a29ec571990a94",
-		FileType: 0,
-		Dims:     [4]uint16{1, 1, 1, 1},
-		MD5:      "YOQQklgfezKbBXuEAsqopw",
-		Size:     300792,
+		SHA1:      "012a2f912c9ee93ceb0ccb8684a29ec571990a94",
+		FileType:  common.JPEG,
+		ThumbType: common.JPEG,
+		Dims:      [4]uint16{1, 1, 1, 1},
+		MD5:       "YOQQklgfezKbBXuEAsqopw",
+		Size:      300792,
+		Variants:  []common.ThumbVariant{},
+		Contents:  []common.ArchiveEntry{},
 	}
 )
 
 func TestInsertThread(t *testing.T) {
 	assertTableClear(t, "boards", "images")
-	if err := db.SetPostCounter(5); err != nil {
-		t.Fatal(err)
-	}
+	writeSampleBoard(t)
+	setBoardConfigs(t)
+	writeSampleImage(t)
 
-	conf := [...]db.BoardConfigs{
-		{
-			BoardConfigs: config.BoardConfigs{
-				ID: "c",
-			},
-		},
-		{
-			BoardConfigs: config.BoardConfigs{
-				ID: "r",
-				BoardPublic: config.BoardPublic{
-					Title: "123",
+	newReq := func(sign string) ThreadCreationRequest {
+		return ThreadCreationRequest{
+			PostCreationRequest: PostCreationRequest{
+				FilesRequest: FilesRequest{
+					Tokens: []string{newImageToken(t)},
 				},
+				Board: "a",
+				Ip:    "::1",
+				Body:  "foo",
+				Token: newPostToken(t, "::1"),
+				Sign:  sign,
 			},
-		},
-	}
-	for _, c := range conf {
-		if _, err := config.SetBoardConfigs(c.BoardConfigs); err != nil {
-			t.Fatal(err)
-		}
-		if err := db.WriteBoard(nil, c); err != nil {
-			t.Fatal(err)
+			Subject: "subject",
 		}
 	}
 
-	cases := [...]struct {
-		name, board string
-		err         error
-	}{
-		{"invalid board", "all", errInvalidBoard},
-		{"invalid board", "x", errInvalidBoard},
+	if _, err := CreateThread(newReq("bad")); err != errBadSignature {
+		UnexpectedError(t, err)
 	}
 
-	for i := range cases {
-		c := cases[i]
-		t.Run(c.name, func(t *testing.T) {
-			t.Parallel()
-
-			req := ThreadCreationRequest{
-				Board: c.board,
-			}
-			if _, err := CreateThread(req, ""); err != c.err {
-				UnexpectedError(t, err)
-			}
-		})
-	}
-
-	t.Run("with image", testCreateThread)
-}
-
-func testCreateThread(t *testing.T) {
-	writeSampleImage(t)
-	token, err := db.NewImageToken(stdJPEG.SHA1)
+	post, err := CreateThread(newReq(testSign))
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	std := common.Thread{
-		Board:    "c",
-		Subject:  "subject",
-		ImageCtr: 1,
-		PostCtr:  1,
-		Post: common.Post{
-			ID:   6,
-			Name: "name",
-			Image: &common.Image{
-				Spoiler:     true,
-				ImageCommon: stdJPEG,
-				Name:        "foo",
-			},
-		},
-		Posts: []common.Post{},
-	}
-
-	req := ThreadCreationRequest{
-		ReplyCreationRequest: ReplyCreationRequest{
-			Name:     "name",
-			Password: "123",
-			Image: ImageRequest{
-				Name:    "foo.jpeg",
-				Token:   token,
-				Spoiler: true,
-			},
-		},
-		Subject: "subject",
-		Board:   "c",
-	}
-	if _, err := CreateThread(req, "::1"); err != nil {
-		t.Fatal(err)
-	}
-
-	thread, err := db.GetThread(6, 0)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Pointers have to be dereferenced to be asserted
-	AssertDeepEquals(t, *thread.Image, *std.Image)
-
-	// Normalize timestamps and pointer fields
-	then := thread.ReplyTime
-	std.ReplyTime = then
-	std.BumpTime = then
-	std.Time = then
-	std.Image = thread.Image
-
-	AssertDeepEquals(t, thread, std)
-}
-
-func setBoardConfigs(t testing.TB) {
-	config.ClearBoards()
-	_, err := config.SetBoardConfigs(config.BoardConfigs{
-		ID: "a",
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-}
-
-func assertIP(t *testing.T, id uint64, ip string) {
-	res, err := db.GetIP(id)
+	thread, err := db.GetThread(post.ID, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	AssertDeepEquals(t, res, ip)
+	AssertDeepEquals(t, thread.ID, post.ID)
+	AssertDeepEquals(t, thread.Board, "a")
+	AssertDeepEquals(t, thread.Subject, "subject")
+	AssertDeepEquals(t, thread.Body, "foo")
+	AssertDeepEquals(t, thread.PostCtr, uint32(1))
+	AssertDeepEquals(t, thread.ImageCtr, uint32(1))
+	assertFiles(t, thread.Files, stdJPEG)
+	assertIP(t, post.ID, "::1")
 }
 
 func TestGetInvalidImage(t *testing.T) {
 	assertTableClear(t, "images")
 
-	const (
-		name  = "foo.jpeg"
-		token = "dasdasd-ad--dsad-ads-d-ad-"
-	)
-
 	cases := [...]struct {
-		testName, token, name string
-		err                   error
+		name, token string
 	}{
-		{"token too long", GenString(128), name, errInvalidImageToken},
-		{"image name too long", token, GenString(201), errImageNameTooLong},
-		{"no token in DB", token, name, errInvalidImageToken},
+		{"token too long", GenString(128)},
+		{"token too short", GenString(common.LenImageToken - 1)},
+		{"no token in DB", GenString(common.LenImageToken)},
 	}
 
 	for i := range cases {
 		c := cases[i]
-		t.Run(c.testName, func(t *testing.T) {
+		t.Run(c.name, func(t *testing.T) {
 			t.Parallel()
-			if _, err := getImage(c.token, c.name, false); err != c.err {
+			if _, err := getImage(nil, c.token); err != errInvalidImageToken {
 				UnexpectedError(t, err)
 			}
 		})
@@ -188,15 +100,13 @@ func TestClosePreviousPostOnCreation(t *testing.T) {
 	writeSampleBoard(t)
 	writeSampleThread(t)
 	writeSamplePost(t)
-	if err := db.SetPostCounter(5); err != nil {
-		t.Fatal(err)
-	}
 	setBoardConfigs(t)
 
 	sv := newWSServer(t)
 	defer sv.Close()
-	cl, wcl := sv.NewClient()
+	cl, _ := sv.NewClient()
 	registerClient(t, cl, 1, "a")
+	defer cl.Close(nil)
 	cl.post = openPost{
 		id:    2,
 		op:    1,
@@ -206,130 +116,217 @@ func TestClosePreviousPostOnCreation(t *testing.T) {
 		body:  []byte("abc"),
 	}
 	data := marshalJSON(t, ReplyCreationRequest{
-		Name:     "name",
 		Body:     "foo",
 		Password: "123",
+		Token:    newPostToken(t, cl.ip),
+		Sign:     testSign,
 	})
 
 	if err := cl.insertPost(data); err != nil {
 		t.Fatal(err)
 	}
 
-	assertMessage(t, wcl, `326`)
 	assertPostClosed(t, 2)
+	assertBody(t, 2, "abc")
+	if cl.post.id == 0 || cl.post.id == 2 {
+		t.Fatalf("new post not opened: %d", cl.post.id)
+	}
+	post, err := db.GetPost(cl.post.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, post.Editing, true)
+	AssertDeepEquals(t, post.Body, "foo")
 }
 
-func TestPostCreationValidations(t *testing.T) {
+func TestPostCreation(t *testing.T) {
+	feeds.Clear()
+	assertTableClear(t, "boards", "images")
+	writeSampleBoard(t)
+	writeSampleThread(t)
 	setBoardConfigs(t)
+	writeSampleImage(t)
 
 	sv := newWSServer(t)
 	defer sv.Close()
-	cl, _ := sv.NewClient()
+	cl, wcl := sv.NewClient()
 	registerClient(t, cl, 1, "a")
+	defer cl.Close(nil)
 
-	cases := [...]struct {
-		testName, token, name string
-	}{
-		{"no token", "", "abc"},
-		{"no image name", "abc", ""},
+	req := ReplyCreationRequest{
+		Body:     "Δ",
+		Password: "123",
+		Token:    newPostToken(t, cl.ip),
+		Sign:     testSign,
+		Files: FilesRequest{
+			Tokens: []string{newImageToken(t)},
+		},
+	}
+	if err := cl.insertPost(marshalJSON(t, req)); err != nil {
+		t.Fatal(err)
 	}
 
-	for i := range cases {
-		c := cases[i]
-		t.Run(c.testName, func(t *testing.T) {
-			t.Parallel()
+	id := cl.post.id
+	assertMessage(
+		t,
+		wcl,
+		encodeMessageType(common.MessagePostID)+strconv.FormatUint(id, 10),
+	)
 
-			req := ReplyCreationRequest{
-				Image: ImageRequest{
-					Name:  c.name,
-					Token: c.token,
-				},
-			}
-			err := cl.insertPost(marshalJSON(t, req))
-			if err != errNoTextOrImage {
-				UnexpectedError(t, err)
-			}
-		})
+	post, err := db.GetPost(id)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
+	AssertDeepEquals(t, post.Editing, true)
+	AssertDeepEquals(t, post.Body, "Δ")
+	AssertDeepEquals(t, post.OP, uint64(1))
+	AssertDeepEquals(t, post.Board, "a")
+	assertFiles(t, post.Files, stdJPEG)
+	assertIP(t, id, cl.ip)
 
-func TestPostCreation(t *testing.T) {
-	feeds.Clear()
-	prepareForPostCreation(t)
-	setBoardConfigs(t)
-	writeSampleImage(t)
-	token, err := db.NewImageToken(stdJPEG.SHA1)
+	thread, err := db.GetThread(1, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
+	AssertDeepEquals(t, thread.PostCtr, uint32(2))
+	AssertDeepEquals(t, thread.ImageCtr, uint32(1))
 
-	sv := newWSServer(t)
+	AssertDeepEquals(t, cl.post, openPost{
+		id:       id,
+		op:       1,
+		time:     post.Time,
+		board:    "a",
+		len:      1,
+		hasImage: true,
+		body:     []byte("Δ"),
+	})
+}
+
+func BenchmarkPostCreation(b *testing.B) {
+	feeds.Clear()
+	assertTableClear(b, "boards")
+	writeSampleBoard(b)
+	writeSampleThread(b)
+	setBoardConfigs(b)
+
+	sv := newWSServer(b)
 	defer sv.Close()
-	cl, wcl := sv.NewClient()
-	cl.ip = "::1"
-	registerClient(t, cl, 1, "a")
+	cl, _ := sv.NewClient()
+	registerClient(b, cl, 1, "a")
 	defer cl.Close(nil)
 
 	req := ReplyCreationRequest{
-		Open:     true,
-		Body:     "Δ",
+		Body:     "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.",
 		Password: "123",
-		Image: ImageRequest{
-			Name:    "foo.jpeg",
-			Token:   token,
-			Spoiler: true,
-		},
+		Sign:     testSign,
 	}
 
-	if err := cl.insertPost(marshalJSON(t, req)); err != nil {
-		t.Fatal(err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Avoid posting cooldown and post token limits
+		b.StopTimer()
+		cl.ip = fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		req.Token = newPostToken(b, cl.ip)
+		b.StartTimer()
+
+		if err := cl.insertPost(marshalJSON(b, req)); err != nil {
+			b.Fatal(err)
+		}
+		if err := cl.closePost(); err != nil {
+			b.Fatal(err)
+		}
 	}
+}
 
-	assertMessage(t, wcl, encodeMessageType(common.MessagePostID)+"6")
-
-	stdPost := common.StandalonePost{
-		Post: common.Post{
-			Editing: true,
-			ID:      6,
-			Body:    "Δ",
-			Image: &common.Image{
-				Name:        "foo",
-				Spoiler:     true,
-				ImageCommon: stdJPEG,
-			},
-		},
-		OP:    1,
-		Board: "a",
+// Allocate a post token for the IP. Valid with testSign.
+func newPostToken(t testing.TB, ip string) string {
+	token, err := db.NewPostToken(ip)
+	if err != nil {
+		t.Fatal(err)
 	}
+	return token
+}
 
-	post, err := db.GetPost(6)
+func newImageToken(t testing.TB) string {
+	token, err := db.NewImageToken(stdJPEG.SHA1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	AssertDeepEquals(t, *post.Image, *stdPost.Image)
-	stdPost.Image = post.Image
-	stdPost.Time = post.Time
-	AssertDeepEquals(t, post, stdPost)
+	return token
+}
 
-	assertIP(t, 6, "::1")
+func writeSampleImage(t testing.TB) {
+	if err := db.WriteImage(nil, stdJPEG); err != nil {
+		t.Fatal(err)
+	}
+}
 
-	thread, err := db.GetThread(1, 0)
+// Assert the post has exactly the passed image attached
+func assertFiles(t *testing.T, files common.Files, img common.ImageCommon) {
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+	AssertDeepEquals(t, files[0].ImageCommon, img)
+}
+
+func setBoardConfigs(t testing.TB) {
+	err := config.SetBoardConfig(config.BoardConfig{
+		BoardPublic: config.BoardPublic{
+			ID:          "a",
+			LiveEditing: true,
+			MaxFiles:    1,
+		},
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	AssertDeepEquals(t, thread.PostCtr, uint32(1))
-	AssertDeepEquals(t, thread.ImageCtr, uint32(2))
+}
 
-	AssertDeepEquals(t, cl.post, openPost{
-		id:          6,
-		op:          1,
-		time:        stdPost.Time,
-		board:       "a",
-		len:         1,
-		hasImage:    true,
-		isSpoilered: true,
-		body:        []byte("Δ"),
-	})
+func assertIP(t *testing.T, id uint64, ip string) {
+	res, err := db.GetIP(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, res, ip)
+}
+
+func TestPostCreationValidations(t *testing.T) {
+	setBoardConfigs(t)
+
+	cases := [...]struct {
+		name string
+		req  PostCreationRequest
+		err  error
+	}{
+		{
+			name: "no text or files",
+			req: PostCreationRequest{
+				Board: "a",
+			},
+			err: errNoTextOrFiles,
+		},
+		{
+			name: "too many files",
+			req: PostCreationRequest{
+				Board: "a",
+				FilesRequest: FilesRequest{
+					Tokens: []string{"a", "b"},
+				},
+			},
+			err: errTooManyFiles,
+		},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := constructPost(nil, c.req); err != c.err {
+				UnexpectedError(t, err)
+			}
+		})
+	}
 }
 
 func registerClient(t testing.TB, cl *Client, id uint64, board string) {
@@ -344,18 +341,9 @@ func encodeMessageType(typ common.MessageType) string {
 	return strconv.Itoa(int(typ))
 }
 
-func prepareForPostCreation(t testing.TB) {
-	assertTableClear(t, "boards", "images")
-	writeSampleBoard(t)
-	writeSampleThread(t)
-	if err := db.SetPostCounter(5); err != nil {
-		t.Fatal(err)
-	}
-}
-
 func writeSampleBoard(t testing.TB) {
-	b := db.BoardConfigs{
-		BoardConfigs: config.BoardConfigs{
+	b := config.BoardConfig{
+		BoardPublic: config.BoardPublic{
 			ID: "a",
 		},
 	}
@@ -365,51 +353,40 @@ func writeSampleBoard(t testing.TB) {
 }
 
 func writeSampleThread(t testing.TB) {
-	now := time.Now().Unix()
-	thread := db.Thread{
-		ID:        1,
-		Board:     "a",
-		PostCtr:   0,
-		ImageCtr:  1,
-		ReplyTime: now,
-	}
-	op := db.Post{
+	writeThread(t, db.Post{
 		StandalonePost: common.StandalonePost{
 			Post: common.Post{
 				ID:   1,
 				Time: time.Now().Unix(),
 			},
-			OP: 1,
+			OP:    1,
+			Board: "a",
 		},
-	}
-	if err := db.WriteThread(nil, thread, op); err != nil {
-		t.Fatal(err)
-	}
+	})
 }
 
-func BenchmarkPostCreation(b *testing.B) {
-	feeds.Clear()
-	prepareForPostCreation(b)
-	setBoardConfigs(b)
+// Write a thread with the passed OP
+func writeThread(t testing.TB, op db.Post) {
+	inTx(t, func(tx *sql.Tx) error {
+		return db.InsertThread(tx, op, "")
+	})
+}
 
-	sv := newWSServer(b)
-	defer sv.Close()
-	cl, _ := sv.NewClient()
-	registerClient(b, cl, 1, "a")
-	defer cl.Close(nil)
+// Write a reply into an existing thread
+func writePost(t testing.TB, p db.Post) {
+	inTx(t, func(tx *sql.Tx) error {
+		return db.InsertPost(tx, p)
+	})
+}
 
-	req := ReplyCreationRequest{
-		Body:     "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.",
-		Password: "123",
+func inTx(t testing.TB, fn func(tx *sql.Tx) error) {
+	tx, err := db.BeginTx()
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		if err := cl.insertPost(marshalJSON(b, req)); err != nil {
-			b.Fatal(err)
-		}
-		if err := cl.closePost(); err != nil {
-			b.Fatal(err)
-		}
+	err = fn(tx)
+	db.EndTx(tx, &err)
+	if err != nil {
+		t.Fatal(err)
 	}
 }
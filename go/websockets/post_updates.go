@@ -0,0 +1,316 @@
+// Open post editing message handlers
+
+package websockets
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cutechan/cutechan/go/auth"
+	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/config"
+	"github.com/cutechan/cutechan/go/db"
+	"github.com/cutechan/cutechan/go/feeds"
+)
+
+// Open posts are closed automatically after this period of time. Must be kept
+// in sync with get_expired_open_posts.sql.
+const openPostTimeout = 15 * time.Minute
+
+var (
+	errBanned              = errors.New("you are banned")
+	errNoPostOpen          = errors.New("no post open")
+	errEmptyPost           = errors.New("post body empty")
+	errInvalidSpliceCoords = errors.New("invalid splice coordinates")
+	errSpliceTooLong       = errors.New("splice text too long")
+	errSpliceNOOP          = errors.New("splice NOOP")
+	errLiveEditingDisabled = errors.New("live editing disabled on board")
+)
+
+// Post currently being edited by the client
+type openPost struct {
+	hasImage bool
+	len      int
+	id, op   uint64
+	time     int64
+	board    string
+	body     []byte
+}
+
+// ReplyCreationRequest contains data for allocating an open post in a thread.
+type ReplyCreationRequest struct {
+	Body      string
+	Token     string
+	Sign      string
+	Password  string
+	ShowBadge bool
+	ShowName  bool
+	Files     FilesRequest
+}
+
+// Request or response to replace the current line's text starting at an exact
+// position in the current line
+type spliceRequest struct {
+	spliceCoords
+	Text string `json:"text"`
+}
+
+// Coordinates of the replaced text in a splice request
+type spliceCoords struct {
+	Start uint `json:"start"`
+	Len   uint `json:"len"`
+}
+
+// Allocate a new open post in the thread the client is synced to and
+// propagate it to other clients.
+func (c *Client) insertPost(data []byte) (err error) {
+	// Only one post can be open at a time
+	if c.post.id != 0 {
+		if err = c.closePost(); err != nil {
+			return
+		}
+	}
+
+	var req ReplyCreationRequest
+	err = decodeMessage(data, &req)
+	if err != nil {
+		return
+	}
+
+	_, op, board := feeds.GetSync(c)
+	switch {
+	case op == 0 || c.feed == nil:
+		return errInvalidThread
+	case !config.IsLiveEditingBoard(board):
+		return errLiveEditingDisabled
+	case config.IsReadOnlyBoard(board), config.IsModOnlyBoard(board):
+		return errInvalidBoard
+	case auth.IsBanned(board, c.ip):
+		return errBanned
+	case len(req.Password) > common.MaxLenPassword:
+		return common.ErrTooLong("password")
+	}
+
+	var ss *auth.Session
+	if c.sessionToken != "" {
+		ss, err = db.GetSession(board, c.sessionToken)
+		switch err {
+		case nil:
+		case common.ErrInvalidCreds:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	post, msg, err := CreatePost(PostCreationRequest{
+		FilesRequest: req.Files,
+		Board:        board,
+		Ip:           c.ip,
+		Body:         req.Body,
+		Token:        req.Token,
+		Sign:         req.Sign,
+		ShowBadge:    req.ShowBadge,
+		ShowName:     req.ShowName,
+		Session:      ss,
+		Open:         true,
+		Password:     req.Password,
+	}, op)
+	if err != nil {
+		return
+	}
+
+	body := []byte(post.Body)
+	feeds.InsertOpenPost(post.StandalonePost, body, msg)
+	c.post = openPost{
+		hasImage: len(post.Files) > 0,
+		len:      utf8.RuneCount(body),
+		id:       post.ID,
+		op:       op,
+		time:     post.Time,
+		board:    board,
+		body:     body,
+	}
+	return c.sendMessage(common.MessagePostID, post.ID)
+}
+
+// Returns, if the client has an open post. Posts, that have been open for
+// longer than openPostTimeout, are closed.
+func (c *Client) hasPost() (bool, error) {
+	switch {
+	case c.post.id == 0:
+		return false, errNoPostOpen
+	case c.post.time < time.Now().Add(-openPostTimeout).Unix():
+		return false, c.closePost()
+	}
+	return true, nil
+}
+
+// Append a rune to the body of the open post
+func (c *Client) appendRune(data []byte) (err error) {
+	has, err := c.hasPost()
+	switch {
+	case err != nil:
+		return
+	case !has:
+		return
+	case c.post.len+1 > common.MaxLenBody:
+		return common.ErrBodyTooLong
+	}
+
+	var char rune
+	err = decodeMessage(data, &char)
+	switch {
+	case err != nil:
+		return
+	case char == 0:
+		return common.ErrContainsNull
+	case char == '\n':
+		if bytes.Count(c.post.body, []byte{'\n'}) >= common.MaxLinesBody {
+			return errTooManyLines
+		}
+	}
+
+	msg, err := common.EncodeMessage(
+		common.MessageAppend,
+		[2]uint64{c.post.id, uint64(char)},
+	)
+	if err != nil {
+		return
+	}
+
+	c.post.body = append(c.post.body, string(char)...)
+	c.post.len++
+	return c.updateBody(msg)
+}
+
+// Remove one character from the end of the line in the open post
+func (c *Client) backspace() error {
+	has, err := c.hasPost()
+	switch {
+	case err != nil:
+		return err
+	case !has:
+		return nil
+	case c.post.len == 0:
+		return errEmptyPost
+	}
+
+	msg := common.PrependMessageType(
+		common.MessageBackspace,
+		strconv.AppendUint(nil, c.post.id, 10),
+	)
+
+	_, lastRuneLen := utf8.DecodeLastRune(c.post.body)
+	c.post.body = c.post.body[:len(c.post.body)-lastRuneLen]
+	c.post.len--
+	return c.updateBody(msg)
+}
+
+// Splice the text in the open post. This call is also used for text pastes.
+func (c *Client) spliceText(data []byte) error {
+	if has, err := c.hasPost(); err != nil {
+		return err
+	} else if !has {
+		return nil
+	}
+
+	var req spliceRequest
+	err := decodeMessage(data, &req)
+	if err != nil {
+		return err
+	}
+	text := []rune(req.Text)
+	switch {
+	case req.Len == 0 && len(text) == 0:
+		return errSpliceNOOP
+	case req.Start > uint(c.post.len), req.Start+req.Len > uint(c.post.len):
+		return errInvalidSpliceCoords
+	case len(text) > common.MaxLenBody:
+		return errSpliceTooLong
+	case strings.ContainsRune(req.Text, 0):
+		return common.ErrContainsNull
+	}
+
+	old := []rune(string(c.post.body))
+	end := make([]rune, 0, len(old)-int(req.Start+req.Len)+len(text))
+	end = append(end, text...)
+	end = append(end, old[req.Start+req.Len:]...)
+	body := append(old[:req.Start], end...)
+	switch {
+	case len(body) > common.MaxLenBody:
+		return common.ErrBodyTooLong
+	case countLines(body) > common.MaxLinesBody:
+		return errTooManyLines
+	}
+
+	msg, err := common.EncodeMessage(common.MessageSplice, common.SpliceMessage{
+		ID:    c.post.id,
+		Start: req.Start,
+		Len:   req.Len,
+		Text:  req.Text,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.post.body = []byte(string(body))
+	c.post.len = len(body)
+	return c.updateBody(msg)
+}
+
+// Count the number of line breaks in a rune slice
+func countLines(body []rune) (n int) {
+	for _, r := range body {
+		if r == '\n' {
+			n++
+		}
+	}
+	return
+}
+
+// Write the new body of the open post to the database and propagate the
+// update message to the feed
+func (c *Client) updateBody(msg []byte) error {
+	err := db.SetOpenBody(c.post.id, c.post.body)
+	if err != nil {
+		return err
+	}
+	feeds.SetOpenBody(c.post.id, c.post.op, c.post.body, msg)
+	return nil
+}
+
+// Close an open post, parse its links and commands and persist the final state
+func (c *Client) closePost() (err error) {
+	if c.post.id == 0 {
+		return errNoPostOpen
+	}
+
+	links, commands, err := common.ParseBody(c.post.body)
+	if err != nil {
+		return
+	}
+	err = db.ClosePost(nil, c.post.id, string(c.post.body), links, commands)
+	if err != nil {
+		return
+	}
+
+	msg, err := common.EncodeMessage(common.MessageClosePost, common.CloseMessage{
+		ID:       c.post.id,
+		Links:    links,
+		Commands: commands,
+	})
+	if err != nil {
+		return
+	}
+	feeds.ClosePost(c.post.id, c.post.op, msg)
+
+	go feeds.NotifyReplies(c.post.id, c.post.op, c.post.board, c.account, links)
+
+	c.post = openPost{}
+	return
+}
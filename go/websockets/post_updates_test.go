@@ -118,9 +118,7 @@ func awaitFlush() {
 }
 
 func writeSamplePost(t testing.TB) {
-	if err := db.WritePost(nil, samplePost); err != nil {
-		t.Fatal(err)
-	}
+	writePost(t, samplePost)
 }
 
 func assertOpenPost(t *testing.T, cl *Client, len int, buf string) {
@@ -178,15 +176,15 @@ func TestClosePostWithHashCommand(t *testing.T) {
 	post := db.Post{
 		StandalonePost: common.StandalonePost{
 			Post: common.Post{
-				ID:   2,
-				Body: "#flip",
+				Editing: true,
+				ID:      2,
+				Body:    "#flip",
 			},
-			OP: 1,
+			OP:    1,
+			Board: "a",
 		},
 	}
-	if err := db.WritePost(nil, post); err != nil {
-		t.Fatal(err)
-	}
+	writePost(t, post)
 
 	sv := newWSServer(t)
 	defer sv.Close()
@@ -226,28 +224,23 @@ func TestClosePostWithLinks(t *testing.T) {
 	writeSampleBoard(t)
 	writeSampleThread(t)
 
-	thread := db.Thread{
-		ID:    21,
-		Board: "a",
-	}
-	op := db.Post{
+	writeThread(t, db.Post{
 		StandalonePost: common.StandalonePost{
 			Post: common.Post{
 				ID: 21,
 			},
-			OP: 21,
+			OP:    21,
+			Board: "a",
 		},
-	}
-	if err := db.WriteThread(nil, thread, op); err != nil {
-		t.Fatal(err)
-	}
+	})
 
 	posts := [...]db.Post{
 		{
 			StandalonePost: common.StandalonePost{
 				Post: common.Post{
-					ID:   2,
-					Body: " >>22 ",
+					Editing: true,
+					ID:      2,
+					Body:    " >>22 ",
 				},
 				Board: "a",
 				OP:    1,
@@ -259,14 +252,12 @@ func TestClosePostWithLinks(t *testing.T) {
 					ID: 22,
 				},
 				OP:    21,
-				Board: "c",
+				Board: "a",
 			},
 		},
 	}
 	for _, p := range posts {
-		if err := db.WritePost(nil, p); err != nil {
-			t.Fatal(err)
-		}
+		writePost(t, p)
 	}
 
 	sv := newWSServer(t)
@@ -290,7 +281,7 @@ func TestClosePostWithLinks(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	AssertDeepEquals(t, post.Links, [][2]uint64{{22, 21}})
+	AssertDeepEquals(t, post.Links, common.Links{{22, 21}})
 }
 
 func TestBackspace(t *testing.T) {
@@ -298,10 +289,7 @@ func TestBackspace(t *testing.T) {
 	assertTableClear(t, "boards")
 	writeSampleBoard(t)
 	writeSampleThread(t)
-	err := db.WritePost(nil, samplePost)
-	if err != nil {
-		t.Fatal(err)
-	}
+	writePost(t, samplePost)
 
 	sv := newWSServer(t)
 	defer sv.Close()
@@ -329,9 +317,7 @@ func TestClosePost(t *testing.T) {
 	assertTableClear(t, "boards")
 	writeSampleBoard(t)
 	writeSampleThread(t)
-	if err := db.WritePost(nil, samplePost); err != nil {
-		t.Fatal(err)
-	}
+	writePost(t, samplePost)
 
 	sv := newWSServer(t)
 	defer sv.Close()
@@ -402,7 +388,7 @@ func TestSpliceValidityChecks(t *testing.T) {
 					Start: c.start,
 					Len:   c.len,
 				},
-				Text: []rune(c.text),
+				Text: c.text,
 			}
 			if err := cl.spliceText(marshalJSON(t, req)); err != c.err {
 				UnexpectedError(t, err)
@@ -426,6 +412,7 @@ func TestSplice(t *testing.T) {
 		name                   string
 		start, len             uint
 		text, init, final, log string
+		err                    error
 	}{
 		{
 			name:  "append to empty body",
@@ -473,22 +460,29 @@ func TestSplice(t *testing.T) {
 			log:   `05{"id":2,"start":2,"len":0,"text":"Δ"}`,
 		},
 		{
-			name:  "injection exceeds max body length",
+			name:  "inject long text",
 			start: 1943,
 			len:   0,
 			text:  longSplice,
 			init:  longPost,
-			final: longPost[:1943] + longSplice[:57],
-			log:   `05{"id":2,"start":1943,"len":-1,"text":"Never gonna give you up Never gonna let you down Never go"}`,
+			final: longPost[:1943] + longSplice + longPost[1943:],
 		},
 		{
-			name:  "append exceeds max body length",
+			name:  "append long text",
 			start: 1951,
 			len:   0,
 			text:  longSplice + "\n",
 			init:  longPost,
-			final: longPost + longSplice[:49],
-			log:   `05{"id":2,"start":1951,"len":-1,"text":"Never gonna give you up Never gonna let you down "}`,
+			final: longPost + longSplice + "\n",
+		},
+		{
+			name:  "injection exceeds max body length",
+			start: 0,
+			len:   0,
+			text:  longPost + longSplice,
+			init:  longPost,
+			final: longPost,
+			err:   common.ErrBodyTooLong,
 		},
 	}
 
@@ -509,9 +503,7 @@ func TestSplice(t *testing.T) {
 					OP:    1,
 				},
 			}
-			if err := db.WritePost(nil, post); err != nil {
-				t.Fatal(err)
-			}
+			writePost(t, post)
 
 			cl, _ := sv.NewClient()
 			registerClient(t, cl, 1, "a")
@@ -529,11 +521,11 @@ func TestSplice(t *testing.T) {
 					Start: c.start,
 					Len:   c.len,
 				},
-				Text: []rune(c.text),
+				Text: c.text,
 			}
 
-			if err := cl.spliceText(marshalJSON(t, req)); err != nil {
-				t.Fatal(err)
+			if err := cl.spliceText(marshalJSON(t, req)); err != c.err {
+				UnexpectedError(t, err)
 			}
 
 			assertOpenPost(t, cl, utf8.RuneCountInString(c.final), c.final)
@@ -560,9 +552,7 @@ func TestCloseOldOpenPost(t *testing.T) {
 			OP: 1,
 		},
 	}
-	if err := db.WritePost(nil, post); err != nil {
-		t.Fatal(err)
-	}
+	writePost(t, post)
 
 	sv := newWSServer(t)
 	defer sv.Close()
@@ -584,47 +574,12 @@ func TestCloseOldOpenPost(t *testing.T) {
 	assertPostClosed(t, 2)
 }
 
-func TestInsertImageIntoPostWithImage(t *testing.T) {
-	t.Parallel()
-
-	sv := newWSServer(t)
-	defer sv.Close()
-	cl, _ := sv.NewClient()
-	cl.post = openPost{
-		id:       1,
-		time:     time.Now().Unix(),
-		hasImage: true,
-	}
-	if err := cl.insertImage(nil); err != errHasImage {
-		UnexpectedError(t, err)
-	}
-}
-
-func TestInsertImage(t *testing.T) {
+func TestEditingRoundTrip(t *testing.T) {
 	feeds.Clear()
-	assertTableClear(t, "boards", "images")
+	assertTableClear(t, "boards")
 	writeSampleBoard(t)
 	writeSampleThread(t)
-	writeSampleImage(t)
-	setBoardConfigs(t)
-
-	post := db.Post{
-		StandalonePost: common.StandalonePost{
-			Post: common.Post{
-				ID: 2,
-			},
-			Board: "a",
-			OP:    1,
-		},
-	}
-	if err := db.WritePost(nil, post); err != nil {
-		t.Fatal(err)
-	}
-
-	token, err := db.NewImageToken(stdJPEG.SHA1)
-	if err != nil {
-		t.Fatal(err)
-	}
+	writeSamplePost(t)
 
 	sv := newWSServer(t)
 	defer sv.Close()
@@ -632,26 +587,41 @@ func TestInsertImage(t *testing.T) {
 	registerClient(t, cl, 1, "a")
 	cl.post = openPost{
 		id:    2,
-		board: "a",
 		op:    1,
+		len:   3,
+		board: "a",
 		time:  time.Now().Unix(),
+		body:  []byte("abc"),
+	}
+
+	if err := cl.appendRune([]byte("100")); err != nil {
+		t.Fatal(err)
 	}
+	assertBody(t, 2, "abcd")
 
-	req := ImageRequest{
-		Name:  "foo.jpeg",
-		Token: token,
+	req := spliceRequest{
+		spliceCoords: spliceCoords{
+			Start: 0,
+			Len:   1,
+		},
+		Text: ">>1 Δ",
 	}
-	if err := cl.insertImage(marshalJSON(t, req)); err != nil {
+	if err := cl.spliceText(marshalJSON(t, req)); err != nil {
 		t.Fatal(err)
 	}
+	assertOpenPost(t, cl, 8, ">>1 Δbcd")
+	assertBody(t, 2, ">>1 Δbcd")
 
-	if !cl.post.hasImage {
-		t.Error("no image flag on openPost")
+	if err := cl.closePost(); err != nil {
+		t.Fatal(err)
 	}
-}
+	AssertDeepEquals(t, cl.post, openPost{})
+	assertPostClosed(t, 2)
 
-func writeSampleImage(t *testing.T) {
-	if err := db.WriteImage(nil, stdJPEG); err != nil {
+	post, err := db.GetPost(2)
+	if err != nil {
 		t.Fatal(err)
 	}
+	AssertDeepEquals(t, post.Body, ">>1 Δbcd")
+	AssertDeepEquals(t, post.Links, common.Links{{1, 1}})
 }
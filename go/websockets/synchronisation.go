@@ -3,8 +3,11 @@
 package websockets
 
 import (
+	"database/sql"
 	"errors"
+	"unicode/utf8"
 
+	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/common"
 	"github.com/cutechan/cutechan/go/config"
 	"github.com/cutechan/cutechan/go/db"
@@ -46,6 +49,13 @@ func (c *Client) synchronise(data []byte) error {
 		}
 	}
 
+	// Close any open post, before switching feeds
+	if c.post.id != 0 && c.post.op != msg.Thread {
+		if err := c.closePost(); err != nil {
+			return err
+		}
+	}
+
 	return c.registerSync(msg.Thread, msg.Board)
 }
 
@@ -63,3 +73,57 @@ func (c *Client) registerSync(id uint64, board string) (err error) {
 	}
 	return
 }
+
+// Reclaim an open post after a disconnect. The client must already be synced
+// to the thread of the post.
+func (c *Client) reclaimPost(data []byte) error {
+	var req reclaimRequest
+	if err := decodeMessage(data, &req); err != nil {
+		return err
+	}
+	if c.post.id != 0 {
+		if err := c.closePost(); err != nil {
+			return err
+		}
+	}
+
+	hash, err := db.GetPostPassword(req.ID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return c.sendMessage(common.MessageReclaim, 1)
+	default:
+		return err
+	}
+	if auth.BcryptCompare(req.Password, hash) != nil {
+		return c.sendMessage(common.MessageReclaim, 1)
+	}
+
+	post, err := db.GetPost(req.ID)
+	if err != nil {
+		return err
+	}
+	if post.Deleted || !post.Editing {
+		return c.sendMessage(common.MessageReclaim, 1)
+	}
+	_, op, _ := feeds.GetSync(c)
+	if c.feed == nil || op != post.OP {
+		return errInvalidThread
+	}
+
+	body := []byte(post.Body)
+	c.post = openPost{
+		hasImage: len(post.Files) > 0,
+		len:      utf8.RuneCount(body),
+		id:       post.ID,
+		op:       post.OP,
+		time:     post.Time,
+		board:    post.Board,
+		body:     body,
+	}
+	// Already in the thread, so only restore the open post cache entry
+	// without broadcasting the post again
+	c.feed.InsertPost(post, body, nil)
+
+	return c.sendMessage(common.MessageReclaim, 0)
+}
@@ -192,7 +192,6 @@ func TestReclaimPost(t *testing.T) {
 			StandalonePost: common.StandalonePost{
 				Post: common.Post{
 					Editing: true,
-					Image:   &assets.StdJPEG,
 					ID:      2,
 					Body:    "abc\ndef",
 					Time:    3,
@@ -215,9 +214,7 @@ func TestReclaimPost(t *testing.T) {
 		},
 	}
 	for _, p := range posts {
-		if err := db.WritePost(nil, p); err != nil {
-			t.Fatal(err)
-		}
+		writePost(t, p)
 	}
 
 	cases := [...]struct {
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"github.com/cutechan/cutechan/go/auth"
 	"github.com/cutechan/cutechan/go/common"
+	"github.com/cutechan/cutechan/go/db"
 	"github.com/cutechan/cutechan/go/feeds"
+	"github.com/cutechan/cutechan/go/metrics"
 	"github.com/cutechan/cutechan/go/util"
 	"log"
 	"net/http"
@@ -58,6 +60,10 @@ type Client struct {
 	conn *websocket.Conn
 	// Client IP
 	ip string
+	// Login session token and account ID, if any
+	sessionToken, account string
+	// Post currently open by the client, if any
+	post openPost
 	// Internal message receiver channel
 	receive chan receivedMessage
 	// Only used to pass messages from the Send method.
@@ -102,11 +108,27 @@ func newClient(conn *websocket.Conn, req *http.Request) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	var sessionToken, account string
+	if c, err := req.Cookie("session"); err == nil {
+		if len(c.Value) == common.LenSession {
+			ss, err := db.GetSession("", c.Value)
+			switch err {
+			case nil:
+				sessionToken = c.Value
+				account = ss.UserID
+			case common.ErrInvalidCreds:
+			default:
+				return nil, err
+			}
+		}
+	}
 	return &Client{
-		ip:       ip,
-		close:    make(chan error, 2),
-		receive:  make(chan receivedMessage),
-		redirect: make(chan string),
+		ip:           ip,
+		sessionToken: sessionToken,
+		account:      account,
+		close:        make(chan error, 2),
+		receive:      make(chan receivedMessage),
+		redirect:     make(chan string),
 		// Allows for ~60 seconds of messages, until the buffer overflows.
 		// A larger gap is more acceptable to shitty connections and mobile
 		// phones, especially while uploading.
@@ -117,6 +139,8 @@ func newClient(conn *websocket.Conn, req *http.Request) (*Client, error) {
 
 // Listen listens for incoming messages on the channels and processes them
 func (c *Client) listen() error {
+	metrics.WebsocketClients.Inc()
+	defer metrics.WebsocketClients.Dec()
 	go c.receiverLoop()
 
 	// Clean up, when loop exits
@@ -152,6 +176,11 @@ func (c *Client) listenerLoop() error {
 				return err
 			}
 		case board := <-c.redirect:
+			if c.post.id != 0 {
+				if err := c.closePost(); err != nil {
+					return err
+				}
+			}
 			err := c.sendMessage(common.MessageRedirect, board)
 			if err != nil {
 				return err
@@ -328,3 +357,9 @@ func (c *Client) Redirect(board string) {
 func (c *Client) IP() string {
 	return c.ip
 }
+
+// Account returns the account ID the client is logged in as or an empty
+// string. Thread-safe, as the account is never written to after assignment.
+func (c *Client) Account() string {
+	return c.account
+}
@@ -24,6 +24,8 @@ const (
 	onlyText         = "only text frames allowed"
 	closeNormal      = "websocket: close 1000"
 	invalidCharacter = "invalid character"
+	// Only signature accepted in tests
+	testSign = "test"
 )
 
 var (
@@ -40,9 +42,12 @@ type mockWSServer struct {
 func init() {
 	db.ConnArgs = db.TestConnArgs
 	db.IsTest = true
-	if err := db.LoadDB(); err != nil {
+	if err := db.StartDB(); err != nil {
 		panic(err)
 	}
+	verifySign = func(_, sign string) bool {
+		return sign == testSign
+	}
 }
 
 func newWSServer(t testing.TB) *mockWSServer {
@@ -93,7 +98,7 @@ func assertTableClear(t testing.TB, tables ...string) {
 func readListenErrors(t *testing.T, cl *Client, sv *mockWSServer) {
 	defer sv.Done()
 	if err := cl.listen(); err != nil && err != websocket.ErrCloseSent {
-		t.Fatal(err)
+		t.Error(err)
 	}
 }
 
@@ -6,9 +6,9 @@
 
 import cx from "classnames";
 import { Component, h, render } from "preact";
-import { showSendAlert } from "../alerts";
+import { showAlert, showSendAlert } from "../alerts";
 import API from "../api";
-import { ModerationLevel } from "../auth";
+import { ModerationLevel, session } from "../auth";
 import _ from "../lang";
 import { BoardConfig, page } from "../state";
 import { readableTime, relativeTime } from "../templates";
@@ -57,6 +57,17 @@ const enum ModerationAction {
   spoilerImage,
   deleteThread,
   updateBoard,
+  stickyThread,
+  unstickyThread,
+  lockThread,
+  unlockThread,
+  moveThread,
+  dismissReport,
+  acceptReport,
+  undeletePost,
+  acceptAppeal,
+  rejectAppeal,
+  blockImage,
 }
 
 interface ModLogRecord {
@@ -69,19 +80,48 @@ interface ModLogRecord {
 
 type ModLogRecords = ModLogRecord[];
 
+interface Report {
+  id: number;
+  board: string;
+  post: number;
+  reason: string;
+  created: number;
+}
+
+type Reports = Report[];
+
+interface Appeal {
+  id: number;
+  board: string;
+  post: number;
+  reason: string;
+  body: string;
+  expires: number;
+  created: number;
+}
+
+type Appeals = Appeal[];
+
 declare global {
   interface Window {
     modBoards?: ModBoards;
+    modOwned?: string[];
     modStaff?: Staff;
     modBans?: BanRecords;
     modLog?: ModLogRecords;
+    modReports?: Reports;
+    modAppeals?: Appeals;
   }
 }
 
 export const modBoards = window.modBoards;
+// Boards user can configure. Staff of other boards only sees reports.
+export const modOwned = window.modOwned || [];
 export const modStaff = window.modStaff;
 export const modBans = window.modBans;
 export const modLog = window.modLog;
+export const modReports = window.modReports;
+export const modAppeals = window.modAppeals || [];
 
 type ChangeFn = (changes: BoardStateChanges) => void;
 
@@ -324,13 +364,14 @@ class Bans extends Component<BansProps, {}> {
           <thead>
             <tr class="admin-table-header admin-ban-item-header">
               <th class="admin-ban-id-header">#</th>
+              <th class="admin-ban-range-header">{_("Range")}</th>
               <th class="admin-ban-reason-header">{_("Reason")}</th>
               <th class="admin-ban-by-header">{_("By")}</th>
               <th class="admin-ban-time-header">{_("Expires")}</th>
             </tr>
           </thead>
           <tbody>
-            {bans.map(({ id, reason, by, expires }) => (
+            {bans.map(({ id, ip, reason, by, expires }) => (
               <tr
                 class="admin-table-item admin-ban-item"
                 onClick={() => this.handleRemove(id)}
@@ -340,6 +381,7 @@ class Bans extends Component<BansProps, {}> {
                     &gt;&gt;{id}
                   </a>
                 </td>
+                <td class="admin-ban-range">{ip}</td>
                 <td class="admin-ban-reason">{reason}</td>
                 <td class="admin-ban-by">{by}</td>
                 <td class="admin-ban-time" title={readableTime(expires)}>
@@ -349,7 +391,7 @@ class Bans extends Component<BansProps, {}> {
             ))}
             {!bans.length && (
               <tr class="admin-table-empty">
-                <td class="admin-bans-empty" colSpan={4}>
+                <td class="admin-bans-empty" colSpan={5}>
                   {_("No bans")}
                 </td>
               </tr>
@@ -366,6 +408,178 @@ class Bans extends Component<BansProps, {}> {
   }
 }
 
+interface ReportsProps {
+  board: string;
+}
+
+class ReportQueue extends Component<ReportsProps, {}> {
+  public render({ board }: ReportsProps) {
+    const reports = modReports.filter((r) => r.board === board);
+    return (
+      <div class="admin-reports">
+        <a class="admin-content-anchor" name="reports" />
+        <h3 class="admin-content-header">
+          <a class="admin-header-link" href="#reports">
+            {_("Reports")}
+          </a>
+        </h3>
+        <table class="admin-table admin-report-list">
+          <thead>
+            <tr class="admin-table-header admin-report-item-header">
+              <th class="admin-report-id-header">#</th>
+              <th class="admin-report-reason-header">{_("Reason")}</th>
+              <th class="admin-report-time-header">{_("Date")}</th>
+              <th class="admin-report-actions-header" />
+            </tr>
+          </thead>
+          <tbody>
+            {reports.map(({ id, post, reason, created }) => (
+              <tr class="admin-table-item admin-report-item">
+                <td class="admin-report-id">
+                  <a class="post-link" href={`/all/${post}#${post}`}>
+                    &gt;&gt;{post}
+                  </a>
+                </td>
+                <td class="admin-report-reason">{reason}</td>
+                <td class="admin-report-time" title={readableTime(created)}>
+                  {relativeTime(created)}
+                </td>
+                <td class="admin-report-actions">
+                  <a
+                    class="control admin-report-control"
+                    title={_("acceptReport")}
+                    onClick={() => this.handleAccept(id, post)}
+                  >
+                    <i class="fa fa-check" />
+                  </a>
+                  <a
+                    class="control admin-report-control"
+                    title={_("dismissReport")}
+                    onClick={() => this.handleDismiss(id)}
+                  >
+                    <i class="fa fa-times" />
+                  </a>
+                </td>
+              </tr>
+            ))}
+            {!reports.length && (
+              <tr class="admin-table-empty">
+                <td class="admin-reports-empty" colSpan={4}>
+                  {_("No reports")}
+                </td>
+              </tr>
+            )}
+          </tbody>
+        </table>
+      </div>
+    );
+  }
+  private handleAccept(id: number, post: number) {
+    API.report.accept({ id }).then(() => {
+      // All reports of the post are closed at once.
+      this.remove((r) => r.post === post);
+    }, showSendAlert);
+  }
+  private handleDismiss(id: number) {
+    API.report.dismiss({ id }).then(() => {
+      this.remove((r) => r.id === id);
+    }, showSendAlert);
+  }
+  private remove(fn: (r: Report) => boolean) {
+    replace(modReports, modReports.filter((r) => !fn(r)));
+    this.forceUpdate();
+  }
+}
+
+interface AppealsProps {
+  board: string;
+}
+
+class AppealQueue extends Component<AppealsProps, {}> {
+  public render({ board }: AppealsProps) {
+    const appeals = modAppeals.filter((a) => a.board === board);
+    return (
+      <div class="admin-appeals">
+        <a class="admin-content-anchor" name="appeals" />
+        <h3 class="admin-content-header">
+          <a class="admin-header-link" href="#appeals">
+            {_("Appeals")}
+          </a>
+        </h3>
+        <table class="admin-table admin-appeal-list">
+          <thead>
+            <tr class="admin-table-header admin-appeal-item-header">
+              <th class="admin-appeal-id-header">#</th>
+              <th class="admin-appeal-reason-header">{_("Reason")}</th>
+              <th class="admin-appeal-body-header">{_("Appeal")}</th>
+              <th class="admin-appeal-time-header">{_("Date")}</th>
+              <th class="admin-appeal-actions-header" />
+            </tr>
+          </thead>
+          <tbody>
+            {appeals.map(({ id, post, reason, body, expires, created }) => (
+              <tr class="admin-table-item admin-appeal-item">
+                <td class="admin-appeal-id">
+                  <a class="post-link" href={`/all/${post}#${post}`}>
+                    &gt;&gt;{post}
+                  </a>
+                </td>
+                <td
+                  class="admin-appeal-reason"
+                  title={`${_("Expires")}: ${readableTime(expires)}`}
+                >
+                  {reason}
+                </td>
+                <td class="admin-appeal-body">{body}</td>
+                <td class="admin-appeal-time" title={readableTime(created)}>
+                  {relativeTime(created)}
+                </td>
+                <td class="admin-appeal-actions">
+                  <a
+                    class="control admin-appeal-control"
+                    title={_("acceptAppeal")}
+                    onClick={() => this.handleAccept(id)}
+                  >
+                    <i class="fa fa-check" />
+                  </a>
+                  <a
+                    class="control admin-appeal-control"
+                    title={_("rejectAppeal")}
+                    onClick={() => this.handleReject(id)}
+                  >
+                    <i class="fa fa-times" />
+                  </a>
+                </td>
+              </tr>
+            ))}
+            {!appeals.length && (
+              <tr class="admin-table-empty">
+                <td class="admin-appeals-empty" colSpan={5}>
+                  {_("No appeals")}
+                </td>
+              </tr>
+            )}
+          </tbody>
+        </table>
+      </div>
+    );
+  }
+  private handleAccept(id: number) {
+    API.appeal.accept({ id }).then(() => {
+      this.remove(id);
+    }, showSendAlert);
+  }
+  private handleReject(id: number) {
+    API.appeal.reject({ id }).then(() => {
+      this.remove(id);
+    }, showSendAlert);
+  }
+  private remove(id: number) {
+    replace(modAppeals, modAppeals.filter((a) => a.id !== id));
+    this.forceUpdate();
+  }
+}
+
 interface LogProps {
   board: string;
 }
@@ -446,17 +660,100 @@ class Log extends Component<LogProps, {}> {
       case ModerationAction.deletePost:
         return <i class="fa fa-trash" title={_("deletePost")} />;
       case ModerationAction.deleteImage:
-        return null;
+        return <i class="fa fa-picture-o" title={_("deleteImage")} />;
       case ModerationAction.spoilerImage:
-        return null;
+        return <i class="fa fa-eye-slash" title={_("spoilerImage")} />;
       case ModerationAction.deleteThread:
         return <i class="fa fa-2x fa-trash-o" title={_("deleteThread")} />;
       case ModerationAction.updateBoard:
         return <i class="fa fa-refresh" title={_("updateBoard")} />;
+      case ModerationAction.stickyThread:
+        return <i class="fa fa-thumb-tack" title={_("stickyThread")} />;
+      case ModerationAction.unstickyThread:
+        return (
+          <span class="fa-stack" title={_("unstickyThread")}>
+            <i class="fa fa-thumb-tack fa-stack-1x" />
+            <i class="fa fa-ban fa-stack-2x admin-log-ban-icon" />
+          </span>
+        );
+      case ModerationAction.lockThread:
+        return <i class="fa fa-lock" title={_("lockThread")} />;
+      case ModerationAction.unlockThread:
+        return <i class="fa fa-unlock" title={_("unlockThread")} />;
+      case ModerationAction.moveThread:
+        return <i class="fa fa-share" title={_("moveThread")} />;
+      case ModerationAction.dismissReport:
+        return <i class="fa fa-flag-o" title={_("dismissReport")} />;
+      case ModerationAction.acceptReport:
+        return <i class="fa fa-flag" title={_("acceptReport")} />;
+      case ModerationAction.undeletePost:
+        return <i class="fa fa-undo" title={_("undeletePost")} />;
+      case ModerationAction.acceptAppeal:
+        return <i class="fa fa-unlock-alt" title={_("acceptAppeal")} />;
+      case ModerationAction.rejectAppeal:
+        return <i class="fa fa-times-circle" title={_("rejectAppeal")} />;
+      case ModerationAction.blockImage:
+        return <i class="fa fa-ban" title={_("blockImage")} />;
     }
   }
 }
 
+interface NotificationState {
+  text: string;
+  sending: boolean;
+}
+
+// Broadcast message to all connected clients. Only for site admin.
+class Notification extends Component<{}, NotificationState> {
+  public state = {
+    text: "",
+    sending: false,
+  };
+  public render({}, { text, sending }: NotificationState) {
+    return (
+      <div class="admin-notification">
+        <a class="admin-content-anchor" name="notification" />
+        <h3 class="admin-content-header">
+          <a class="admin-header-link" href="#notification">
+            {_("notification")}
+          </a>
+        </h3>
+        <div class="admin-notification-form">
+          <input
+            class="input admin-notification-input"
+            value={text}
+            disabled={sending}
+            onInput={this.handleInput}
+          />
+          <button
+            class="button admin-button admin-notification-button"
+            disabled={sending || !text}
+            onClick={this.handleSend}
+          >
+            {_("send")}
+          </button>
+        </div>
+      </div>
+    );
+  }
+  private handleInput = (e: Event) => {
+    const text = (e.target as HTMLInputElement).value;
+    this.setState({ text });
+  };
+  private handleSend = () => {
+    this.setState({ sending: true });
+    API.server
+      .notify(this.state.text)
+      .then(() => {
+        this.setState({ text: "" });
+        showAlert(_("notificationSent"));
+      }, showSendAlert)
+      .then(() => {
+        this.setState({ sending: false });
+      });
+  };
+}
+
 interface BoardState {
   settings: AdminBoardConfig;
   staff: Staff;
@@ -495,6 +792,8 @@ class Admin extends Component<{}, AdminState> {
   }
   public render({}, { id, boardState, needSaving, saving }: AdminState) {
     const { settings, staff, bans } = boardState;
+    const isAdmin = session && session.userID === "admin";
+    const owned = modOwned.indexOf(id) !== -1;
     return (
       <section class="admin">
         <header class="admin-header">
@@ -513,37 +812,74 @@ class Admin extends Component<{}, AdminState> {
         </header>
         <section class="admin-inner">
           <ul class="admin-section-tabs">
+            {owned && (
+              <li class="admin-section-tab">
+                <a href="#settings">{_("Settings")}</a>
+              </li>
+            )}
+            {owned && (
+              <li class="admin-section-tab">
+                <a href="#members">{_("Members")}</a>
+              </li>
+            )}
+            {owned && (
+              <li class="admin-section-tab">
+                <a href="#bans">{_("Bans")}</a>
+              </li>
+            )}
             <li class="admin-section-tab">
-              <a href="#settings">{_("Settings")}</a>
-            </li>
-            <li class="admin-section-tab">
-              <a href="#members">{_("Members")}</a>
-            </li>
-            <li class="admin-section-tab">
-              <a href="#bans">{_("Bans")}</a>
-            </li>
-            <li class="admin-section-tab">
-              <a href="#log">{_("Mod log")}</a>
+              <a href="#reports">{_("Reports")}</a>
             </li>
+            {owned && (
+              <li class="admin-section-tab">
+                <a href="#appeals">{_("Appeals")}</a>
+              </li>
+            )}
+            {owned && (
+              <li class="admin-section-tab">
+                <a href="#log">{_("Mod log")}</a>
+              </li>
+            )}
+            {isAdmin && (
+              <li class="admin-section-tab">
+                <a href="#notification">{_("notification")}</a>
+              </li>
+            )}
           </ul>
           <hr class="admin-separator" />
           <section class="admin-content">
-            <Settings
-              settings={settings}
-              disabled={saving}
-              onChange={this.handleChange}
-            />
-            <hr class="admin-separator" />
-            <Members
-              board={id}
-              staff={staff}
-              disabled={saving}
-              onChange={this.handleChange}
-            />
-            <hr class="admin-separator" />
-            <Bans bans={bans} disabled={saving} onChange={this.handleChange} />
-            <hr class="admin-separator" />
-            <Log board={id} />
+            {owned && (
+              <Settings
+                settings={settings}
+                disabled={saving}
+                onChange={this.handleChange}
+              />
+            )}
+            {owned && <hr class="admin-separator" />}
+            {owned && (
+              <Members
+                board={id}
+                staff={staff}
+                disabled={saving}
+                onChange={this.handleChange}
+              />
+            )}
+            {owned && <hr class="admin-separator" />}
+            {owned && (
+              <Bans
+                bans={bans}
+                disabled={saving}
+                onChange={this.handleChange}
+              />
+            )}
+            {owned && <hr class="admin-separator" />}
+            <ReportQueue board={id} />
+            {owned && <hr class="admin-separator" />}
+            {owned && <AppealQueue board={id} />}
+            {owned && <hr class="admin-separator" />}
+            {owned && <Log board={id} />}
+            {isAdmin && <hr class="admin-separator" />}
+            {isAdmin && <Notification />}
           </section>
         </section>
         <footer
@@ -12,6 +12,7 @@ import {
   sendJSON,
   uncachedGET,
 } from "../util";
+import { uploadResumable } from "./tus";
 
 type ReqFn = (
   url: string,
@@ -80,6 +81,9 @@ const emit = {
   PUT: {
     JSON: makeReq(sendJSON, "PUT"),
   },
+  DELETE: {
+    JSON: makeReq(sendJSON, "DELETE"),
+  },
 };
 
 export const API = {
@@ -87,10 +91,30 @@ export const API = {
     create: emit.POST.Form("post"),
     createToken: emit.POST.JSON("post/token"),
     delete: emit.POST.JSON("delete-post"),
+    undelete: emit.POST.JSON("undelete-post"),
+    deleteImage: emit.POST.JSON("delete-image"),
+    spoilerImage: emit.POST.JSON("spoiler-image"),
     get: (id: number) => emit.GET.JSON(`post/${id}`)(),
+    getSameIP: (id: number) => emit.GET.JSON(`same-ip/${id}`)(),
   },
   thread: {
     create: emit.POST.Form("thread"),
+    setSticky: emit.POST.JSON("sticky"),
+    setLocked: emit.POST.JSON("lock"),
+    move: emit.POST.JSON("move"),
+  },
+  upload: {
+    resumable: uploadResumable,
+  },
+  report: {
+    create: emit.POST.JSON("report"),
+    dismiss: emit.POST.JSON("dismiss-report"),
+    accept: emit.POST.JSON("accept-report"),
+  },
+  appeal: {
+    create: emit.POST.JSON("appeal"),
+    accept: emit.POST.JSON("accept-appeal"),
+    reject: emit.POST.JSON("reject-appeal"),
   },
   user: {
     banByPost: emit.POST.JSON("ban"),
@@ -98,9 +122,17 @@ export const API = {
   account: {
     setSettings: emit.POST.JSON("account/settings"),
   },
+  watch: {
+    list: emit.GET.JSON("watch"),
+    watch: (id: number) => emit.POST.JSON(`watch/${id}`)(),
+    unwatch: (id: number) => emit.DELETE.JSON(`watch/${id}`)(),
+  },
   board: {
     save: (b: string, data: Dict) => emit.PUT.JSON(`boards/${b}`)(data),
   },
+  server: {
+    notify: (text: string) => emit.POST.JSON("notification")(text as any),
+  },
 };
 
 export default API;
@@ -0,0 +1,147 @@
+/**
+ * Resumable file uploads using tus protocol. Big files are sent in
+ * chunks, so an interrupted upload continues from the last received
+ * byte instead of starting over.
+ */
+
+import _ from "../lang";
+import { AbortError, FutureAPI } from "../util";
+
+const TUS_VERSION = "1.0.0";
+const CHUNK_SIZE = 2 * 1024 * 1024;
+const MAX_RETRIES = 5;
+const RETRY_DELAY = 3000;
+
+export type UploadProgressFn = (loaded: number) => void;
+
+interface XHRResult {
+  status: number;
+  xhr: XMLHttpRequest;
+}
+
+function request(
+  method: string,
+  url: string,
+  headers: { [key: string]: string },
+  body?: Blob,
+  onProgress?: UploadProgressFn,
+  api?: FutureAPI
+): Promise<XHRResult> {
+  return new Promise((resolve, reject) => {
+    const xhr = new XMLHttpRequest();
+    xhr.open(method, url);
+    xhr.setRequestHeader("Tus-Resumable", TUS_VERSION);
+    for (const k of Object.keys(headers)) {
+      xhr.setRequestHeader(k, headers[k]);
+    }
+    xhr.onload = () => resolve({ status: xhr.status, xhr });
+    xhr.onerror = () => reject(new Error(_("unknownErr")));
+    if (onProgress) {
+      xhr.upload.onprogress = (e: ProgressEvent) => onProgress(e.loaded);
+    }
+    if (api) {
+      api.abort = () => {
+        xhr.abort();
+        reject(new AbortError());
+      };
+    }
+    xhr.send(body);
+  });
+}
+
+function errorFrom({ xhr }: XHRResult): Error {
+  try {
+    const data = JSON.parse(xhr.responseText);
+    return new Error(data.error || _("unknownErr"));
+  } catch (e) {
+    return new Error(_("unknownErr"));
+  }
+}
+
+function delay(ms: number): Promise<void> {
+  return new Promise((resolve) => setTimeout(resolve, ms));
+}
+
+function encodeMeta(meta: { [key: string]: string }): string {
+  return Object.keys(meta)
+    .map((k) => `${k} ${btoa(unescape(encodeURIComponent(meta[k])))}`)
+    .join(",");
+}
+
+/**
+ * Upload file and return image token to be passed to post creation.
+ */
+export function uploadResumable(
+  board: string,
+  file: Blob,
+  onProgress?: UploadProgressFn,
+  api?: FutureAPI
+): Promise<string> {
+  let url = "";
+  let offset = 0;
+  let retries = 0;
+
+  const create = () =>
+    request(
+      "POST",
+      "/api/uploads",
+      {
+        "Upload-Length": file.size.toString(),
+        "Upload-Metadata": encodeMeta({ board }),
+      },
+      null,
+      null,
+      api
+    ).then((res) => {
+      if (res.status !== 201) throw errorFrom(res);
+      url = res.xhr.getResponseHeader("Location");
+    });
+
+  // Ask server how much it got after a failure. Upload might have been
+  // finished already if only the response was lost.
+  const resync = (): Promise<string> =>
+    request("HEAD", url, {}, null, null, api).then((res) => {
+      if (res.status !== 200) throw errorFrom(res);
+      offset = +res.xhr.getResponseHeader("Upload-Offset");
+      return res.xhr.getResponseHeader("Image-Token") || sendChunk();
+    }, retry);
+
+  const sendChunk = (): Promise<string> => {
+    const chunk = file.slice(offset, offset + CHUNK_SIZE);
+    const start = offset;
+    const progress = onProgress
+      ? (loaded: number) => onProgress(start + loaded)
+      : null;
+    return request(
+      "PATCH",
+      url,
+      {
+        "Content-Type": "application/offset+octet-stream",
+        "Upload-Offset": offset.toString(),
+      },
+      chunk,
+      progress,
+      api
+    ).then((res) => {
+      // Previous request might still be in progress on the server, or
+      // the assembled file is waiting for a free thumbnailer.
+      if (res.status === 409 || res.status === 503) {
+        return retry(errorFrom(res));
+      }
+      if (res.status !== 204) throw errorFrom(res);
+      retries = 0;
+      offset = +res.xhr.getResponseHeader("Upload-Offset");
+      return res.xhr.getResponseHeader("Image-Token") || sendChunk();
+    }, retry);
+  };
+
+  // Network errors, conflicts and busy server are retried, other server
+  // errors are final.
+  const retry = (err: Error): Promise<string> => {
+    if (err instanceof AbortError || retries >= MAX_RETRIES) throw err;
+    retries++;
+    return delay(RETRY_DELAY).then(resync);
+  };
+
+  return create().then(sendChunk);
+}
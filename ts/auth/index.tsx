@@ -14,6 +14,8 @@ import { Post } from "../posts";
 import { getModel, page } from "../state";
 import {
   Constructable,
+  Dict,
+  getID,
   hook,
   HOOKS,
   on,
@@ -23,9 +25,15 @@ import {
 } from "../util";
 import {
   MODAL_CONTAINER_SEL,
+  POST_SEL,
   TRIGGER_BAN_BY_POST_SEL,
   TRIGGER_DELETE_POST_SEL,
   TRIGGER_IGNORE_USER_SEL,
+  TRIGGER_LOCK_THREAD_SEL,
+  TRIGGER_MOVE_THREAD_SEL,
+  TRIGGER_SAME_IP_POSTS_SEL,
+  TRIGGER_STICKY_THREAD_SEL,
+  TRIGGER_UNDELETE_POST_SEL,
 } from "../vars";
 import { BackgroundClickMixin, EscapePressMixin, MemberList } from "../widgets";
 import { BoardCreationForm } from "./board-form";
@@ -426,6 +434,107 @@ class IgnoreModalBase extends Component<{}, IgnoreState> {
 
 const IgnoreModal = EscapePressMixin(BackgroundClickMixin(IgnoreModalBase));
 
+interface BanRange {
+  label: string;
+  ipv4Prefix: number;
+  ipv6Prefix: number;
+}
+
+// Sizes of banned networks. Moderators don't know the address family of
+// the poster, so each choice covers both.
+const banRanges: BanRange[] = [
+  { label: "banAddress", ipv4Prefix: 32, ipv6Prefix: 64 },
+  { label: "banSubnet", ipv4Prefix: 24, ipv6Prefix: 48 },
+  { label: "banWideRange", ipv4Prefix: 16, ipv6Prefix: 32 },
+];
+
+interface BanState {
+  target?: Element;
+  shown: boolean;
+  left: number;
+  top: number;
+  sending: boolean;
+}
+
+class BanModalBase extends Component<{}, BanState> {
+  public state: BanState = {
+    target: null,
+    shown: false,
+    left: 0,
+    top: 0,
+    sending: false,
+  };
+  public componentDidMount() {
+    hook(HOOKS.openBanModal, this.show);
+  }
+  public componentWillUnmount() {
+    unhook(HOOKS.openBanModal, this.show);
+  }
+  public render({}, { shown, left, top, sending }: BanState) {
+    if (!shown) return null;
+    const style = { left, top };
+    return (
+      <div
+        class={cx("ban-modal", { "ban-modal_sending": sending })}
+        style={style}
+        onClick={this.handleModalClick}
+      >
+        <div class="ban-modal-info">{_("banConfirm")}</div>
+        {banRanges.map((r) => (
+          <div class="ban-modal-item" onClick={() => this.ban(r)}>
+            <span>{_(r.label)}</span>
+            <span class="ban-modal-prefix">
+              /{r.ipv4Prefix}, /{r.ipv6Prefix}
+            </span>
+          </div>
+        ))}
+      </div>
+    );
+  }
+  public onBackgroundClick = (e: MouseEvent) => {
+    if (e.target === this.state.target) return;
+    if (this.state.shown) {
+      this.hide();
+    }
+  };
+  public onEscapePress = () => {
+    this.hide();
+  };
+  private show = (target: Element) => {
+    if (target === this.state.target) {
+      this.hide();
+      return;
+    }
+    let { left, top } = target.getBoundingClientRect();
+    left += window.pageXOffset;
+    top += window.pageYOffset + 20;
+    this.setState({ shown: true, target, left, top });
+  };
+  private hide = () => {
+    if (this.state.sending) return;
+    this.setState({ target: null, shown: false });
+  };
+  private handleModalClick = (e: Event) => {
+    e.stopPropagation();
+  };
+  private ban(range: BanRange) {
+    if (this.state.sending) return;
+    const post = getModel(this.state.target);
+    this.setState({ sending: true });
+    banUser(post, range).then(
+      () => {
+        this.setState({ sending: false }, this.hide);
+      },
+      (err) => {
+        showAlert(err);
+        this.setState({ sending: false });
+      }
+    );
+  }
+}
+
+const BanModal = EscapePressMixin(BackgroundClickMixin(BanModalBase));
+
 // Terminate the user session(s) server-side and reset the panel
 async function logout(url: string) {
   const res = await fetch(url, {
@@ -491,21 +600,62 @@ function deletePost(post: Post, force?: boolean) {
   }, showAlert);
 }
 
-function banUser(post: Post) {
-  if (!confirm(_("banConfirm"))) return;
+function undeletePost(post: Post) {
+  API.post.undelete([post.id]).then(() => {
+    post.setUndeleted();
+  }, showAlert);
+}
+
+function banUser(post: Post, range: BanRange): Promise<void> {
   const YEAR = 365 * 24 * 60;
-  API.user
+  return API.user
     .banByPost({
       // Hardcode for now.
       duration: YEAR,
       global: position >= ModerationLevel.admin,
       ids: [post.id],
       reason: "default",
+      ipv4Prefix: range.ipv4Prefix,
+      ipv6Prefix: range.ipv6Prefix,
     })
     .then(() => {
       deletePost(post, true);
-    })
-    .catch(showAlert);
+    });
+}
+
+function showSameIPPosts(post: Post) {
+  API.post.getSameIP(post.id).then((posts: Dict[]) => {
+    const ids = posts.filter((p) => p.id !== post.id).map((p) => `>>${p.id}`);
+    showAlert({
+      title: _("sameIPPosts"),
+      message: ids.length ? ids.join(" ") : _("noSameIPPosts"),
+    });
+  }, showAlert);
+}
+
+function toggleSticky(el: Element) {
+  const postEl = el.closest(POST_SEL);
+  const sticky = !postEl.classList.contains("post_sticky");
+  API.thread.setSticky({ id: getID(postEl), sticky }).then(() => {
+    postEl.classList.toggle("post_sticky", sticky);
+  }, showAlert);
+}
+
+function toggleLock(el: Element) {
+  const postEl = el.closest(POST_SEL);
+  const locked = !postEl.classList.contains("post_locked");
+  API.thread.setLocked({ id: getID(postEl), locked }).then(() => {
+    postEl.classList.toggle("post_locked", locked);
+  }, showAlert);
+}
+
+function moveThread(el: Element) {
+  const id = getID(el.closest(POST_SEL));
+  const board = prompt(_("moveToBoard"));
+  if (!board) return;
+  API.thread.move({ id, board }).then(() => {
+    location.href = `/${board}/${id}`;
+  }, showAlert);
 }
 
 export function init() {
@@ -531,6 +681,11 @@ export function init() {
     }
   }
   if (position >= ModerationLevel.moderator) {
+    const container = document.querySelector(MODAL_CONTAINER_SEL);
+    if (container) {
+      render(<BanModal />, container);
+    }
+
     on(
       document,
       "click",
@@ -544,9 +699,54 @@ export function init() {
       document,
       "click",
       (e) => {
-        banUser(getModelByEvent(e));
+        undeletePost(getModelByEvent(e));
+      },
+      { selector: TRIGGER_UNDELETE_POST_SEL }
+    );
+
+    on(
+      document,
+      "click",
+      (e) => {
+        trigger(HOOKS.openBanModal, e.target);
       },
       { selector: TRIGGER_BAN_BY_POST_SEL }
     );
+
+    on(
+      document,
+      "click",
+      (e) => {
+        showSameIPPosts(getModelByEvent(e));
+      },
+      { selector: TRIGGER_SAME_IP_POSTS_SEL }
+    );
+
+    on(
+      document,
+      "click",
+      (e) => {
+        toggleSticky(e.target as Element);
+      },
+      { selector: TRIGGER_STICKY_THREAD_SEL }
+    );
+
+    on(
+      document,
+      "click",
+      (e) => {
+        toggleLock(e.target as Element);
+      },
+      { selector: TRIGGER_LOCK_THREAD_SEL }
+    );
+
+    on(
+      document,
+      "click",
+      (e) => {
+        moveThread(e.target as Element);
+      },
+      { selector: TRIGGER_MOVE_THREAD_SEL }
+    );
   }
 }
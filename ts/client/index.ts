@@ -6,9 +6,19 @@ import { showAlert } from "../alerts";
 import { PostData } from "../common";
 import { connEvent, connSM, handlers, message } from "../connection";
 import options from "../options";
-import { isHoverActive, Post, PostView } from "../posts";
+import {
+  CloseMessage,
+  isHoverActive,
+  Post,
+  PostView,
+  SpliceMessage,
+} from "../posts";
 import { page, posts } from "../state";
-import { postAdded } from "../ui";
+import {
+  notifyAboutRemoteReply,
+  postAdded,
+  ReplyNotification,
+} from "../ui";
 import { isAtBottom, scrollToBottom } from "../util";
 
 // Run a function on a model, if it exists
@@ -60,8 +70,15 @@ export function init() {
     location.href = `/${board}/`;
   };
 
-  // handlers[message.notification] = (text: string) =>
-  //   new OverlayNotification(text);
+  // Either a plain text notification from the admin or a reply to a post
+  // of the logged in user.
+  handlers[message.notification] = (msg: string | ReplyNotification) => {
+    if (typeof msg === "string") {
+      showAlert({ message: msg, sticky: true });
+    } else if (msg.type === "reply") {
+      notifyAboutRemoteReply(msg);
+    }
+  };
 
   // handlers[message.insertImage] = (msg: ImageMessage) =>
   //   handle(msg.id, (m) => {
@@ -69,26 +86,16 @@ export function init() {
   //     m.insertImage(msg);
   //   });
 
-  // handlers[message.append] = ([id, char]: [number, number]) =>
-  //   handle(id, (m) =>
-  //     m.append(char));
+  handlers[message.append] = ([id, char]: [number, number]) =>
+    handle(id, (m) => m.append(char));
 
-  // handlers[message.backspace] = (id: number) =>
-  //   handle(id, (m) =>
-  //     m.backspace());
-
-  // handlers[message.splice] = (msg: SpliceResponse) =>
-  //   handle(msg.id, (m) =>
-  //     m.splice(msg));
-
-  // handlers[message.closePost] = ({ id, links }: CloseMessage) =>
-  //   handle(id, (m) => {
-  //     if (links) {
-  //       m.links = links;
-  //       m.propagateLinks();
-  //     }
-  //     m.closePost();
-  //   });
+  handlers[message.backspace] = (id: number) => handle(id, (m) => m.backspace());
+
+  handlers[message.splice] = (msg: SpliceMessage) =>
+    handle(msg.id, (m) => m.splice(msg));
+
+  handlers[message.closePost] = (msg: CloseMessage) =>
+    handle(msg.id, (m) => m.closePost(msg));
 
   // handlers[message.deleteImage] = (id: number) =>
   //   handle(id, (m) =>
@@ -4,6 +4,7 @@
 export interface ThreadData extends PostData {
   abbrev: boolean;
   sticky: boolean;
+  locked?: boolean;
   postCtr: number;
   imageCtr: number;
   replyTime: number;
@@ -26,6 +27,8 @@ export interface PostData {
   files?: ImageData[];
   op?: number;
   board?: string;
+  deleted?: boolean;
+  editing?: boolean;
 }
 
 /** Generic link object containing target post board and thread. */
@@ -54,8 +57,26 @@ export interface ImageData {
   thumbType: fileTypes;
   length?: number;
   title?: string;
+  artist?: string;
+  md5: string;
   // [width, height, thumbnail_width, thumbnail_height]
   dims: [number, number, number, number];
+  variants?: ThumbVariant[];
+  contents?: ArchiveEntry[];
+  spoiler?: boolean;
+}
+
+/** Single file stored in the uploaded archive. */
+export interface ArchiveEntry {
+  name: string;
+  size: number;
+}
+
+/** Additional WebP thumbnail of an image. */
+export interface ThumbVariant {
+  name: string;
+  width: number;
+  height: number;
 }
 
 /** Possible file types of a post image. */
@@ -74,6 +95,19 @@ export enum fileTypes {
   "7z",
   "tar.gz",
   "tar.xz",
+  webp,
+}
+
+/** Check if file type is an archive. */
+export function isArchive(type: fileTypes): boolean {
+  switch (type) {
+    case fileTypes.zip:
+    case fileTypes["7z"]:
+    case fileTypes["tar.gz"]:
+    case fileTypes["tar.xz"]:
+      return true;
+  }
+  return false;
 }
 
 export const thumbSize = 200;
@@ -55,7 +55,7 @@ handlers[message.synchronise] = async (data: SyncData) => {
 
   // Board pages currently have no sync data
   if (data) {
-    const { recent, deleted } = data;
+    const { recent, open, deleted } = data;
     const proms: Array<Promise<void>> = [];
 
     for (const id of recent) {
@@ -94,6 +94,15 @@ handlers[message.synchronise] = async (data: SyncData) => {
       showAlert(e.message);
       throw e;
     });
+
+    // Catch up with open posts edited while disconnected
+    for (const id of Object.keys(open || {})) {
+      const post = posts.get(+id);
+      if (post && post.body !== open[+id].body) {
+        post.body = open[+id].body;
+        post.view.renderBody();
+      }
+    }
   }
 
   connSM.feed(connEvent.sync);
@@ -0,0 +1,39 @@
+/**
+ * Ban page with the appeal form.
+ */
+
+import { showSendAlert } from "../alerts";
+import API from "../api";
+import _ from "../lang";
+import { on } from "../util";
+import { BAN_APPEAL_FORM_SEL } from "../vars";
+
+function send(form: HTMLFormElement) {
+  const input = form.elements.namedItem("body") as HTMLTextAreaElement;
+  const button = form.querySelector("button");
+  const body = input.value.trim();
+  if (!body) return;
+
+  button.disabled = true;
+  API.appeal.create({ board: form.dataset.board, body }).then(
+    () => {
+      const state = document.createElement("div");
+      state.className = "ban-appeal-state";
+      state.textContent = _("appealSent");
+      form.replaceWith(state);
+    },
+    (err) => {
+      button.disabled = false;
+      showSendAlert(err);
+    }
+  );
+}
+
+export function init() {
+  const form = document.querySelector(BAN_APPEAL_FORM_SEL) as HTMLFormElement;
+  if (!form) return;
+  on(form, "submit", (e) => {
+    e.preventDefault();
+    send(form);
+  });
+}
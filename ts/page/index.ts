@@ -1,3 +1,4 @@
 export { isBanned } from "./common";
 export { render as renderThread } from "./thread";
 export { render as renderBoard } from "./board";
+export { init as initBanned } from "./banned";
@@ -1,4 +1,4 @@
-import { fileTypes } from "../common";
+import { fileTypes, ImageData } from "../common";
 import { config } from "../state";
 
 export function getFilePrefix(): string {
@@ -12,6 +12,33 @@ export function thumbPath(thumbType: fileTypes, sha1: string): string {
   }`;
 }
 
+// Get the path of a named WebP thumbnail variant.
+export function variantPath(name: string, sha1: string): string {
+  return `${getFilePrefix()}/thumb/${sha1.slice(0, 2)}/${sha1.slice(
+    2
+  )}-${name}.webp`;
+}
+
+// Build srcset of image's thumbnail variants, empty if there are none.
+export function variantSrcset(img: ImageData): string {
+  return (img.variants || [])
+    .map((v) => `${variantPath(v.name, img.SHA1)} ${v.width}w`)
+    .join(", ");
+}
+
+// Number of archive files shown in the tooltip.
+const maxListingEntries = 20;
+
+// Build short plain text listing of archive files.
+export function archiveListing(img: ImageData): string {
+  const contents = img.contents || [];
+  const names = contents.slice(0, maxListingEntries).map((e) => e.name);
+  if (contents.length > maxListingEntries) {
+    names.push("…");
+  }
+  return names.join("\n");
+}
+
 // Resolve the path to the source file of an upload.
 export function sourcePath(fileType: fileTypes, sha1: string): string {
   return `${getFilePrefix()}/src/${sha1.slice(0, 2)}/${sha1.slice(2)}.${
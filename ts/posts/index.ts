@@ -1,14 +1,30 @@
-export { Thread, Post, Backlinks } from "./model";
+export {
+  Backlinks,
+  CloseMessage,
+  Post,
+  SpliceMessage,
+  Thread,
+} from "./model";
 export { default as PostView } from "./view";
-export { getFilePrefix, thumbPath, sourcePath } from "./images";
+export {
+  archiveListing,
+  getFilePrefix,
+  thumbPath,
+  sourcePath,
+  variantPath,
+  variantSrcset,
+} from "./images";
 export { default as PostCollection } from "./collection";
 export { isOpen as isHoverActive } from "./hover";
 
+import { showAlert } from "../alerts";
+import API from "../api";
+import _ from "../lang";
 import options from "../options";
-import { page, posts } from "../state";
+import { getModel, page, posts } from "../state";
 import { copyToClipboard, on } from "../util";
 import { RELATIVE_TIME_PERIOD_SECS } from "../vars";
-import { POST_FILE_TITLE_SEL } from "../vars";
+import { POST_FILE_TITLE_SEL, TRIGGER_REPORT_POST_SEL } from "../vars";
 import { init as initHover } from "./hover";
 import { init as initPopup } from "./popup";
 import { init as initReply } from "./reply";
@@ -41,11 +57,29 @@ function initFileTitle() {
   );
 }
 
+function initReport() {
+  on(
+    document,
+    "click",
+    (e) => {
+      const post = getModel(e.target as Element);
+      if (!post) return;
+      const reason = prompt(_("reportReason"));
+      if (!reason) return;
+      API.report
+        .create({ id: post.id, reason })
+        .then(() => showAlert(_("reportSent")), showAlert);
+    },
+    { selector: TRIGGER_REPORT_POST_SEL }
+  );
+}
+
 export function init() {
   if (!page.catalog) {
     initRenderTime();
   }
   initFileTitle();
+  initReport();
   initReply();
   initHover();
   initPopup();
@@ -1,5 +1,14 @@
 import { Model } from "../base";
-import { fileTypes, ImageData, PostData, PostLink } from "../common";
+import {
+  ArchiveEntry,
+  Command,
+  fileTypes,
+  ImageData,
+  PostData,
+  PostLink,
+  ThumbVariant,
+} from "../common";
+import { isModerator } from "../auth";
 import { mine, page, posts } from "../state";
 import { notifyAboutReply } from "../ui";
 import Collection from "./collection";
@@ -13,10 +22,27 @@ export interface PostBacklinks {
   [id: string]: number;
 }
 
+// Splice of an open post body, mirroring websockets.spliceMessage.
+export interface SpliceMessage {
+  id: number;
+  start: number;
+  len: number;
+  text: string;
+}
+
+// Final state of a closed post, mirroring common.CloseMessage.
+export interface CloseMessage {
+  id: number;
+  links?: PostLink[];
+  commands?: Command[];
+}
+
 // Thread model, mirroring common.Thread.
 // Just a stub yet, for usage in isomorphic templates.
 export class Thread {
   public id: number;
+  public sticky?: boolean;
+  public locked?: boolean;
 
   constructor(post: Post) {
     this.id = post.op;
@@ -35,12 +61,14 @@ export class Post extends Model implements PostData {
   public userName?: string;
   public body: string;
   public links?: PostLink[];
+  public commands?: Command[];
   public files?: ImageData[];
   public backlinks: PostBacklinks;
   public op?: number;
   public board?: string;
   public sticky?: boolean;
   public deleted?: boolean;
+  public editing?: boolean;
   public subject?: string;
 
   constructor(attrs: PostData) {
@@ -116,12 +144,58 @@ export class Post extends Model implements PostData {
         posts.removeThread(this);
         this.view.removeThread();
       }
+    } else if (page.thread && isModerator()) {
+      // Moderators can still see and restore deleted posts.
+      this.deleted = true;
+      this.view.renderDeleted();
     } else {
       posts.remove(this);
       this.view.remove();
     }
   }
 
+  // Restore deleted post.
+  public setUndeleted() {
+    this.deleted = false;
+    this.view.renderDeleted();
+  }
+
+  // Append a character to the body of an open post.
+  public append(code: number) {
+    this.body += String.fromCodePoint(code);
+    this.view.renderBody();
+  }
+
+  // Remove the last character from the body of an open post.
+  public backspace() {
+    const chars = Array.from(this.body);
+    chars.pop();
+    this.body = chars.join("");
+    this.view.renderBody();
+  }
+
+  // Replace a part of the open post body. Coordinates are in code points,
+  // same as on the server.
+  public splice({ start, len, text }: SpliceMessage) {
+    const chars = Array.from(this.body);
+    chars.splice(start, len, ...Array.from(text));
+    this.body = chars.join("");
+    this.view.renderBody();
+  }
+
+  // Close an open post and render its final state.
+  public closePost({ links, commands }: CloseMessage) {
+    this.editing = false;
+    if (links) {
+      this.links = links;
+    }
+    if (commands) {
+      this.commands = commands;
+    }
+    this.view.renderBody();
+    this.propagateLinks();
+  }
+
   // Returns, if this post has been seen already.
   public seen(): boolean {
     // Already seen, nothing to do.
@@ -154,7 +228,11 @@ export class File implements ImageData {
   public thumbType: fileTypes;
   public length?: number;
   public title?: string;
+  public artist?: string;
+  public md5: string;
   public dims: [number, number, number, number];
+  public variants?: ThumbVariant[];
+  public contents?: ArchiveEntry[];
 
   public get thumb(): string {
     return thumbPath(this.thumbType, this.SHA1);
@@ -29,6 +29,7 @@ import {
   REPLY_CONTAINER_SEL,
   REPLY_HEIGHT_PX,
   REPLY_THREAD_WIDTH_PX,
+  RESUMABLE_UPLOAD_SIZE,
   TRIGGER_OPEN_REPLY_SEL,
   TRIGGER_QUOTE_POST_SEL,
 } from "../vars";
@@ -327,13 +328,20 @@ class Reply extends Component<any, any> {
           class="reply-files-input"
           ref={s(this, "fileEl")}
           type="file"
-          accept="image/*,video/*,audio/mpeg,audio/mp3"
+          accept={this.accept}
           multiple
           onChange={this.handleFileChange}
         />
       </div>
     );
   }
+  private get accept() {
+    const types = "image/*,video/*,audio/mpeg,audio/mp3";
+    const board = boards.find(({ id }) => id === this.state.board);
+    return board && board.extraFileTypes
+      ? types + ",application/pdf,audio/ogg,.ogg,.zip,.7z,.tar.gz,.tar.xz"
+      : types;
+  }
   private get cursor() {
     switch (this.state.pos) {
       case "nw":
@@ -722,27 +730,32 @@ class Reply extends Component<any, any> {
   private handleSend = () => {
     if (this.disabled) return;
     const { board, thread, subject, body, showBadge } = this.state;
-    const files = this.state.fwraps.map((f) => f.file);
+    let files = this.state.fwraps.map((f) => f.file);
     const sendFn = page.thread ? API.post.create : API.thread.create;
     this.setState({ sending: true });
-    API.post
-      .createToken()
-      .then(({ id: token }: Dict) => {
-        const sign = genSign(token);
-        return sendFn(
-          {
-            board,
-            thread,
-            subject,
-            body,
-            files,
-            showBadge,
-            token,
-            sign,
-          },
-          this.handleSendProgress,
-          this.sendAPI
-        );
+    this.uploadBig(board, files)
+      .then((tokens) => {
+        if (tokens.length) {
+          files = [];
+        }
+        return API.post.createToken().then(({ id: token }: Dict) => {
+          const sign = genSign(token);
+          return sendFn(
+            {
+              board,
+              thread,
+              subject,
+              body,
+              files,
+              tokens,
+              showBadge,
+              token,
+              sign,
+            },
+            this.handleSendProgress,
+            this.sendAPI
+          );
+        });
       })
       .then(
         (res: Dict) => {
@@ -764,6 +777,40 @@ class Reply extends Component<any, any> {
         this.sendAPI = {};
       });
   };
+  // Big files are sent with resumable uploads so flaky connections don't
+  // break posting. Files are uploaded in order and all of them are sent
+  // this way, to keep attachments order.
+  private uploadBig(board: string, files: Blob[]): Promise<string[]> {
+    if (!files.some((f) => f.size > RESUMABLE_UPLOAD_SIZE)) {
+      return Promise.resolve([]);
+    }
+    const total = files.reduce((sum, f) => sum + f.size, 0);
+    const tokens: string[] = [];
+    let done = 0;
+    return files
+      .reduce(
+        (p, file) =>
+          p.then(() =>
+            API.upload
+              .resumable(
+                board,
+                file,
+                (loaded) =>
+                  this.handleSendProgress({
+                    loaded: done + loaded,
+                    total,
+                  } as ProgressEvent),
+                this.sendAPI
+              )
+              .then((token) => {
+                tokens.push(token);
+                done += file.size;
+              })
+          ),
+        Promise.resolve()
+      )
+      .then(() => tokens);
+  }
   private handleSendProgress = (e: ProgressEvent) => {
     const progress = Math.floor((e.loaded / e.total) * 100);
     this.setState({ progress });
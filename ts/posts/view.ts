@@ -6,11 +6,12 @@ import {
   makePostContext,
   readableTime,
   relativeTime,
+  renderBody,
   renderPostLink,
   TemplateContext,
 } from "../templates";
 import { getID } from "../util";
-import { POST_BACKLINKS_SEL, THREAD_SEL } from "../vars";
+import { POST_BACKLINKS_SEL, POST_MESSAGE_SEL, THREAD_SEL } from "../vars";
 import { render as renderEmbeds } from "./embed";
 import { Post, Thread } from "./model";
 
@@ -69,6 +70,19 @@ export default class PostView extends View<Post> {
     container.innerHTML = html;
   }
 
+  // Rerender the body of an open post after it was modified.
+  public renderBody() {
+    this.el.classList.toggle("post_editing", !!this.model.editing);
+    const message = this.el.querySelector(POST_MESSAGE_SEL);
+    message.innerHTML = renderBody(this.model);
+    renderEmbeds(this.el);
+  }
+
+  // Grey out deleted post instead of removing it.
+  public renderDeleted() {
+    this.el.classList.toggle("post_deleted", !!this.model.deleted);
+  }
+
   public removeThread() {
     this.el.closest(THREAD_SEL).remove();
   }
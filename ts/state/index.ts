@@ -18,6 +18,7 @@ export interface BoardConfig {
   id: string;
   title: string;
   readOnly?: boolean;
+  extraFileTypes?: boolean;
 }
 
 // The current state of a board or thread page
@@ -25,6 +26,7 @@ export interface PageState {
   landing: boolean;
   stickers: boolean;
   admin: string;
+  banned: boolean;
   catalog: boolean;
   thread: number;
   lastN: number;
@@ -63,6 +65,7 @@ function getState(href: string): PageState {
     landing: pathname === "/",
     stickers: pathname.startsWith("/stickers/"),
     admin: admin ? admin[1] || "all" : "",
+    banned: /^\/\w+\/banned/.test(pathname),
     lastN: /[&\?]last=100/.test(u.search) ? 100 : 0,
     page: pageN ? parseInt(pageN[1], 10) : 0,
     thread: parseInt(thread && thread[1], 10) || 0,
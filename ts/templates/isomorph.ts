@@ -4,9 +4,17 @@
 import templates from "cc-templates";
 import Mustache from "mustache";
 import { bodyEmbeds, renderBody } from ".";
-import { fileTypes, ImageData } from "../common";
+import { fileTypes, ImageData, isArchive } from "../common";
 import { _, days, months, ngettext } from "../lang";
-import { Backlinks, Post, sourcePath, Thread, thumbPath } from "../posts";
+import {
+  archiveListing,
+  Backlinks,
+  Post,
+  sourcePath,
+  Thread,
+  thumbPath,
+  variantSrcset,
+} from "../posts";
 import { mine } from "../state";
 import { Dict, makeNode, pad, printf } from "../util";
 
@@ -40,6 +48,9 @@ export function makePostContext(
     TID: t.id,
     Index: index,
     OP: t.id === p.id,
+    Sticky: t.id === p.id && !!t.sticky,
+    Locked: t.id === p.id && !!t.locked,
+    Deleted: !!p.deleted,
     HasBoard: t.id === p.id && index && all,
     Board: p.board,
     Subject: p.subject,
@@ -56,6 +67,18 @@ export function makePostContext(
     if (ctx.OP) {
       classes.push("post_op");
     }
+    if (ctx.Sticky) {
+      classes.push("post_sticky");
+    }
+    if (ctx.Locked) {
+      classes.push("post_locked");
+    }
+    if (ctx.Deleted) {
+      classes.push("post_deleted");
+    }
+    if (ctx.post.editing) {
+      classes.push("post_editing");
+    }
     if (ctx.post.files) {
       classes.push("post_file");
       if (ctx.post.files.length > 1) {
@@ -103,6 +126,9 @@ export function makePostContext(
       HasLength: img.video || img.audio,
       Length: duration(img.length || 0),
       Record: img.audio && !img.video,
+      Archive: isArchive(img.fileType),
+      Document: img.fileType === fileTypes.pdf || isArchive(img.fileType),
+      Listing: archiveListing(img),
       Size: fileSize(img.size),
       Width: img.dims[0],
       Height: img.dims[1],
@@ -111,6 +137,9 @@ export function makePostContext(
       DName: getDownloadName(p, img, n),
       SourcePath: sourcePath(img.fileType, img.SHA1),
       ThumbPath: thumbPath(img.thumbType, img.SHA1),
+      HasSrcset: !!(img.variants && img.variants.length),
+      Srcset: variantSrcset(img),
+      Spoiler: !!img.spoiler,
     }).render()
   );
 
@@ -2,7 +2,9 @@ export { default as FormView } from "./forms";
 export { postAdded } from "./tab";
 export {
   default as notifyAboutReply,
+  notifyAboutRemoteReply,
   OverlayNotification,
+  ReplyNotification,
 } from "./notification";
 
 import { page } from "../state";
@@ -11,12 +13,14 @@ import { init as initKeyboard } from "./keyboard";
 import { init as initNav } from "./nav";
 import OptionPanel from "./options";
 import { init as initTab } from "./tab";
+import { init as initWatch } from "./watch";
 
 export function init() {
   initKeyboard();
   initNav();
   if (page.thread) {
     initTab();
+    initWatch();
   }
   // tslint:disable-next-line:no-unused-expression
   new FAQPanel();
@@ -2,7 +2,8 @@ import { View } from "../base";
 import _ from "../lang";
 import options from "../options";
 import { Post } from "../posts";
-import { mine } from "../state";
+import { showAlert } from "../alerts";
+import { mine, page } from "../state";
 import { DEFAULT_NOTIFICATION_IMAGE_URL } from "../vars";
 import { repliedToMe } from "./tab";
 
@@ -46,6 +47,45 @@ export default function notifyAboutReply(post: Post) {
   };
 }
 
+// Reply to a post of the logged in user, sent by the server.
+export interface ReplyNotification {
+  type: "reply";
+  id: number;
+  op: number;
+  board: string;
+  target: number;
+}
+
+// Notify the logged in user that one of their posts has been replied to,
+// possibly in another thread or from another device.
+export function notifyAboutRemoteReply(reply: ReplyNotification) {
+  // Replies inside the current thread to posts made from this browser
+  // are handled by the post model.
+  if (reply.op === page.thread && mine.has(reply.target)) return;
+
+  const url = `/${reply.board}/${reply.op}#${reply.id}`;
+  if (
+    !options.notification ||
+    typeof Notification !== "function" ||
+    (Notification as any).permission !== "granted"
+  ) {
+    showAlert({ title: _("quoted"), message: url });
+    return;
+  }
+
+  const icon = options.workModeToggle ? "" : DEFAULT_NOTIFICATION_IMAGE_URL;
+  const n = new Notification(_("quoted"), {
+    body: `>>/${reply.board}/${reply.id}`,
+    icon,
+    vibrate: true,
+  });
+  n.onclick = () => {
+    n.close();
+    window.focus();
+    location.href = url;
+  };
+}
+
 // Textual notification at the top of the page
 // TODO(Kagami): Rework.
 export class OverlayNotification extends View<null> {
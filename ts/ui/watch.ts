@@ -0,0 +1,52 @@
+/**
+ * Watch/unwatch toggle of the thread page.
+ */
+
+import { showAlert } from "../alerts";
+import API from "../api";
+import _ from "../lang";
+import { page } from "../state";
+import { on } from "../util";
+import { TRIGGER_WATCH_THREAD_SEL } from "../vars";
+
+interface WatchedThread {
+  id: number;
+}
+
+let watched = false;
+let pending = false;
+
+function render() {
+  const label = watched ? _("unwatchThread") : _("watchThread");
+  for (const el of document.querySelectorAll(TRIGGER_WATCH_THREAD_SEL)) {
+    el.textContent = label;
+  }
+}
+
+function toggle() {
+  if (pending) return;
+  pending = true;
+  const fn = watched ? API.watch.unwatch : API.watch.watch;
+  fn(page.thread).then(
+    () => {
+      watched = !watched;
+      pending = false;
+      render();
+    },
+    (err: Error) => {
+      pending = false;
+      showAlert(err);
+    }
+  );
+}
+
+export function init() {
+  // Buttons are only rendered for logged in users.
+  if (!document.querySelector(TRIGGER_WATCH_THREAD_SEL)) return;
+
+  API.watch.list().then((threads: WatchedThread[]) => {
+    watched = threads.some((t) => t.id === page.thread);
+    render();
+  }, showAlert);
+  on(document, "click", toggle, { selector: TRIGGER_WATCH_THREAD_SEL });
+}
@@ -18,6 +18,7 @@ export const enum HOOKS {
   spoilerMarkup,
   focusIdolSearch,
   openIgnoreModal,
+  openBanModal,
 }
 
 const hooks = new EventEmitter();
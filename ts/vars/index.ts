@@ -13,10 +13,12 @@ export const REPLY_CONTAINER_SEL = ".reply-container";
 export const PROFILES_CONTAINER_SEL = ".header-profiles";
 export const BOARD_SEARCH_INPUT_SEL = ".board-search-input";
 export const BOARD_SEARCH_SORT_SEL = ".board-search-sort";
+export const BAN_APPEAL_FORM_SEL = ".ban-appeal-form";
 export const THREAD_SEL = ".thread";
 export const POST_SEL = ".post";
 export const POST_LINK_SEL = ".post-link";
 export const POST_BODY_SEL = ".post-body";
+export const POST_MESSAGE_SEL = ".post-message";
 export const POST_FILE_TITLE_SEL = ".post-file-title";
 export const POST_FILE_LINK_SEL = ".post-file-link";
 export const POST_FILE_THUMB_SEL = ".post-file-thumb";
@@ -28,13 +30,20 @@ export const PAGE_NAV_BOTTOM_SEL = ".page-nav-bottom";
 // Action trigger selectors, might appear multiple times in markup.
 export const TRIGGER_OPEN_REPLY_SEL = ".trigger-open-reply";
 export const TRIGGER_QUOTE_POST_SEL = ".trigger-quote-post";
+export const TRIGGER_REPORT_POST_SEL = ".trigger-report-post";
 export const TRIGGER_DELETE_POST_SEL = ".trigger-delete-post";
+export const TRIGGER_UNDELETE_POST_SEL = ".trigger-undelete-post";
 export const TRIGGER_BAN_BY_POST_SEL = ".trigger-ban-by-post";
+export const TRIGGER_SAME_IP_POSTS_SEL = ".trigger-same-ip-posts";
+export const TRIGGER_STICKY_THREAD_SEL = ".trigger-sticky-thread";
+export const TRIGGER_LOCK_THREAD_SEL = ".trigger-lock-thread";
+export const TRIGGER_MOVE_THREAD_SEL = ".trigger-move-thread";
 export const TRIGGER_IGNORE_USER_SEL = ".trigger-ignore-user";
 export const TRIGGER_MEDIA_HOVER_SEL = ".trigger-media-hover";
 export const TRIGGER_MEDIA_POPUP_SEL = ".trigger-media-popup";
 export const TRIGGER_PAGE_NAV_TOP_SEL = ".trigger-page-nav-top";
 export const TRIGGER_PAGE_NAV_BOTTOM_SEL = ".trigger-page-nav-bottom";
+export const TRIGGER_WATCH_THREAD_SEL = ".trigger-watch-thread";
 
 // Constants.
 export const ALERT_HIDE_TIMEOUT_SECS = 4;
@@ -46,6 +55,7 @@ export const HEADER_HEIGHT_PX = 30;
 export const REPLY_THREAD_WIDTH_PX = 700;
 export const REPLY_BOARD_WIDTH_PX = 1000;
 export const REPLY_HEIGHT_PX = 200;
+export const RESUMABLE_UPLOAD_SIZE = 4 * 1024 * 1024;
 export const DEFAULT_NOTIFICATION_IMAGE_URL = "/static/img/notification.png";
 const DAY_MS = 24 * 60 * 60 * 1000;
 export const EMBED_CACHE_EXPIRY_MS = 30 * DAY_MS;